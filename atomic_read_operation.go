@@ -0,0 +1,12 @@
+package keyvaluestore
+
+// AtomicReadOperation reads several keys at once. Backends limit the number of operations, as
+// reported by Capabilities.MaxAtomicReadOperations.
+type AtomicReadOperation interface {
+	Get(key string) GetResult
+	SMembers(key string) SMembersResult
+	ZScore(key string, member interface{}) ScoreResult
+
+	// Executes the operation. The results are only valid once this returns without error.
+	Exec() error
+}
@@ -1,12 +1,17 @@
 package keyvaluestore
 
+import "time"
+
 type AtomicWriteResult interface {
-	// Returns false if the transaction failed due to this operation's conditional failing.
+	// Returns true if the transaction failed due to this operation's conditional failing. This can
+	// be used to determine which check caused the transaction to fail.
 	ConditionalFailed() bool
 }
 
-// DynamoDB can't do more than 10 operations in an atomic write. So all stores should enforce this
-// limit.
+// DynamoDB used to limit atomic writes to 10 operations, so all stores enforced this limit.
+//
+// Deprecated: Each backend now enforces its own limit, which is reported by
+// Capabilities.MaxAtomicWriteOperations.
 const MaxAtomicWriteOperations = 10
 
 type AtomicWriteOperation interface {
@@ -14,6 +19,39 @@ type AtomicWriteOperation interface {
 	CAS(key string, oldValue, newValue string) AtomicWriteResult
 	Delete(key string) AtomicWriteResult
 
+	// Unconditionally sets the key with an expiration.
+	SetEX(key string, value interface{}, ttl time.Duration) AtomicWriteResult
+
+	// Unconditionally sets the key.
+	Set(key string, value interface{}) AtomicWriteResult
+
+	// Adds to or creates a set.
+	SAdd(key string, member interface{}, members ...interface{}) AtomicWriteResult
+
+	// Removes from a set.
+	SRem(key string, member interface{}, members ...interface{}) AtomicWriteResult
+
+	// Adds to or creates a sorted set, or updates the score of an existing member.
+	ZAdd(key string, member interface{}, score float64) AtomicWriteResult
+
+	// Removes from a sorted set.
+	ZRem(key string, member interface{}) AtomicWriteResult
+
+	// Sets the key if it already exists.
+	SetXX(key string, value interface{}) AtomicWriteResult
+
+	// Adds to the integer value of the key, creating it if it doesn't exist.
+	AddInt(key string, n int64) AtomicWriteResult
+
+	// Requires the key's value to equal expected without writing to it.
+	Check(key string, expected string) AtomicWriteResult
+
+	// Requires the key to exist without writing to it.
+	CheckExists(key string) AtomicWriteResult
+
+	// Requires the key to not exist without writing to it.
+	CheckNotExists(key string) AtomicWriteResult
+
 	// Executes the operation. If a condition failed, returns false.
 	Exec() (bool, error)
 }
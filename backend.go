@@ -1,15 +1,32 @@
 package keyvaluestore
 
+import (
+	"context"
+	"time"
+)
+
 type Backend interface {
+	// WithContext returns a backend that performs its operations, including those of batches and
+	// atomic writes created from it, within the given context. Once the context is done, operations
+	// return the context's error instead of waiting for the underlying store.
+	WithContext(ctx context.Context) Backend
+
 	// Batch allows you to batch up simple operations for better performance potential. Use this
 	// only for possible performance benefits. Read isolation is implementation-defined and other
 	// properties such as atomicity should not be assumed.
 	Batch() BatchOperation
 
-	// AtomicWrite executes up to 10 write operations atomically, failing entirely if any
-	// conditional operations (e.g. SetNX) are not executed.
+	// AtomicWrite executes write operations atomically, failing entirely if any conditional
+	// operations (e.g. SetNX) are not executed. The number of operations is limited by the
+	// backend's Capabilities.
 	AtomicWrite() AtomicWriteOperation
 
+	// AtomicRead executes read operations as a single consistent snapshot.
+	AtomicRead() AtomicReadOperation
+
+	// Capabilities returns the backend's limits and the optional features it supports.
+	Capabilities() Capabilities
+
 	Delete(key string) (success bool, err error)
 	Get(key string) (*string, error)
 	Set(key string, value interface{}) error
@@ -28,8 +45,21 @@ type Backend interface {
 	// Set if the key doesn't exist.
 	SetNX(key string, value interface{}) (bool, error)
 
+	// Set with an expiration. Operations that replace the value, such as Set, remove the
+	// expiration. Operations that modify the value, such as AddInt, preserve it.
+	SetEX(key string, value interface{}, ttl time.Duration) error
+
+	// Sets the expiration of an existing key, returning false if the key doesn't exist. Only keys
+	// holding plain values are guaranteed to support expiration. Sets and sorted sets may not.
+	Expire(key string, ttl time.Duration) (bool, error)
+
+	// Gets the remaining time to live of a key. Returns nil if the key doesn't exist or doesn't
+	// expire.
+	TTL(key string) (*time.Duration, error)
+
 	// Add to or create a set. Sets are ideal for small sizes and fast read access. Sorted sets
-	// should be considered instead for large, write-heavy applications.
+	// should be considered instead for large, write-heavy applications. Some backends, such as
+	// DynamoDB, read the whole set for SIsMember, SCard, and SRandMember.
 	SAdd(key string, member interface{}, members ...interface{}) error
 
 	// Remove from a set.
@@ -38,6 +68,18 @@ type Backend interface {
 	// Get members of a set.
 	SMembers(key string) ([]string, error)
 
+	// Returns whether or not the member is in the set.
+	SIsMember(key string, member interface{}) (bool, error)
+
+	// Returns the number of members in the set, or 0 if it doesn't exist.
+	SCard(key string) (int, error)
+
+	// Returns a random member of the set without removing it, or nil if the set is empty.
+	SRandMember(key string) (*string, error)
+
+	// Atomically removes and returns a random member of the set, or nil if the set is empty.
+	SPop(key string) (*string, error)
+
 	// Add to or create a sorted set.
 	ZAdd(key string, member interface{}, score float64) error
 
@@ -65,6 +107,17 @@ type Backend interface {
 	// Gets the number of members with scores between min and max, inclusive.
 	ZCount(key string, min, max float64) (int, error)
 
+	// Get members (and their scores) of a sorted set by ascending score, within a range that may
+	// have exclusive bounds and an offset.
+	ZRangeByScoreRange(key string, r ScoreRange) (ScoredMembers, error)
+
+	// Get members (and their scores) of a sorted set by descending score, within a range that may
+	// have exclusive bounds and an offset. The offset is counted from the max end of the range.
+	ZRevRangeByScoreRange(key string, r ScoreRange) (ScoredMembers, error)
+
+	// Gets the number of members with scores between min and max.
+	ZCountRange(key string, min, max ScoreBound) (int, error)
+
 	// Gets the number of members between min and max. All members of the set must have been added
 	// with a zero score. min and max must begin with '(' or '[' to indicate exclusive or inclusive.
 	// Alternatively, min can be "-" and max can be "+" to represent infinities.
@@ -80,6 +133,75 @@ type Backend interface {
 	// exclusive or inclusive. Alternatively, min can be "-" and max can be "+" to represent
 	// infinities.
 	ZRevRangeByLex(key string, min, max string, limit int) ([]string, error)
+
+	// Gets a page of members (and their scores) of a sorted set by ascending score. Pass an empty
+	// cursor to get the first page, then the cursor of each page to get the next. Unlike
+	// ZRangeByScore, members that share a score are never skipped or repeated across pages. A limit
+	// of 0 gets all of the remaining members.
+	ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*ZRangePage, error)
+
+	// Like ZRangeByScorePage, but by descending score.
+	ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*ZRangePage, error)
+
+	// Gets a page of members of a sorted set by lexicographical order. The bounds are the same as
+	// for ZRangeByLex, and the cursor and limit are the same as for ZRangeByScorePage.
+	ZRangeByLexPage(key string, min, max, cursor string, limit int) (*ZLexRangePage, error)
+
+	// Like ZRangeByLexPage, but by descending lexicographical order.
+	ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*ZLexRangePage, error)
+
+	// Gets the number of members in a sorted set.
+	ZCard(key string) (int, error)
+
+	// Gets the zero-based rank of a member, ordered by ascending score. Returns nil if the member
+	// doesn't exist.
+	ZRank(key string, member interface{}) (*int, error)
+
+	// Gets the zero-based rank of a member, ordered by descending score. Returns nil if the member
+	// doesn't exist.
+	ZRevRank(key string, member interface{}) (*int, error)
+
+	// Get members of a sorted set by ascending score, from the start rank to the stop rank,
+	// inclusive. Negative ranks are offsets from the end of the set, so -1 is the last member.
+	ZRange(key string, start, stop int) ([]string, error)
+
+	// Get members (and their scores) of a sorted set by ascending score, from the start rank to the
+	// stop rank, inclusive. Negative ranks are offsets from the end of the set.
+	ZRangeWithScores(key string, start, stop int) (ScoredMembers, error)
+
+	// Get members of a sorted set by descending score, from the start rank to the stop rank,
+	// inclusive. Negative ranks are offsets from the end of the set.
+	ZRevRange(key string, start, stop int) ([]string, error)
+
+	// Get members (and their scores) of a sorted set by descending score, from the start rank to
+	// the stop rank, inclusive. Negative ranks are offsets from the end of the set.
+	ZRevRangeWithScores(key string, start, stop int) (ScoredMembers, error)
+
+	// Sets a field of a hash, creating the hash if it doesn't exist.
+	HSet(key, field string, value interface{}) error
+
+	// Gets a field of a hash. Returns nil if the field doesn't exist.
+	HGet(key, field string) (*string, error)
+
+	// Gets multiple fields of a hash. The values are returned in the same order as the fields, with
+	// nil for fields that don't exist.
+	HMGet(key string, field string, fields ...string) ([]*string, error)
+
+	// Removes fields from a hash. The hash is deleted once it has no fields left.
+	HDel(key string, field string, fields ...string) error
+
+	// Gets all of the fields of a hash. Returns an empty map if the hash doesn't exist.
+	HGetAll(key string) (map[string]string, error)
+
+	// Adds an integer to a field of a hash. Or sets it if the field doesn't exist.
+	HIncrBy(key, field string, n int64) (int64, error)
+
+	// Scan iterates over the keys that begin with the given prefix. Pass an empty cursor to get the
+	// first page, then the cursor of each page to get the next. The limit is only a hint: pages may
+	// have more or fewer keys, and may even be empty before the scan is complete. A limit of 0 lets
+	// the backend choose. Keys that are modified during the scan may be omitted or returned more
+	// than once.
+	Scan(prefix, cursor string, limit int) (*ScanPage, error)
 }
 
 type ScoredMembers []*ScoredMember
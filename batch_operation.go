@@ -1,5 +1,7 @@
 package keyvaluestore
 
+import "time"
+
 type DeleteResult interface {
 	Result() (bool, error)
 }
@@ -16,15 +18,72 @@ type ErrorResult interface {
 	Result() error
 }
 
+type BoolResult interface {
+	Result() (bool, error)
+}
+
+type IntResult interface {
+	Result() (int64, error)
+}
+
+type FloatResult interface {
+	Result() (float64, error)
+}
+
+type ScoreResult interface {
+	Result() (*float64, error)
+}
+
+type ZRangeResult interface {
+	Result() ([]string, error)
+}
+
+type ZRangeWithScoresResult interface {
+	Result() (ScoredMembers, error)
+}
+
+type HMGetResult interface {
+	Result() ([]*string, error)
+}
+
+type HGetAllResult interface {
+	Result() (map[string]string, error)
+}
+
+// BatchOperation queues operations that are sent to the backend when Exec is called. Operations
+// within a batch aren't ordered: backends may run them in any order or concurrently. So a batch
+// shouldn't contain operations that depend on each other, such as a read of a key that another
+// operation in the same batch writes.
 type BatchOperation interface {
 	Get(key string) GetResult
 	Delete(key string) DeleteResult
 	Set(key string, value interface{}) ErrorResult
+	SetNX(key string, value interface{}) BoolResult
+	SetXX(key string, value interface{}) BoolResult
+	AddInt(key string, n int64) IntResult
+	SetEX(key string, value interface{}, ttl time.Duration) ErrorResult
+	Expire(key string, ttl time.Duration) BoolResult
 	SMembers(key string) SMembersResult
 	SAdd(key string, member interface{}, members ...interface{}) ErrorResult
 	SRem(key string, member interface{}, members ...interface{}) ErrorResult
+	SIsMember(key string, member interface{}) BoolResult
+	SCard(key string) IntResult
+	SRandMember(key string) GetResult
+	SPop(key string) GetResult
 	ZAdd(key string, member interface{}, score float64) ErrorResult
 	ZRem(key string, member interface{}) ErrorResult
+	ZScore(key string, member interface{}) ScoreResult
+	ZIncrBy(key string, member string, n float64) FloatResult
+	ZCount(key string, min, max float64) IntResult
+	ZRangeByScore(key string, min, max float64, limit int) ZRangeResult
+	ZRangeByScoreWithScores(key string, min, max float64, limit int) ZRangeWithScoresResult
+	ZRangeByLex(key string, min, max string, limit int) ZRangeResult
+	HSet(key, field string, value interface{}) ErrorResult
+	HGet(key, field string) GetResult
+	HMGet(key string, field string, fields ...string) HMGetResult
+	HDel(key string, field string, fields ...string) ErrorResult
+	HGetAll(key string) HGetAllResult
+	HIncrBy(key, field string, n int64) IntResult
 
 	Exec() error
 }
@@ -77,6 +136,37 @@ func (op *FallbackBatchOperation) Set(key string, value interface{}) ErrorResult
 	return result
 }
 
+func (op *FallbackBatchOperation) SetEX(key string, value interface{}, ttl time.Duration) ErrorResult {
+	result := &fboErrorResult{}
+	op.fs = append(op.fs, func() {
+		result.err = op.Backend.SetEX(key, value, ttl)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboBoolResult struct {
+	value bool
+	err   error
+}
+
+func (r *fboBoolResult) Result() (bool, error) {
+	return r.value, r.err
+}
+
+func (op *FallbackBatchOperation) Expire(key string, ttl time.Duration) BoolResult {
+	result := &fboBoolResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.Expire(key, ttl)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
 type fboDeleteResult struct {
 	success bool
 	err     error
@@ -139,6 +229,52 @@ func (op *FallbackBatchOperation) SRem(key string, member interface{}, members .
 	return result
 }
 
+func (op *FallbackBatchOperation) SIsMember(key string, member interface{}) BoolResult {
+	result := &fboBoolResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.SIsMember(key, member)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) SCard(key string) IntResult {
+	result := &fboIntResult{}
+	op.fs = append(op.fs, func() {
+		var n int
+		n, result.err = op.Backend.SCard(key)
+		result.value = int64(n)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) SRandMember(key string) GetResult {
+	result := &fboGetResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.SRandMember(key)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) SPop(key string) GetResult {
+	result := &fboGetResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.SPop(key)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
 func (op *FallbackBatchOperation) ZAdd(key string, member interface{}, score float64) ErrorResult {
 	result := &fboErrorResult{}
 	op.fs = append(op.fs, func() {
@@ -161,6 +297,236 @@ func (op *FallbackBatchOperation) ZRem(key string, member interface{}) ErrorResu
 	return result
 }
 
+func (op *FallbackBatchOperation) HSet(key, field string, value interface{}) ErrorResult {
+	result := &fboErrorResult{}
+	op.fs = append(op.fs, func() {
+		result.err = op.Backend.HSet(key, field, value)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) HGet(key, field string) GetResult {
+	result := &fboGetResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.HGet(key, field)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) SetNX(key string, value interface{}) BoolResult {
+	result := &fboBoolResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.SetNX(key, value)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) SetXX(key string, value interface{}) BoolResult {
+	result := &fboBoolResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.SetXX(key, value)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) AddInt(key string, n int64) IntResult {
+	result := &fboIntResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.AddInt(key, n)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboScoreResult struct {
+	value *float64
+	err   error
+}
+
+func (r *fboScoreResult) Result() (*float64, error) {
+	return r.value, r.err
+}
+
+func (op *FallbackBatchOperation) ZScore(key string, member interface{}) ScoreResult {
+	result := &fboScoreResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.ZScore(key, member)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboFloatResult struct {
+	value float64
+	err   error
+}
+
+func (r *fboFloatResult) Result() (float64, error) {
+	return r.value, r.err
+}
+
+func (op *FallbackBatchOperation) ZIncrBy(key string, member string, n float64) FloatResult {
+	result := &fboFloatResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.ZIncrBy(key, member, n)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) ZCount(key string, min, max float64) IntResult {
+	result := &fboIntResult{}
+	op.fs = append(op.fs, func() {
+		var n int
+		n, result.err = op.Backend.ZCount(key, min, max)
+		result.value = int64(n)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboZRangeResult struct {
+	values []string
+	err    error
+}
+
+func (r *fboZRangeResult) Result() ([]string, error) {
+	return r.values, r.err
+}
+
+func (op *FallbackBatchOperation) ZRangeByScore(key string, min, max float64, limit int) ZRangeResult {
+	result := &fboZRangeResult{}
+	op.fs = append(op.fs, func() {
+		result.values, result.err = op.Backend.ZRangeByScore(key, min, max, limit)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboZRangeWithScoresResult struct {
+	members ScoredMembers
+	err     error
+}
+
+func (r *fboZRangeWithScoresResult) Result() (ScoredMembers, error) {
+	return r.members, r.err
+}
+
+func (op *FallbackBatchOperation) ZRangeByScoreWithScores(key string, min, max float64, limit int) ZRangeWithScoresResult {
+	result := &fboZRangeWithScoresResult{}
+	op.fs = append(op.fs, func() {
+		result.members, result.err = op.Backend.ZRangeByScoreWithScores(key, min, max, limit)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) ZRangeByLex(key string, min, max string, limit int) ZRangeResult {
+	result := &fboZRangeResult{}
+	op.fs = append(op.fs, func() {
+		result.values, result.err = op.Backend.ZRangeByLex(key, min, max, limit)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboHMGetResult struct {
+	values []*string
+	err    error
+}
+
+func (r *fboHMGetResult) Result() ([]*string, error) {
+	return r.values, r.err
+}
+
+func (op *FallbackBatchOperation) HMGet(key string, field string, fields ...string) HMGetResult {
+	result := &fboHMGetResult{}
+	op.fs = append(op.fs, func() {
+		result.values, result.err = op.Backend.HMGet(key, field, fields...)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+func (op *FallbackBatchOperation) HDel(key string, field string, fields ...string) ErrorResult {
+	result := &fboErrorResult{}
+	op.fs = append(op.fs, func() {
+		result.err = op.Backend.HDel(key, field, fields...)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboHGetAllResult struct {
+	value map[string]string
+	err   error
+}
+
+func (r *fboHGetAllResult) Result() (map[string]string, error) {
+	return r.value, r.err
+}
+
+func (op *FallbackBatchOperation) HGetAll(key string) HGetAllResult {
+	result := &fboHGetAllResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.HGetAll(key)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
+type fboIntResult struct {
+	value int64
+	err   error
+}
+
+func (r *fboIntResult) Result() (int64, error) {
+	return r.value, r.err
+}
+
+func (op *FallbackBatchOperation) HIncrBy(key, field string, n int64) IntResult {
+	result := &fboIntResult{}
+	op.fs = append(op.fs, func() {
+		result.value, result.err = op.Backend.HIncrBy(key, field, n)
+		if result.err != nil && op.firstError == nil {
+			op.firstError = result.err
+		}
+	})
+	return result
+}
+
 func (op *FallbackBatchOperation) Exec() error {
 	for _, f := range op.fs {
 		f()
@@ -0,0 +1,38 @@
+package keyvaluestore
+
+// Capabilities describes the limits and optional features of a backend. For limits, zero means
+// that the backend doesn't impose one.
+type Capabilities struct {
+	// The maximum number of operations in an atomic write.
+	MaxAtomicWriteOperations int
+
+	// The maximum number of operations in an atomic read.
+	MaxAtomicReadOperations int
+
+	// The maximum number of reads or writes sent to the store in a single request. Batches may be
+	// larger than this, but they'll be split into multiple requests and lose any atomicity that a
+	// single request would have had.
+	MaxBatchReadSize  int
+	MaxBatchWriteSize int
+
+	// The maximum size of a value in bytes. Writes of larger values return ErrValueTooLarge.
+	MaxValueSize int
+
+	// Whether reads may return stale values.
+	EventuallyConsistentReads bool
+
+	// Whether keys can be given an expiration via SetEX and Expire.
+	TTL bool
+
+	// Whether Scan is supported.
+	Scan bool
+
+	// Whether set, sorted set, and hash operations against a key that holds another kind of value
+	// fail with ErrWrongType. DynamoDB stores each kind of value in different items, so it can't
+	// detect this without additional reads.
+	WrongTypeErrors bool
+
+	// Whether the underlying store supports publish/subscribe messaging. This package doesn't
+	// provide a pub/sub API, so it must be used via the store's own client.
+	PubSub bool
+}
@@ -0,0 +1,278 @@
+package keyvaluestore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// Requests are allowed and their outcomes are counted.
+	CircuitClosed CircuitState = iota
+
+	// Requests are rejected until the breaker's open duration has passed.
+	CircuitOpen
+
+	// A limited number of trial requests are allowed to find out whether the backend has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreakerOptions configures a CircuitBreaker. The zero value is usable.
+type CircuitBreakerOptions struct {
+	// The fraction of failed requests, between 0 and 1, at which the breaker opens. Defaults to 0.5.
+	FailureRateThreshold float64
+
+	// The number of requests that must be made within the window before the breaker can open, so
+	// that a few failures during quiet periods don't trip it. Defaults to 20.
+	MinimumRequests int
+
+	// The period over which the failure rate is measured. Defaults to 10 seconds.
+	Window time.Duration
+
+	// How long the breaker stays open before allowing trial requests. Defaults to 5 seconds.
+	OpenDuration time.Duration
+
+	// The number of trial requests allowed while half-open. If they all succeed, the breaker closes.
+	// If any fail, it opens again. Defaults to 1.
+	HalfOpenRequests int
+
+	// Returns true if a request that returned the given error should count as a failure. Defaults
+	// to IsCircuitBreakerFailure.
+	IsFailure func(err error) bool
+
+	// If given, this is called whenever a breaker changes state. It must not block.
+	OnStateChange func(name string, from, to CircuitState)
+
+	// Defaults to SystemClock.
+	Clock Clock
+}
+
+// IsCircuitBreakerFailure returns true for errors that suggest the backend is unhealthy. Errors
+// that are caused by the request itself, such as ErrWrongType, and canceled contexts aren't
+// failures.
+func IsCircuitBreakerFailure(err error) bool {
+	switch err {
+	case nil, ErrContention, ErrTooManyOperations, ErrWrongType, ErrValueTooLarge, ErrCircuitOpen, context.Canceled:
+		return false
+	}
+	return true
+}
+
+// The window is divided into buckets so that old requests can be forgotten without remembering
+// each one.
+const circuitBreakerBuckets = 10
+
+type circuitBreakerBucket struct {
+	requests int
+	failures int
+}
+
+// CircuitBreaker tracks the failure rate of requests and stops allowing them while it's too high.
+// It's safe for concurrent use.
+type CircuitBreaker struct {
+	name                 string
+	failureRateThreshold float64
+	minimumRequests      int
+	window               time.Duration
+	openDuration         time.Duration
+	halfOpenRequests     int
+	isFailure            func(err error) bool
+	onStateChange        func(name string, from, to CircuitState)
+	clock                Clock
+
+	mutex sync.Mutex
+	state CircuitState
+
+	// Incremented with every state change so that requests that began in an earlier state aren't
+	// counted.
+	generation uint64
+
+	openedAt          time.Time
+	buckets           [circuitBreakerBuckets]circuitBreakerBucket
+	bucket            int
+	bucketStart       time.Time
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+// NewCircuitBreaker creates a closed breaker. The name is passed to OnStateChange.
+func NewCircuitBreaker(name string, opts *CircuitBreakerOptions) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		name:                 name,
+		failureRateThreshold: 0.5,
+		minimumRequests:      20,
+		window:               10 * time.Second,
+		openDuration:         5 * time.Second,
+		halfOpenRequests:     1,
+		isFailure:            IsCircuitBreakerFailure,
+		clock:                SystemClock,
+	}
+	if opts != nil {
+		if opts.FailureRateThreshold > 0 {
+			cb.failureRateThreshold = opts.FailureRateThreshold
+		}
+		if opts.MinimumRequests > 0 {
+			cb.minimumRequests = opts.MinimumRequests
+		}
+		if opts.Window > 0 {
+			cb.window = opts.Window
+		}
+		if opts.OpenDuration > 0 {
+			cb.openDuration = opts.OpenDuration
+		}
+		if opts.HalfOpenRequests > 0 {
+			cb.halfOpenRequests = opts.HalfOpenRequests
+		}
+		if opts.IsFailure != nil {
+			cb.isFailure = opts.IsFailure
+		}
+		if opts.Clock != nil {
+			cb.clock = opts.Clock
+		}
+		cb.onStateChange = opts.OnStateChange
+	}
+	cb.bucketStart = cb.clock.Now()
+	return cb
+}
+
+func (cb *CircuitBreaker) Name() string {
+	return cb.name
+}
+
+// Returns the breaker's current state.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mutex.Lock()
+	from := cb.state
+	cb.update(cb.clock.Now())
+	to := cb.state
+	cb.mutex.Unlock()
+
+	cb.notify(from, to)
+	return to
+}
+
+// Allow returns ErrCircuitOpen if a request shouldn't be made. Otherwise it returns a function that
+// must be called with the request's error once it completes.
+func (cb *CircuitBreaker) Allow() (func(err error), error) {
+	cb.mutex.Lock()
+	from := cb.state
+	cb.update(cb.clock.Now())
+	allowed := true
+	switch cb.state {
+	case CircuitOpen:
+		allowed = false
+	case CircuitHalfOpen:
+		if cb.halfOpenInFlight+cb.halfOpenSuccesses >= cb.halfOpenRequests {
+			allowed = false
+		} else {
+			cb.halfOpenInFlight++
+		}
+	}
+	generation := cb.generation
+	to := cb.state
+	cb.mutex.Unlock()
+
+	cb.notify(from, to)
+	if !allowed {
+		return nil, ErrCircuitOpen
+	}
+	return func(err error) {
+		cb.done(generation, err)
+	}, nil
+}
+
+func (cb *CircuitBreaker) done(generation uint64, err error) {
+	failed := err != nil && cb.isFailure(err)
+
+	cb.mutex.Lock()
+	from := cb.state
+	if generation == cb.generation {
+		now := cb.clock.Now()
+		switch cb.state {
+		case CircuitClosed:
+			cb.rotate(now)
+			cb.buckets[cb.bucket].requests++
+			if failed {
+				cb.buckets[cb.bucket].failures++
+			}
+			requests, failures := 0, 0
+			for _, bucket := range cb.buckets {
+				requests += bucket.requests
+				failures += bucket.failures
+			}
+			if requests >= cb.minimumRequests && float64(failures) >= cb.failureRateThreshold*float64(requests) {
+				cb.setState(CircuitOpen, now)
+			}
+		case CircuitHalfOpen:
+			cb.halfOpenInFlight--
+			if failed {
+				cb.setState(CircuitOpen, now)
+			} else if cb.halfOpenSuccesses++; cb.halfOpenSuccesses >= cb.halfOpenRequests {
+				cb.setState(CircuitClosed, now)
+			}
+		}
+	}
+	to := cb.state
+	cb.mutex.Unlock()
+
+	cb.notify(from, to)
+}
+
+// Moves from open to half-open once the open duration has passed.
+func (cb *CircuitBreaker) update(now time.Time) {
+	if cb.state == CircuitOpen && now.Sub(cb.openedAt) >= cb.openDuration {
+		cb.setState(CircuitHalfOpen, now)
+	}
+}
+
+// Clears the buckets that have fallen out of the window.
+func (cb *CircuitBreaker) rotate(now time.Time) {
+	width := cb.window / circuitBreakerBuckets
+	if width <= 0 {
+		width = 1
+	}
+	n := now.Sub(cb.bucketStart) / width
+	if n <= 0 {
+		return
+	}
+	for i := time.Duration(0); i < n && i < circuitBreakerBuckets; i++ {
+		cb.bucket = (cb.bucket + 1) % circuitBreakerBuckets
+		cb.buckets[cb.bucket] = circuitBreakerBucket{}
+	}
+	cb.bucketStart = cb.bucketStart.Add(n * width)
+}
+
+func (cb *CircuitBreaker) setState(state CircuitState, now time.Time) {
+	cb.state = state
+	cb.generation++
+	cb.halfOpenInFlight = 0
+	cb.halfOpenSuccesses = 0
+	switch state {
+	case CircuitOpen:
+		cb.openedAt = now
+	case CircuitClosed:
+		cb.buckets = [circuitBreakerBuckets]circuitBreakerBucket{}
+		cb.bucketStart = now
+	}
+}
+
+func (cb *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && cb.onStateChange != nil {
+		cb.onStateChange(cb.name, from, to)
+	}
+}
@@ -0,0 +1,131 @@
+package keyvaluestore
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreakerBackendOptions configures a CircuitBreakerBackend. The zero value is usable.
+type CircuitBreakerBackendOptions struct {
+	CircuitBreakerOptions
+
+	// If true, each operation has its own breaker, named after the Backend method, so that an
+	// operation that's failing doesn't stop the others. Batches and atomic operations use the names
+	// "Batch", "AtomicWrite", and "AtomicRead". Otherwise, every operation shares a breaker named "".
+	PerOperation bool
+
+	// If given, requests that a breaker rejects are sent to the backend that this returns instead
+	// of failing with ErrCircuitOpen. It's called once, with a backend that rejects everything, for
+	// fallbacks that need a backend to wrap. For example, to serve reads from a read cache that
+	// outlives the breaker:
+	//
+	//     Fallback: func(unavailable keyvaluestore.Backend) keyvaluestore.Backend {
+	//         return cache.WithBackend(unavailable)
+	//     }
+	Fallback func(unavailable Backend) Backend
+}
+
+// CircuitBreakerBackend wraps a backend, rejecting requests with ErrCircuitOpen while too many of
+// them are failing, so that callers don't wait on a degraded backend. It's an InstrumentedBackend
+// whose interceptor asks a breaker to allow each operation.
+//
+// Batches and atomic operations are checked when they're executed, and their outcomes are counted
+// then.
+type CircuitBreakerBackend struct {
+	InstrumentedBackend
+
+	breakers *circuitBreakers
+}
+
+var _ Backend = &CircuitBreakerBackend{}
+
+type circuitBreakers struct {
+	opts         CircuitBreakerOptions
+	perOperation bool
+
+	mutex    sync.Mutex
+	breakers map[string]*CircuitBreaker
+}
+
+func (bs *circuitBreakers) get(operation string) *CircuitBreaker {
+	name := ""
+	if bs.perOperation {
+		name = operation
+	}
+
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	cb, ok := bs.breakers[name]
+	if !ok {
+		cb = NewCircuitBreaker(name, &bs.opts)
+		bs.breakers[name] = cb
+	}
+	return cb
+}
+
+func NewCircuitBreakerBackend(b Backend, opts *CircuitBreakerBackendOptions) *CircuitBreakerBackend {
+	interceptor := &circuitBreakerInterceptor{
+		breakers: &circuitBreakers{
+			breakers: map[string]*CircuitBreaker{},
+		},
+		done: map[*Operation]func(err error){},
+	}
+	if opts != nil {
+		interceptor.breakers.opts = opts.CircuitBreakerOptions
+		interceptor.breakers.perOperation = opts.PerOperation
+		if opts.Fallback != nil {
+			interceptor.fallback = opts.Fallback(NewInstrumentedBackend(b, failingInterceptor{ErrCircuitOpen}))
+		}
+	}
+	return &CircuitBreakerBackend{
+		InstrumentedBackend: InstrumentedBackend{
+			Backend:     b,
+			Interceptor: interceptor,
+		},
+		breakers: interceptor.breakers,
+	}
+}
+
+// Returns the breaker that guards the given operation.
+func (b *CircuitBreakerBackend) Breaker(operation string) *CircuitBreaker {
+	return b.breakers.get(operation)
+}
+
+// Asks a breaker to allow each operation, and sends the ones that it rejects to the fallback.
+type circuitBreakerInterceptor struct {
+	breakers *circuitBreakers
+	fallback Backend
+
+	// The functions to call with the errors of the operations that were allowed.
+	mutex sync.Mutex
+	done  map[*Operation]func(err error)
+}
+
+func (i *circuitBreakerInterceptor) Before(op *Operation) {
+	done, err := i.breakers.get(op.Name).Allow()
+	if err != nil {
+		if i.fallback == nil {
+			op.Err = err
+		} else if op.Context != nil {
+			op.Backend = i.fallback.WithContext(op.Context)
+		} else {
+			op.Backend = i.fallback
+		}
+		return
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.done[op] = done
+}
+
+func (i *circuitBreakerInterceptor) After(op *Operation, duration time.Duration, err error) {
+	i.mutex.Lock()
+	done, ok := i.done[op]
+	delete(i.done, op)
+	i.mutex.Unlock()
+
+	if ok {
+		done(err)
+	}
+}
@@ -0,0 +1,274 @@
+package keyvaluestore_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestorecache"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+// Fails requests with err while it's set.
+type faultyBackend struct {
+	keyvaluestore.Backend
+
+	err      error
+	requests int
+}
+
+func (b *faultyBackend) Get(key string) (*string, error) {
+	b.requests++
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.Backend.Get(key)
+}
+
+func (b *faultyBackend) Set(key string, value interface{}) error {
+	b.requests++
+	if b.err != nil {
+		return b.err
+	}
+	return b.Backend.Set(key, value)
+}
+
+func (b *faultyBackend) Batch() keyvaluestore.BatchOperation {
+	return &faultyBatchOperation{
+		BatchOperation: b.Backend.Batch(),
+		backend:        b,
+	}
+}
+
+type faultyBatchOperation struct {
+	keyvaluestore.BatchOperation
+	backend *faultyBackend
+}
+
+func (op *faultyBatchOperation) Exec() error {
+	op.backend.requests++
+	if op.backend.err != nil {
+		return op.backend.err
+	}
+	return op.BatchOperation.Exec()
+}
+
+func newTestCircuitBreakerBackend(opts *keyvaluestore.CircuitBreakerBackendOptions) (*keyvaluestore.CircuitBreakerBackend, *faultyBackend, *keyvaluestoretest.FakeClock) {
+	clock := keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0))
+	if opts == nil {
+		opts = &keyvaluestore.CircuitBreakerBackendOptions{}
+	}
+	opts.MinimumRequests = 2
+	opts.OpenDuration = 5 * time.Second
+	opts.Clock = clock
+	faulty := &faultyBackend{
+		Backend: memorystore.NewBackend(),
+	}
+	return keyvaluestore.NewCircuitBreakerBackend(faulty, opts), faulty, clock
+}
+
+func TestCircuitBreakerBackend(t *testing.T) {
+	keyvaluestoretest.TestBackend(t, func() keyvaluestore.Backend {
+		return keyvaluestore.NewCircuitBreakerBackend(memorystore.NewBackend(), nil)
+	})
+
+	failure := fmt.Errorf("failure")
+
+	t.Run("Trip", func(t *testing.T) {
+		var changes []stateChange
+		b, faulty, clock := newTestCircuitBreakerBackend(&keyvaluestore.CircuitBreakerBackendOptions{
+			CircuitBreakerOptions: keyvaluestore.CircuitBreakerOptions{
+				OnStateChange: func(name string, from, to keyvaluestore.CircuitState) {
+					changes = append(changes, stateChange{name, from, to})
+				},
+			},
+		})
+		require.NoError(t, faulty.Backend.Set("foo", "bar"))
+
+		faulty.err = failure
+		_, err := b.Get("foo")
+		assert.Equal(t, failure, err)
+		_, err = b.Get("foo")
+		assert.Equal(t, failure, err)
+		assert.Equal(t, 2, faulty.requests)
+
+		// Requests fail fast without reaching the backend, including other operations.
+		_, err = b.Get("foo")
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, b.Set("foo", "baz"))
+		assert.Equal(t, 2, faulty.requests)
+
+		batch := b.Batch()
+		get := batch.Get("foo")
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, batch.Exec())
+		_, err = get.Result()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		tx := b.AtomicWrite()
+		tx.Set("foo", "baz")
+		_, err = tx.Exec()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		read := b.AtomicRead()
+		readGet := read.Get("foo")
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, read.Exec())
+		_, err = readGet.Result()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		// After the open duration, a successful trial closes the breaker.
+		faulty.err = nil
+		clock.Advance(5 * time.Second)
+		v, err := b.Get("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", *v)
+		assert.Equal(t, keyvaluestore.CircuitClosed, b.Breaker("Get").State())
+
+		assert.Equal(t, []stateChange{
+			{"", keyvaluestore.CircuitClosed, keyvaluestore.CircuitOpen},
+			{"", keyvaluestore.CircuitOpen, keyvaluestore.CircuitHalfOpen},
+			{"", keyvaluestore.CircuitHalfOpen, keyvaluestore.CircuitClosed},
+		}, changes)
+	})
+
+	t.Run("PerOperation", func(t *testing.T) {
+		b, faulty, _ := newTestCircuitBreakerBackend(&keyvaluestore.CircuitBreakerBackendOptions{
+			PerOperation: true,
+		})
+
+		faulty.err = failure
+		b.Get("foo")
+		b.Get("foo")
+		assert.Equal(t, keyvaluestore.CircuitOpen, b.Breaker("Get").State())
+		assert.Equal(t, keyvaluestore.CircuitClosed, b.Breaker("Set").State())
+
+		faulty.err = nil
+		_, err := b.Get("foo")
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+		assert.NoError(t, b.Set("foo", "bar"))
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		b, faulty, _ := newTestCircuitBreakerBackend(&keyvaluestore.CircuitBreakerBackendOptions{
+			PerOperation: true,
+		})
+
+		faulty.err = failure
+		for i := 0; i < 2; i++ {
+			batch := b.Batch()
+			batch.Get("foo")
+			assert.Equal(t, failure, batch.Exec())
+		}
+		assert.Equal(t, keyvaluestore.CircuitOpen, b.Breaker("Batch").State())
+		assert.Equal(t, keyvaluestore.CircuitClosed, b.Breaker("Get").State())
+	})
+
+	t.Run("StaleOperations", func(t *testing.T) {
+		b, faulty, clock := newTestCircuitBreakerBackend(nil)
+		require.NoError(t, faulty.Backend.Set("foo", "bar"))
+
+		// Operations created while the breaker is closed are rejected if it opens before they're
+		// executed.
+		batch := b.Batch()
+		get := batch.Get("foo")
+		tx := b.AtomicWrite()
+		set := tx.Set("foo", "baz")
+		read := b.AtomicRead()
+		readGet := read.Get("foo")
+
+		faulty.err = failure
+		b.Get("foo")
+		b.Get("foo")
+		assert.Equal(t, keyvaluestore.CircuitOpen, b.Breaker("").State())
+		faulty.err = nil
+
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, batch.Exec())
+		_, err := get.Result()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		_, err = tx.Exec()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+		assert.False(t, set.ConditionalFailed())
+
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, read.Exec())
+		_, err = readGet.Result()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		assert.Equal(t, 2, faulty.requests)
+		v, err := faulty.Backend.Get("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", *v)
+
+		// Operations created while the breaker is half-open are rejected once the trial fails.
+		clock.Advance(5 * time.Second)
+		assert.Equal(t, keyvaluestore.CircuitHalfOpen, b.Breaker("").State())
+		trial := b.Batch()
+		trial.Get("foo")
+		batch = b.Batch()
+		get = batch.Get("foo")
+
+		faulty.err = failure
+		assert.Equal(t, failure, trial.Exec())
+		assert.Equal(t, 3, faulty.requests)
+		faulty.err = nil
+
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, batch.Exec())
+		_, err = get.Result()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+		assert.Equal(t, 3, faulty.requests)
+	})
+
+	t.Run("ReadCacheFallback", func(t *testing.T) {
+		memory := memorystore.NewBackend()
+		require.NoError(t, memory.Set("foo", "bar"))
+		require.NoError(t, memory.Set("baz", "qux"))
+		faulty := &faultyBackend{
+			Backend: memory,
+		}
+
+		// A long-lived cache that has already read foo.
+		cache := keyvaluestorecache.NewReadCache(faulty)
+		_, err := cache.Get("foo")
+		require.NoError(t, err)
+
+		b := keyvaluestore.NewCircuitBreakerBackend(faulty, &keyvaluestore.CircuitBreakerBackendOptions{
+			CircuitBreakerOptions: keyvaluestore.CircuitBreakerOptions{
+				MinimumRequests: 1,
+			},
+			Fallback: func(unavailable keyvaluestore.Backend) keyvaluestore.Backend {
+				return cache.WithBackend(unavailable).WithoutStoring()
+			},
+		})
+
+		// A batch created before the breaker opens is sent to the fallback.
+		batch := b.Batch()
+		get := batch.Get("foo")
+
+		faulty.err = failure
+		_, err = b.Get("baz")
+		assert.Equal(t, failure, err)
+
+		v, err := b.Get("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", *v)
+
+		require.NoError(t, batch.Exec())
+		v, err = get.Result()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", *v)
+
+		_, err = b.Get("baz")
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, b.Set("foo", "x"))
+
+		// The fallback's errors weren't cached.
+		faulty.err = nil
+		v, err = cache.Get("baz")
+		require.NoError(t, err)
+		assert.Equal(t, "qux", *v)
+	})
+}
@@ -0,0 +1,157 @@
+package keyvaluestore_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+)
+
+type stateChange struct {
+	name     string
+	from, to keyvaluestore.CircuitState
+}
+
+func newTestCircuitBreaker() (*keyvaluestore.CircuitBreaker, *keyvaluestoretest.FakeClock, *[]stateChange) {
+	clock := keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0))
+	var changes []stateChange
+	cb := keyvaluestore.NewCircuitBreaker("test", &keyvaluestore.CircuitBreakerOptions{
+		FailureRateThreshold: 0.5,
+		MinimumRequests:      4,
+		Window:               10 * time.Second,
+		OpenDuration:         5 * time.Second,
+		HalfOpenRequests:     2,
+		OnStateChange: func(name string, from, to keyvaluestore.CircuitState) {
+			changes = append(changes, stateChange{name, from, to})
+		},
+		Clock: clock,
+	})
+	return cb, clock, &changes
+}
+
+func request(t *testing.T, cb *keyvaluestore.CircuitBreaker, err error) {
+	done, allowErr := cb.Allow()
+	require.NoError(t, allowErr)
+	done(err)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failure := fmt.Errorf("failure")
+
+	t.Run("Trip", func(t *testing.T) {
+		cb, _, changes := newTestCircuitBreaker()
+
+		request(t, cb, nil)
+		request(t, cb, failure)
+		request(t, cb, nil)
+		assert.Equal(t, keyvaluestore.CircuitClosed, cb.State())
+
+		request(t, cb, failure)
+		assert.Equal(t, keyvaluestore.CircuitOpen, cb.State())
+		_, err := cb.Allow()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		assert.Equal(t, []stateChange{
+			{"test", keyvaluestore.CircuitClosed, keyvaluestore.CircuitOpen},
+		}, *changes)
+	})
+
+	t.Run("MinimumRequests", func(t *testing.T) {
+		cb, _, _ := newTestCircuitBreaker()
+		for i := 0; i < 3; i++ {
+			request(t, cb, failure)
+		}
+		assert.Equal(t, keyvaluestore.CircuitClosed, cb.State())
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		cb, clock, _ := newTestCircuitBreaker()
+		request(t, cb, failure)
+		request(t, cb, failure)
+		request(t, cb, failure)
+
+		// The earlier failures fall out of the window.
+		clock.Advance(11 * time.Second)
+		request(t, cb, failure)
+		request(t, cb, nil)
+		request(t, cb, nil)
+		request(t, cb, nil)
+		assert.Equal(t, keyvaluestore.CircuitClosed, cb.State())
+	})
+
+	t.Run("NotFailures", func(t *testing.T) {
+		cb, _, _ := newTestCircuitBreaker()
+		for _, err := range []error{keyvaluestore.ErrWrongType, keyvaluestore.ErrValueTooLarge, keyvaluestore.ErrContention, context.Canceled} {
+			request(t, cb, err)
+		}
+		assert.Equal(t, keyvaluestore.CircuitClosed, cb.State())
+	})
+
+	t.Run("Recovery", func(t *testing.T) {
+		cb, clock, changes := newTestCircuitBreaker()
+		for i := 0; i < 4; i++ {
+			request(t, cb, failure)
+		}
+
+		clock.Advance(4 * time.Second)
+		assert.Equal(t, keyvaluestore.CircuitOpen, cb.State())
+
+		clock.Advance(time.Second)
+		assert.Equal(t, keyvaluestore.CircuitHalfOpen, cb.State())
+
+		// Only two trial requests are allowed at a time.
+		done1, err := cb.Allow()
+		require.NoError(t, err)
+		done2, err := cb.Allow()
+		require.NoError(t, err)
+		_, err = cb.Allow()
+		assert.Equal(t, keyvaluestore.ErrCircuitOpen, err)
+
+		done1(nil)
+		assert.Equal(t, keyvaluestore.CircuitHalfOpen, cb.State())
+		done2(nil)
+		assert.Equal(t, keyvaluestore.CircuitClosed, cb.State())
+
+		assert.Equal(t, []stateChange{
+			{"test", keyvaluestore.CircuitClosed, keyvaluestore.CircuitOpen},
+			{"test", keyvaluestore.CircuitOpen, keyvaluestore.CircuitHalfOpen},
+			{"test", keyvaluestore.CircuitHalfOpen, keyvaluestore.CircuitClosed},
+		}, *changes)
+	})
+
+	t.Run("FailedTrial", func(t *testing.T) {
+		cb, clock, _ := newTestCircuitBreaker()
+		for i := 0; i < 4; i++ {
+			request(t, cb, failure)
+		}
+		clock.Advance(5 * time.Second)
+
+		request(t, cb, failure)
+		assert.Equal(t, keyvaluestore.CircuitOpen, cb.State())
+
+		// The open duration starts over.
+		clock.Advance(4 * time.Second)
+		assert.Equal(t, keyvaluestore.CircuitOpen, cb.State())
+	})
+
+	t.Run("StaleRequests", func(t *testing.T) {
+		cb, clock, _ := newTestCircuitBreaker()
+		stale, err := cb.Allow()
+		require.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			request(t, cb, failure)
+		}
+		clock.Advance(5 * time.Second)
+		request(t, cb, nil)
+
+		// A request that began before the breaker opened doesn't count as a trial.
+		stale(failure)
+		assert.Equal(t, keyvaluestore.CircuitHalfOpen, cb.State())
+	})
+}
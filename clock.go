@@ -0,0 +1,25 @@
+package keyvaluestore
+
+import "time"
+
+// Clock is a source of time. Anything that depends on the passage of time should use one so that
+// tests can control it.
+type Clock interface {
+	Now() time.Time
+
+	// Returns a channel that receives the current time once the given duration has elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the real, wall-clock time.
+var SystemClock Clock = systemClock{}
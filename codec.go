@@ -0,0 +1,80 @@
+package keyvaluestore
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Codec converts structured values to and from the bytes that are stored by backends.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes data into v, which must be a pointer.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(data, v)
+}
+
+// JSONCodec encodes values as JSON, compatibly with encoding/json.
+var JSONCodec Codec = jsonCodec{}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// GobCodec encodes values using encoding/gob. Each value carries its own type information, so it's
+// less compact than the other codecs.
+var GobCodec Codec = gobCodec{}
+
+// ProtoMarshaler is implemented by generated protobuf messages.
+type ProtoMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// ProtoUnmarshaler is implemented by pointers to generated protobuf messages.
+type ProtoUnmarshaler interface {
+	Unmarshal(data []byte) error
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(ProtoMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement ProtoMarshaler", v)
+	}
+	return m.Marshal()
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(ProtoUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%T does not implement ProtoUnmarshaler", v)
+	}
+	return m.Unmarshal(data)
+}
+
+// ProtobufCodec encodes protobuf messages using their generated Marshal and Unmarshal methods, such
+// as those generated by gogo/protobuf. Values that don't have them are rejected.
+var ProtobufCodec Codec = protobufCodec{}
@@ -0,0 +1,140 @@
+package dynamodbstore
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+type AtomicReadOperation struct {
+	Backend *Backend
+
+	items   []*dynamodb.TransactGetItem
+	results []*atomicReadResult
+}
+
+type atomicReadResult struct {
+	item map[string]*dynamodb.AttributeValue
+	err  error
+}
+
+type atomicReadGetResult struct {
+	*atomicReadResult
+	backend *Backend
+}
+
+func (r *atomicReadGetResult) Result() (*string, error) {
+	if r.err != nil {
+		return nil, r.err
+	} else if r.item == nil || r.item["v"] == nil || r.backend.isExpired(r.item) {
+		return nil, nil
+	}
+	return attributeStringValue(r.item["v"]), nil
+}
+
+type atomicReadSMembersResult struct {
+	*atomicReadResult
+}
+
+func (r *atomicReadSMembersResult) Result() ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	} else if r.item == nil {
+		return nil, nil
+	} else if c := r.item["c"]; c != nil && c.BOOL != nil && *c.BOOL {
+		// The set has spilled over into additional items, which can't be read in the same
+		// transaction.
+		return nil, fmt.Errorf("set is too large to be read atomically")
+	}
+	return attributeStringSliceValue(r.item["v"]), nil
+}
+
+type atomicReadScoreResult struct {
+	*atomicReadResult
+}
+
+func (r *atomicReadScoreResult) Result() (*float64, error) {
+	if r.err != nil {
+		return nil, r.err
+	} else if r.item != nil {
+		if rk2 := attributeStringValue(r.item["rk2"]); rk2 != nil {
+			score := sortKeyFloat(*rk2)
+			return &score, nil
+		}
+	}
+	return nil, nil
+}
+
+func (op *AtomicReadOperation) read(key map[string]*dynamodb.AttributeValue) *atomicReadResult {
+	op.items = append(op.items, &dynamodb.TransactGetItem{
+		Get: &dynamodb.Get{
+			Key:       key,
+			TableName: &op.Backend.TableName,
+		},
+	})
+	ret := &atomicReadResult{}
+	op.results = append(op.results, ret)
+	return ret
+}
+
+func (op *AtomicReadOperation) Get(key string) keyvaluestore.GetResult {
+	return &atomicReadGetResult{
+		atomicReadResult: op.read(compositeKey(key, "_")),
+		backend:          op.Backend,
+	}
+}
+
+// Sets that have grown beyond a single item can't be read atomically. Their results return an
+// error.
+func (op *AtomicReadOperation) SMembers(key string) keyvaluestore.SMembersResult {
+	return &atomicReadSMembersResult{
+		atomicReadResult: op.read(setKey(key, 0)),
+	}
+}
+
+func (op *AtomicReadOperation) ZScore(key string, member interface{}) keyvaluestore.ScoreResult {
+	return &atomicReadScoreResult{
+		atomicReadResult: op.read(compositeKey(key, *keyvaluestore.ToString(member))),
+	}
+}
+
+func (op *AtomicReadOperation) Exec() error {
+	if len(op.items) > maxTransactionItems {
+		return op.fail(keyvaluestore.ErrTooManyOperations)
+	} else if len(op.items) == 0 {
+		return nil
+	}
+
+	output, err := op.Backend.client().TransactGetItemsWithContext(op.Backend.context(), &dynamodb.TransactGetItemsInput{
+		TransactItems: op.items,
+	})
+	if err != nil {
+		if err, ok := err.(awserr.Error); ok {
+			// The documentation says "TransactionCancelledException", but the API returns
+			// "TransactionCanceledException"...
+			if code := err.Code(); code == "TransactionCanceledException" || code == "TransactionCancelledException" {
+				// Reads are only canceled due to conflicting writes.
+				return op.fail(keyvaluestore.ErrContention)
+			}
+		}
+		return op.fail(wrapError(err, "dynamodb transact get items request error"))
+	}
+
+	if len(output.Responses) != len(op.results) {
+		return op.fail(fmt.Errorf("unexpected number of responses"))
+	}
+	for i, response := range output.Responses {
+		op.results[i].item = response.Item
+	}
+	return nil
+}
+
+func (op *AtomicReadOperation) fail(err error) error {
+	for _, result := range op.results {
+		result.err = err
+	}
+	return err
+}
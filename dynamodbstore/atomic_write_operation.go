@@ -3,6 +3,7 @@ package dynamodbstore
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -18,6 +19,7 @@ type AtomicWriteOperation struct {
 
 	items   []*dynamodb.TransactWriteItem
 	results []*atomicWriteResult
+	err     error
 }
 
 type atomicWriteResult struct {
@@ -38,7 +40,10 @@ func (op *AtomicWriteOperation) write(item dynamodb.TransactWriteItem) *atomicWr
 func (op *AtomicWriteOperation) SetNX(key string, value interface{}) keyvaluestore.AtomicWriteResult {
 	return op.write(dynamodb.TransactWriteItem{
 		Put: &dynamodb.Put{
-			ConditionExpression: aws.String("attribute_not_exists(v)"),
+			ConditionExpression: aws.String("attribute_not_exists(v) OR ex <= :now"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now": op.Backend.nowAttributeValue(),
+			},
 			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
 				"v": attributeValue(value),
 			}),
@@ -47,12 +52,28 @@ func (op *AtomicWriteOperation) SetNX(key string, value interface{}) keyvaluesto
 	})
 }
 
+func (op *AtomicWriteOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.AtomicWriteResult {
+	if ttl <= 0 {
+		op.err = fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	return op.write(dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
+				"v":  attributeValue(value),
+				"ex": expirationAttributeValue(op.Backend.now().Add(ttl)),
+			}),
+			TableName: &op.Backend.TableName,
+		},
+	})
+}
+
 func (op *AtomicWriteOperation) CAS(key string, oldValue, newValue string) keyvaluestore.AtomicWriteResult {
 	return op.write(dynamodb.TransactWriteItem{
 		Put: &dynamodb.Put{
-			ConditionExpression: aws.String("v = :v"),
+			ConditionExpression: aws.String("v = :v AND " + notExpiredCondition),
 			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-				":v": attributeValue(oldValue),
+				":v":   attributeValue(oldValue),
+				":now": op.Backend.nowAttributeValue(),
 			},
 			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
 				"v": attributeValue(newValue),
@@ -71,7 +92,166 @@ func (op *AtomicWriteOperation) Delete(key string) keyvaluestore.AtomicWriteResu
 	})
 }
 
+func (op *AtomicWriteOperation) Set(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
+				"v": attributeValue(value),
+			}),
+			TableName: &op.Backend.TableName,
+		},
+	})
+}
+
+func setMembersAttributeValue(member interface{}, members ...interface{}) *dynamodb.AttributeValue {
+	bs := make([][]byte, 1+len(members))
+	bs[0] = []byte(*keyvaluestore.ToString(member))
+	for i, member := range members {
+		bs[i+1] = []byte(*keyvaluestore.ToString(member))
+	}
+	return &dynamodb.AttributeValue{
+		BS: bs,
+	}
+}
+
+// Transactions can't spill sets over into additional items the way Backend.SAdd does, so atomic
+// set writes only support sets that fit in a single item. If the set has grown beyond that, the
+// operation's condition fails.
+func (op *AtomicWriteOperation) SAdd(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			Key:                 setKey(key, 0),
+			TableName:           &op.Backend.TableName,
+			ConditionExpression: aws.String("attribute_not_exists(c) OR c = :c"),
+			UpdateExpression:    aws.String("ADD v :v SET c = :c"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":v": setMembersAttributeValue(member, members...),
+				":c": &dynamodb.AttributeValue{
+					BOOL: aws.Bool(false),
+				},
+			},
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) SRem(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			Key:                 setKey(key, 0),
+			TableName:           &op.Backend.TableName,
+			ConditionExpression: aws.String("attribute_not_exists(c) OR c = :c"),
+			UpdateExpression:    aws.String("DELETE v :v"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":v": setMembersAttributeValue(member, members...),
+				":c": &dynamodb.AttributeValue{
+					BOOL: aws.Bool(false),
+				},
+			},
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) ZAdd(key string, member interface{}, score float64) keyvaluestore.AtomicWriteResult {
+	s := *keyvaluestore.ToString(member)
+	return op.write(dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			Item: newItem(key, s, map[string]*dynamodb.AttributeValue{
+				"v":   attributeValue(s),
+				"rk2": attributeValue(floatSortKey(score) + s),
+			}),
+			TableName: &op.Backend.TableName,
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) ZRem(key string, member interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			Key:       compositeKey(key, *keyvaluestore.ToString(member)),
+			TableName: &op.Backend.TableName,
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) SetXX(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			ConditionExpression: aws.String("attribute_exists(v) AND " + notExpiredCondition),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now": op.Backend.nowAttributeValue(),
+			},
+			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
+				"v": attributeValue(value),
+			}),
+			TableName: &op.Backend.TableName,
+		},
+	})
+}
+
+// Unlike Backend.AddInt, this can't replace an item that has expired but hasn't been deleted yet.
+// If the key is in that state, the operation's condition fails.
+func (op *AtomicWriteOperation) AddInt(key string, n int64) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		Update: &dynamodb.Update{
+			Key:                 compositeKey(key, "_"),
+			TableName:           &op.Backend.TableName,
+			ConditionExpression: aws.String(notExpiredCondition),
+			UpdateExpression:    aws.String("ADD v :n"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":n":   attributeValue(n),
+				":now": op.Backend.nowAttributeValue(),
+			},
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) Check(key string, expected string) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		ConditionCheck: &dynamodb.ConditionCheck{
+			Key:                 compositeKey(key, "_"),
+			TableName:           &op.Backend.TableName,
+			ConditionExpression: aws.String("v = :v AND " + notExpiredCondition),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":v":   attributeValue(expected),
+				":now": op.Backend.nowAttributeValue(),
+			},
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) CheckExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		ConditionCheck: &dynamodb.ConditionCheck{
+			Key:                 compositeKey(key, "_"),
+			TableName:           &op.Backend.TableName,
+			ConditionExpression: aws.String("attribute_exists(v) AND " + notExpiredCondition),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now": op.Backend.nowAttributeValue(),
+			},
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) CheckNotExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.write(dynamodb.TransactWriteItem{
+		ConditionCheck: &dynamodb.ConditionCheck{
+			Key:                 compositeKey(key, "_"),
+			TableName:           &op.Backend.TableName,
+			ConditionExpression: aws.String("attribute_not_exists(v) OR ex <= :now"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now": op.Backend.nowAttributeValue(),
+			},
+		},
+	})
+}
+
 func (op *AtomicWriteOperation) Exec() (bool, error) {
+	if len(op.items) > maxTransactionItems {
+		return false, keyvaluestore.ErrTooManyOperations
+	} else if op.err != nil {
+		return false, op.err
+	}
+
 	token := make([]byte, 20)
 	if _, err := rand.Read(token); err != nil {
 		return false, errors.Wrap(err, "unable to generate request token")
@@ -84,7 +264,7 @@ func (op *AtomicWriteOperation) Exec() (bool, error) {
 
 	attempts := 0
 	for {
-		_, err := op.Backend.Client.TransactWriteItems(input)
+		_, err := op.Backend.client().TransactWriteItemsWithContext(op.Backend.context(), input)
 		if err == nil {
 			return true, nil
 		}
@@ -93,29 +273,46 @@ func (op *AtomicWriteOperation) Exec() (bool, error) {
 			// Internal errors tend to happen if the database was recently recreated. We should
 			// retry the request a few times.
 			attempts++
-			time.Sleep(time.Duration(attempts*attempts) * 100 * time.Millisecond)
+			select {
+			case <-time.After(time.Duration(attempts*attempts) * 100 * time.Millisecond):
+			case <-op.Backend.context().Done():
+				return false, op.Backend.context().Err()
+			}
 			continue
 		}
 
 		// The documentation says "TransactionCancelledException", but the API returns
 		// "TransactionCanceledException"...
 		if err.Code() != "TransactionCancelledException" && err.Code() != "TransactionCanceledException" {
+			if kvsErr := keyvaluestoreError(err); kvsErr != nil {
+				return false, kvsErr
+			}
 			return false, err
 		}
 
 		hasErr := false
 		hasConditionalCheckFailed := false
+		var kvsErr error
 		for i, err := range err.CancellationReasons() {
 			op.results[i].err = err
 			if err != nil {
-				if err.Code() != "ConditionalCheckFailed" {
-					hasErr = true
-				} else {
+				switch err.Code() {
+				case "ConditionalCheckFailed":
 					hasConditionalCheckFailed = true
+				case "TransactionConflict":
+					hasErr = true
+					kvsErr = keyvaluestore.ErrContention
+				case "ThrottlingError", "ProvisionedThroughputExceeded":
+					hasErr = true
+					kvsErr = keyvaluestore.ErrThrottled
+				default:
+					hasErr = true
 				}
 			}
 		}
-		if hasErr || !hasConditionalCheckFailed {
+		if kvsErr != nil {
+			return false, kvsErr
+		} else if hasErr || !hasConditionalCheckFailed {
 			return false, err
 		}
 
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
@@ -46,8 +47,10 @@ func (err *transactWriteErr) CancellationReasons() []awserr.Error {
 	return err.cancellationReasons
 }
 
-func (c *AWSBackendClient) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, TransactWriteErr) {
+func (c *AWSBackendClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, TransactWriteErr) {
 	req, output := c.DynamoDBAPI.TransactWriteItemsRequest(input)
+	req.SetContext(ctx)
+	req.ApplyOptions(opts...)
 
 	if !req.Handlers.UnmarshalError.SwapNamed(request.NamedHandler{
 		Name: jsonrpc.UnmarshalErrorHandler.Name,
@@ -1,25 +1,114 @@
 package dynamodbstore
 
 import (
+	"context"
 	"encoding"
+	"encoding/base64"
 	"encoding/binary"
 	"fmt"
 	"math"
+	"math/rand"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/pkg/errors"
 
 	"github.com/theaaf/keyvaluestore"
 )
 
+// Backend stores keys as DynamoDB items. Expiring keys hold their expiration as a Unix timestamp
+// in the "ex" attribute. Expired items are ignored on read, but you can enable DynamoDB's Time to
+// Live feature on that attribute to have them deleted automatically.
 type Backend struct {
 	Client                         BackendClient
 	TableName                      string
 	AllowEventuallyConsistentReads bool
+
+	// The clock used to determine whether items have expired. If nil, the system clock is used.
+	Clock keyvaluestore.Clock
+
+	// Determines how throttled requests, unprocessed batch items, and contentious operations such as
+	// ZIncrBy are retried. If nil, keyvaluestore.DefaultRetryPolicy is used.
+	RetryPolicy *keyvaluestore.RetryPolicy
+
+	ctx context.Context
+
+	// The profiler added by WithContext, if any, so that it isn't added twice.
+	contextProfiler Profiler
+}
+
+// Returns a backend that makes its requests using the given context, allowing them to be cancelled.
+// If the context was created by ContextWithProfiler, the backend also reports to its profiler.
+func (b *Backend) WithContext(ctx context.Context) keyvaluestore.Backend {
+	ret := *b
+	ret.ctx = ctx
+	if profiler, ok := ctx.Value(profilerContextKey{}).(Profiler); ok && profiler != b.contextProfiler {
+		ret.Client = &ProfilingBackendClient{
+			Client:   b.Client,
+			Profiler: profiler,
+		}
+		ret.contextProfiler = profiler
+	}
+	return &ret
+}
+
+func (b *Backend) context() aws.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
+func (b *Backend) client() BackendClient {
+	return &retryingBackendClient{
+		Client: b.Client,
+		Policy: b.RetryPolicy,
+	}
+}
+
+func (b *Backend) now() time.Time {
+	if b.Clock == nil {
+		return keyvaluestore.SystemClock.Now()
+	}
+	return b.Clock.Now()
+}
+
+// DynamoDB's Time to Live feature requires expirations to be in seconds, so they're rounded up.
+func expirationAttributeValue(t time.Time) *dynamodb.AttributeValue {
+	sec := t.Unix()
+	if t.Nanosecond() > 0 {
+		sec++
+	}
+	return attributeValue(sec)
+}
+
+// The condition under which an item hasn't expired. It uses the ":now" value.
+const notExpiredCondition = "(attribute_not_exists(ex) OR ex > :now)"
+
+func (b *Backend) nowAttributeValue() *dynamodb.AttributeValue {
+	return attributeValue(b.now().Unix())
+}
+
+func itemExpiration(item map[string]*dynamodb.AttributeValue) *time.Time {
+	if item == nil || item["ex"] == nil || item["ex"].N == nil {
+		return nil
+	}
+	sec, err := strconv.ParseInt(*item["ex"].N, 10, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(sec, 0)
+	return &t
+}
+
+func (b *Backend) isExpired(item map[string]*dynamodb.AttributeValue) bool {
+	if expiration := itemExpiration(item); expiration != nil {
+		return expiration.Unix() <= b.now().Unix()
+	}
+	return false
 }
 
 func (b *Backend) WithProfiler(profiler Profiler) *Backend {
@@ -43,6 +132,33 @@ func (b *Backend) AtomicWrite() keyvaluestore.AtomicWriteOperation {
 	}
 }
 
+func (b *Backend) AtomicRead() keyvaluestore.AtomicReadOperation {
+	return &AtomicReadOperation{
+		Backend: b,
+	}
+}
+
+// DynamoDB's request limits.
+const (
+	maxTransactionItems = 100
+	maxBatchGetItems    = 100
+	maxBatchWriteItems  = 25
+	maxItemSize         = 400 * 1024
+)
+
+func (b *Backend) Capabilities() keyvaluestore.Capabilities {
+	return keyvaluestore.Capabilities{
+		MaxAtomicWriteOperations:  maxTransactionItems,
+		MaxAtomicReadOperations:   maxTransactionItems,
+		MaxBatchReadSize:          maxBatchGetItems,
+		MaxBatchWriteSize:         maxBatchWriteItems,
+		MaxValueSize:              maxItemSize,
+		EventuallyConsistentReads: b.AllowEventuallyConsistentReads,
+		TTL:                       true,
+		Scan:                      true,
+	}
+}
+
 func (b *Backend) Batch() keyvaluestore.BatchOperation {
 	return &BatchOperation{
 		FallbackBatchOperation: &keyvaluestore.FallbackBatchOperation{
@@ -77,34 +193,65 @@ func attributeValue(v interface{}) *dynamodb.AttributeValue {
 }
 
 func (b *Backend) AddInt(key string, n int64) (int64, error) {
-	result, err := b.Client.UpdateItem(&dynamodb.UpdateItemInput{
-		Key:              compositeKey(key, "_"),
-		TableName:        aws.String(b.TableName),
-		UpdateExpression: aws.String("ADD v :n"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":n": attributeValue(n),
-		},
-		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	var ret int64
+
+	err := b.runContentiousMethod(func() (bool, error) {
+		now := b.nowAttributeValue()
+
+		result, err := b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
+			Key:                 compositeKey(key, "_"),
+			TableName:           aws.String(b.TableName),
+			UpdateExpression:    aws.String("ADD v :n"),
+			ConditionExpression: aws.String(notExpiredCondition),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":n":   attributeValue(n),
+				":now": now,
+			},
+			ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+		})
+		if err == nil {
+			if v := result.Attributes["v"].N; v != nil {
+				ret, err = strconv.ParseInt(*v, 10, 64)
+				return err == nil, err
+			}
+			return false, fmt.Errorf("update item output is missing updated value")
+		} else if err, ok := err.(awserr.Error); !ok || err.Code() != "ConditionalCheckFailedException" {
+			return false, wrapError(err, "dynamodb update item request error")
+		}
+
+		// The item has expired, so replace it as if it didn't exist.
+		if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
+			TableName: aws.String(b.TableName),
+			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
+				"v": attributeValue(n),
+			}),
+			ConditionExpression: aws.String("ex <= :now"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":now": now,
+			},
+		}); err != nil {
+			if err := err.(awserr.Error); err != nil && err.Code() == "ConditionalCheckFailedException" {
+				return false, nil
+			}
+			return false, wrapError(err, "dynamodb put item request error")
+		}
+		ret = n
+		return true, nil
 	})
-	if err != nil {
-		return 0, errors.Wrap(err, "dynamodb update item request error")
-	}
-	if v := result.Attributes["v"].N; v != nil {
-		return strconv.ParseInt(*v, 10, 64)
-	}
-	return 0, fmt.Errorf("update item output is missing updated value")
+
+	return ret, err
 }
 
 func (b *Backend) Delete(key string) (bool, error) {
-	result, err := b.Client.DeleteItem(&dynamodb.DeleteItemInput{
+	result, err := b.client().DeleteItemWithContext(b.context(), &dynamodb.DeleteItemInput{
 		Key:          compositeKey(key, "_"),
 		TableName:    aws.String(b.TableName),
 		ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
 	})
 	if err != nil {
-		return false, errors.Wrap(err, "dynamodb delete item request error")
+		return false, wrapError(err, "dynamodb delete item request error")
 	}
-	return result.Attributes != nil, nil
+	return result.Attributes != nil && !b.isExpired(result.Attributes), nil
 }
 
 func attributeStringValue(v *dynamodb.AttributeValue) *string {
@@ -136,15 +283,15 @@ func attributeStringSliceValue(v *dynamodb.AttributeValue) []string {
 }
 
 func (b *Backend) Get(key string) (*string, error) {
-	result, err := b.Client.GetItem(&dynamodb.GetItemInput{
+	result, err := b.client().GetItemWithContext(b.context(), &dynamodb.GetItemInput{
 		Key:            compositeKey(key, "_"),
 		TableName:      aws.String(b.TableName),
 		ConsistentRead: aws.Bool(!b.AllowEventuallyConsistentReads),
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "dynamodb get item request error")
+		return nil, wrapError(err, "dynamodb get item request error")
 	}
-	if result.Item == nil || result.Item["v"] == nil {
+	if result.Item == nil || result.Item["v"] == nil || b.isExpired(result.Item) {
 		return nil, nil
 	}
 	return attributeStringValue(result.Item["v"]), nil
@@ -170,17 +317,79 @@ func newItem(key, sort string, attrs map[string]*dynamodb.AttributeValue) map[st
 }
 
 func (b *Backend) Set(key string, value interface{}) error {
-	if _, err := b.Client.PutItem(&dynamodb.PutItemInput{
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
 		TableName: aws.String(b.TableName),
 		Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
 			"v": attributeValue(value),
 		}),
 	}); err != nil {
-		return errors.Wrap(err, "dynamodb put item request error")
+		return wrapError(err, "dynamodb put item request error")
+	}
+	return nil
+}
+
+func (b *Backend) SetEX(key string, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl: %v", ttl)
+	}
+
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
+		TableName: aws.String(b.TableName),
+		Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
+			"v":  attributeValue(value),
+			"ex": expirationAttributeValue(b.now().Add(ttl)),
+		}),
+	}); err != nil {
+		return wrapError(err, "dynamodb put item request error")
 	}
 	return nil
 }
 
+func (b *Backend) Expire(key string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, fmt.Errorf("invalid ttl: %v", ttl)
+	}
+
+	now := b.now()
+	if _, err := b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
+		Key:                 compositeKey(key, "_"),
+		TableName:           aws.String(b.TableName),
+		UpdateExpression:    aws.String("SET ex = :ex"),
+		ConditionExpression: aws.String("attribute_exists(v) AND " + notExpiredCondition),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":ex":  expirationAttributeValue(now.Add(ttl)),
+			":now": attributeValue(now.Unix()),
+		},
+	}); err != nil {
+		if err := err.(awserr.Error); err != nil && err.Code() == "ConditionalCheckFailedException" {
+			return false, nil
+		}
+		return false, wrapError(err, "dynamodb update item request error")
+	}
+	return true, nil
+}
+
+// Expirations are stored with one-second precision, so the returned duration may be up to a second
+// longer than the one given to SetEX or Expire.
+func (b *Backend) TTL(key string) (*time.Duration, error) {
+	result, err := b.client().GetItemWithContext(b.context(), &dynamodb.GetItemInput{
+		Key:            compositeKey(key, "_"),
+		TableName:      aws.String(b.TableName),
+		ConsistentRead: aws.Bool(!b.AllowEventuallyConsistentReads),
+	})
+	if err != nil {
+		return nil, wrapError(err, "dynamodb get item request error")
+	}
+	if result.Item == nil || result.Item["v"] == nil || b.isExpired(result.Item) {
+		return nil, nil
+	}
+	if expiration := itemExpiration(result.Item); expiration != nil {
+		ttl := expiration.Sub(b.now())
+		return &ttl, nil
+	}
+	return nil, nil
+}
+
 func (b *Backend) SetNX(key string, value interface{}) (bool, error) {
 	return b.setNX(key, "_", map[string]*dynamodb.AttributeValue{"v": attributeValue(value)})
 }
@@ -192,39 +401,52 @@ func (b *Backend) setNX(key string, sortKey string, valueMap map[string]*dynamod
 		conditions = append(conditions, fmt.Sprintf("attribute_not_exists(%s)", k))
 	}
 
-	if _, err := b.Client.PutItem(&dynamodb.PutItemInput{
+	// Expired items are treated as if they don't exist.
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
 		TableName:           aws.String(b.TableName),
 		Item:                newItem(key, sortKey, valueMap),
-		ConditionExpression: aws.String(strings.Join(conditions, " and ")),
+		ConditionExpression: aws.String("(" + strings.Join(conditions, " and ") + ") or ex <= :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": b.nowAttributeValue(),
+		},
 	}); err != nil {
 		if err := err.(awserr.Error); err != nil && err.Code() == "ConditionalCheckFailedException" {
 			return false, nil
 		}
-		return false, errors.Wrap(err, "dynamodb put item request error")
+		return false, wrapError(err, "dynamodb put item request error")
 	}
 	return true, nil
 }
 
 func (b *Backend) SetXX(key string, value interface{}) (bool, error) {
-	if _, err := b.Client.PutItem(&dynamodb.PutItemInput{
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
 		TableName: aws.String(b.TableName),
 		Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
 			"v": attributeValue(value),
 		}),
-		ConditionExpression: aws.String("attribute_exists(v)"),
+		ConditionExpression: aws.String("attribute_exists(v) AND " + notExpiredCondition),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": b.nowAttributeValue(),
+		},
 	}); err != nil {
 		if err := err.(awserr.Error); err != nil && err.Code() == "ConditionalCheckFailedException" {
 			return false, nil
 		}
-		return false, errors.Wrap(err, "dynamodb put item request error")
+		return false, wrapError(err, "dynamodb put item request error")
 	}
 	return true, nil
 }
 
-func setKey(key string, bucket int) map[string]*dynamodb.AttributeValue {
+func setRangeKey(bucket int) string {
 	var buf [binary.MaxVarintLen64]byte
 	n := binary.PutVarint(buf[:], int64(bucket))
-	return compositeKey(key, string(buf[:n]))
+	return string(buf[:n])
+}
+
+var firstSetRangeKey = setRangeKey(0)
+
+func setKey(key string, bucket int) map[string]*dynamodb.AttributeValue {
+	return compositeKey(key, setRangeKey(bucket))
 }
 
 func (b *Backend) SAdd(key string, member interface{}, members ...interface{}) error {
@@ -253,20 +475,20 @@ func (b *Backend) SAdd(key string, member interface{}, members ...interface{}) e
 			input.ConditionExpression = aws.String("attribute_exists(c)")
 		}
 
-		_, err := b.Client.UpdateItem(input)
+		_, err := b.client().UpdateItemWithContext(b.context(), input)
 		if err == nil {
 			return nil
 		}
 
 		awserr, ok := err.(awserr.Error)
 		if !ok {
-			return errors.Wrap(err, "dynamodb update item request error")
+			return wrapError(err, "dynamodb update item request error")
 		}
 		code := awserr.Code()
 
 		if code == "ConditionalCheckFailedException" {
 			// Create a new item, then try again.
-			if _, err := b.Client.UpdateItem(&dynamodb.UpdateItemInput{
+			if _, err := b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
 				Key:              setKey(key, i-1),
 				TableName:        aws.String(b.TableName),
 				UpdateExpression: aws.String("SET c = :c"),
@@ -276,10 +498,10 @@ func (b *Backend) SAdd(key string, member interface{}, members ...interface{}) e
 					},
 				},
 			}); err != nil {
-				return errors.Wrap(err, "update item request error")
+				return wrapError(err, "update item request error")
 			}
 
-			if _, err = b.Client.UpdateItem(&dynamodb.UpdateItemInput{
+			if _, err = b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
 				Key:              setKey(key, i),
 				TableName:        aws.String(b.TableName),
 				UpdateExpression: aws.String("SET c = :c"),
@@ -289,13 +511,13 @@ func (b *Backend) SAdd(key string, member interface{}, members ...interface{}) e
 					},
 				},
 			}); err != nil {
-				return errors.Wrap(err, "update item request error")
+				return wrapError(err, "update item request error")
 			}
 		} else if code == "ValidationException" && strings.Contains(awserr.Message(), "size") {
 			// Try the next item.
 			i++
 		} else {
-			return errors.Wrap(err, "dynamodb update item request error")
+			return wrapError(err, "dynamodb update item request error")
 		}
 	}
 }
@@ -308,7 +530,7 @@ func (b *Backend) SRem(key string, member interface{}, members ...interface{}) e
 	}
 
 	for i := 0; ; i++ {
-		result, err := b.Client.UpdateItem(&dynamodb.UpdateItemInput{
+		result, err := b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
 			Key:              setKey(key, i),
 			TableName:        aws.String(b.TableName),
 			UpdateExpression: aws.String("DELETE v :v"),
@@ -320,7 +542,7 @@ func (b *Backend) SRem(key string, member interface{}, members ...interface{}) e
 			ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
 		})
 		if err != nil {
-			return errors.Wrap(err, "dynamodb update item request error")
+			return wrapError(err, "dynamodb update item request error")
 		}
 		if result.Attributes == nil || result.Attributes["c"].BOOL == nil || !*result.Attributes["c"].BOOL {
 			return nil
@@ -344,9 +566,9 @@ func (b *Backend) SMembers(key string) ([]string, error) {
 			},
 			ExclusiveStartKey: startKey,
 		}
-		result, err := b.Client.Query(input)
+		result, err := b.client().QueryWithContext(b.context(), input)
 		if err != nil {
-			return nil, errors.Wrap(err, "dynamodb query request error")
+			return nil, wrapError(err, "dynamodb query request error")
 		}
 		for _, item := range result.Items {
 			if len(members) > 0 {
@@ -375,6 +597,111 @@ func (b *Backend) SMembers(key string) ([]string, error) {
 	return members, nil
 }
 
+// Returns the range key of the first set item containing the member, or nil if no item contains it.
+func (b *Backend) findSetMember(key, member string) (*string, error) {
+	var startKey map[string]*dynamodb.AttributeValue
+
+	for {
+		result, err := b.client().QueryWithContext(b.context(), &dynamodb.QueryInput{
+			TableName:              aws.String(b.TableName),
+			ConsistentRead:         aws.Bool(!b.AllowEventuallyConsistentReads),
+			KeyConditionExpression: aws.String("hk = :hash"),
+			FilterExpression:       aws.String("contains(v, :m)"),
+			ProjectionExpression:   aws.String("rk"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":hash": attributeValue(key),
+				":m":    attributeValue(member),
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, wrapError(err, "dynamodb query request error")
+		}
+		if len(result.Items) > 0 {
+			return attributeStringValue(result.Items[0]["rk"]), nil
+		}
+		if result.LastEvaluatedKey == nil {
+			return nil, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// SIsMember queries the set's items with a filter for the member. DynamoDB reads, and bills for,
+// every item it filters, so this costs as much as SMembers unless the member is found early.
+func (b *Backend) SIsMember(key string, member interface{}) (bool, error) {
+	rangeKey, err := b.findSetMember(key, *keyvaluestore.ToString(member))
+	return rangeKey != nil, err
+}
+
+// Set items may share members, so the cardinality can only be determined by reading them. This costs
+// as much as SMembers.
+func (b *Backend) SCard(key string) (int, error) {
+	members, err := b.SMembers(key)
+	return len(members), err
+}
+
+// SRandMember reads the whole set, so it costs as much as SMembers.
+func (b *Backend) SRandMember(key string) (*string, error) {
+	members, err := b.SMembers(key)
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	return &members[rand.Intn(len(members))], nil
+}
+
+// SPop picks a random member, then removes it from the item containing it with a condition that
+// ensures no concurrent pop claimed it first.
+func (b *Backend) SPop(key string) (*string, error) {
+	var popped *string
+
+	err := b.runContentiousMethod(func() (bool, error) {
+		member, err := b.SRandMember(key)
+		if err != nil || member == nil {
+			return true, err
+		}
+
+		rangeKey, err := b.findSetMember(key, *member)
+		if err != nil {
+			return false, err
+		} else if rangeKey == nil {
+			return false, nil
+		}
+
+		result, err := b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
+			Key:                 compositeKey(key, *rangeKey),
+			TableName:           aws.String(b.TableName),
+			UpdateExpression:    aws.String("DELETE v :v"),
+			ConditionExpression: aws.String("contains(v, :m)"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":v": &dynamodb.AttributeValue{
+					BS: [][]byte{[]byte(*member)},
+				},
+				":m": attributeValue(*member),
+			},
+			ReturnValues: aws.String(dynamodb.ReturnValueAllOld),
+		})
+		if err != nil {
+			if awserr, ok := err.(awserr.Error); ok && awserr.Code() == "ConditionalCheckFailedException" {
+				return false, nil
+			}
+			return false, wrapError(err, "dynamodb update item request error")
+		}
+
+		if *rangeKey != firstSetRangeKey || (result.Attributes["c"] != nil && result.Attributes["c"].BOOL != nil && *result.Attributes["c"].BOOL) {
+			// The set spans multiple items, so other items may contain the member too.
+			if err := b.SRem(key, *member); err != nil {
+				return false, err
+			}
+		}
+
+		popped = member
+		return true, nil
+	})
+
+	return popped, err
+}
+
 const floatSortKeyNumBytes = 8
 
 func floatSortKey(f float64) string {
@@ -420,27 +747,27 @@ func floatSortKeyAfter(f float64) string {
 
 func (b *Backend) ZAdd(key string, member interface{}, score float64) error {
 	s := *keyvaluestore.ToString(member)
-	if _, err := b.Client.PutItem(&dynamodb.PutItemInput{
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
 		TableName: aws.String(b.TableName),
 		Item: newItem(key, s, map[string]*dynamodb.AttributeValue{
 			"v":   attributeValue(s),
 			"rk2": attributeValue(floatSortKey(score) + s),
 		}),
 	}); err != nil {
-		return errors.Wrap(err, "dynamodb put item request error")
+		return wrapError(err, "dynamodb put item request error")
 	}
 	return nil
 }
 
 func (b *Backend) ZScore(key string, member interface{}) (*float64, error) {
 	s := *keyvaluestore.ToString(member)
-	result, err := b.Client.GetItem(&dynamodb.GetItemInput{
+	result, err := b.client().GetItemWithContext(b.context(), &dynamodb.GetItemInput{
 		Key:            compositeKey(key, s),
 		TableName:      aws.String(b.TableName),
 		ConsistentRead: aws.Bool(!b.AllowEventuallyConsistentReads),
 	})
 	if err != nil {
-		return nil, errors.Wrap(err, "dynamodb get item request error")
+		return nil, wrapError(err, "dynamodb get item request error")
 	}
 	if result.Item != nil {
 		if rk2 := attributeStringValue(result.Item["rk2"]); rk2 != nil {
@@ -454,7 +781,7 @@ func (b *Backend) ZScore(key string, member interface{}) (*float64, error) {
 func (b *Backend) ZIncrBy(key string, member string, n float64) (float64, error) {
 	var retValue float64
 
-	err := runContentiousMethod(func() (bool, error) {
+	err := b.runContentiousMethod(func() (bool, error) {
 		var newValue float64
 
 		s := *keyvaluestore.ToString(member)
@@ -472,12 +799,10 @@ func (b *Backend) ZIncrBy(key string, member string, n float64) (float64, error)
 
 		if err != nil {
 			return false, err
-		} else if !success {
-			return false, fmt.Errorf("unable to increment due to contention")
 		}
 
 		retValue = newValue
-		return true, nil
+		return success, nil
 	})
 
 	if err != nil {
@@ -489,11 +814,11 @@ func (b *Backend) ZIncrBy(key string, member string, n float64) (float64, error)
 
 func (b *Backend) ZRem(key string, member interface{}) error {
 	s := *keyvaluestore.ToString(member)
-	if _, err := b.Client.DeleteItem(&dynamodb.DeleteItemInput{
+	if _, err := b.client().DeleteItemWithContext(b.context(), &dynamodb.DeleteItemInput{
 		TableName: aws.String(b.TableName),
 		Key:       compositeKey(key, s),
 	}); err != nil {
-		return errors.Wrap(err, "dynamodb delete item request error")
+		return wrapError(err, "dynamodb delete item request error")
 	}
 	return nil
 }
@@ -515,6 +840,62 @@ func (b *Backend) ZCount(key string, min, max float64) (int, error) {
 	return b.zCount(key, minSortKey, maxSortKey, true)
 }
 
+// Like minMaxFloatSortKeys, but for bounds that may be exclusive. Returns false if nothing can be
+// in the range.
+func scoreRangeSortKeys(min, max keyvaluestore.ScoreBound) (string, string, bool) {
+	minSortKey := "[" + floatSortKey(min.Score)
+	if min.Exclusive {
+		after := floatSortKeyAfter(min.Score)
+		if after == "" {
+			return "", "", false
+		}
+		minSortKey = "[" + after
+	} else if min.Score == math.Inf(-1) {
+		minSortKey = "-"
+	}
+
+	maxSortKey := "(" + floatSortKey(max.Score)
+	if !max.Exclusive {
+		if maxSortKey = "(" + floatSortKeyAfter(max.Score); maxSortKey == "(" {
+			maxSortKey = "+"
+		}
+	}
+	return minSortKey, maxSortKey, true
+}
+
+func (b *Backend) ZRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	return b.zRangeByScoreRange(key, r, false)
+}
+
+func (b *Backend) ZRevRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	return b.zRangeByScoreRange(key, r, true)
+}
+
+// DynamoDB can't skip items, so the offset is applied after querying.
+func (b *Backend) zRangeByScoreRange(key string, r keyvaluestore.ScoreRange, reverse bool) (keyvaluestore.ScoredMembers, error) {
+	minSortKey, maxSortKey, ok := scoreRangeSortKeys(r.Min, r.Max)
+	if !ok {
+		return nil, nil
+	}
+	limit := r.Limit
+	if limit > 0 {
+		limit += r.Offset
+	}
+	members, err := b.zRangeByLex(key, minSortKey, maxSortKey, limit, reverse, true)
+	if err != nil || len(members) <= r.Offset {
+		return nil, err
+	}
+	return members[r.Offset:], nil
+}
+
+func (b *Backend) ZCountRange(key string, min, max keyvaluestore.ScoreBound) (int, error) {
+	minSortKey, maxSortKey, ok := scoreRangeSortKeys(min, max)
+	if !ok {
+		return 0, nil
+	}
+	return b.zCount(key, minSortKey, maxSortKey, true)
+}
+
 func (b *Backend) ZLexCount(key, min, max string) (int, error) {
 	return b.zCount(key, min, max, false)
 }
@@ -555,9 +936,9 @@ func (b *Backend) zCount(key string, min, max string, secondaryIndex bool) (int,
 	if secondaryIndex {
 		input.IndexName = aws.String("rk2")
 	}
-	result, err := b.Client.Query(input)
+	result, err := b.client().QueryWithContext(b.context(), input)
 	if err != nil {
-		return 0, errors.Wrap(err, "dynamodb query request error")
+		return 0, wrapError(err, "dynamodb query request error")
 	}
 	if result.Count == nil {
 		return 0, fmt.Errorf("no count returned by dynamodb query")
@@ -603,6 +984,207 @@ func (b *Backend) ZRevRangeByLex(key string, min, max string, limit int) ([]stri
 	return members.Values(), err
 }
 
+func (b *Backend) ZCard(key string) (int, error) {
+	return b.zQueryCount("hk = :hash", map[string]*dynamodb.AttributeValue{
+		":hash": attributeValue(key),
+	})
+}
+
+func (b *Backend) ZRank(key string, member interface{}) (*int, error) {
+	return b.zRank(key, member, false)
+}
+
+func (b *Backend) ZRevRank(key string, member interface{}) (*int, error) {
+	return b.zRank(key, member, true)
+}
+
+// The rank of a member is the number of members on the rk2 index that sort before it.
+func (b *Backend) zRank(key string, member interface{}, reverse bool) (*int, error) {
+	s := *keyvaluestore.ToString(member)
+	result, err := b.client().GetItemWithContext(b.context(), &dynamodb.GetItemInput{
+		Key:            compositeKey(key, s),
+		TableName:      aws.String(b.TableName),
+		ConsistentRead: aws.Bool(!b.AllowEventuallyConsistentReads),
+	})
+	if err != nil {
+		return nil, wrapError(err, "dynamodb get item request error")
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	rk2 := attributeStringValue(result.Item["rk2"])
+	if rk2 == nil {
+		return nil, nil
+	}
+
+	condition := "hk = :hash AND rk2 < :rk2"
+	if reverse {
+		condition = "hk = :hash AND rk2 > :rk2"
+	}
+	rank, err := b.zQueryCount(condition, map[string]*dynamodb.AttributeValue{
+		":hash": attributeValue(key),
+		":rk2":  attributeValue(*rk2),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rank, nil
+}
+
+// Counts the items on the rk2 index matching the given key condition, following pagination.
+func (b *Backend) zQueryCount(condition string, attributeValues map[string]*dynamodb.AttributeValue) (int, error) {
+	var startKey map[string]*dynamodb.AttributeValue
+	count := 0
+
+	for {
+		result, err := b.client().QueryWithContext(b.context(), &dynamodb.QueryInput{
+			TableName:                 aws.String(b.TableName),
+			IndexName:                 aws.String("rk2"),
+			ConsistentRead:            aws.Bool(!b.AllowEventuallyConsistentReads),
+			KeyConditionExpression:    aws.String(condition),
+			ExpressionAttributeValues: attributeValues,
+			ExclusiveStartKey:         startKey,
+			Select:                    aws.String(dynamodb.SelectCount),
+		})
+		if err != nil {
+			return 0, wrapError(err, "dynamodb query request error")
+		}
+		if result.Count == nil {
+			return 0, fmt.Errorf("no count returned by dynamodb query")
+		}
+		count += int(*result.Count)
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		startKey = result.LastEvaluatedKey
+	}
+
+	return count, nil
+}
+
+func (b *Backend) ZRange(key string, start, stop int) ([]string, error) {
+	members, err := b.zRange(key, start, stop, false)
+	return members.Values(), err
+}
+
+func (b *Backend) ZRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	return b.zRange(key, start, stop, false)
+}
+
+func (b *Backend) ZRevRange(key string, start, stop int) ([]string, error) {
+	members, err := b.zRange(key, start, stop, true)
+	return members.Values(), err
+}
+
+func (b *Backend) ZRevRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	return b.zRange(key, start, stop, true)
+}
+
+func (b *Backend) zRange(key string, start, stop int, reverse bool) (keyvaluestore.ScoredMembers, error) {
+	if start < 0 || stop < 0 {
+		// Negative ranks are relative to the end, so we need to know the cardinality first.
+		card, err := b.ZCard(key)
+		if err != nil {
+			return nil, err
+		}
+		if start < 0 {
+			start += card
+			if start < 0 {
+				start = 0
+			}
+		}
+		if stop < 0 {
+			stop += card
+		}
+	}
+	if start > stop {
+		return nil, nil
+	}
+
+	members, err := b.zRangeByLex(key, "-", "+", stop+1, reverse, true)
+	if err != nil || len(members) <= start {
+		return nil, err
+	}
+	return members[start:], nil
+}
+
+// Range cursors hold the range key of the last evaluated item: rk2 for score ranges, or rk for
+// lexicographical ranges.
+func encodeZRangeCursor(lastEvaluatedKey map[string]*dynamodb.AttributeValue, secondaryIndex bool) string {
+	if lastEvaluatedKey == nil {
+		return ""
+	}
+	rangeKey := lastEvaluatedKey["rk"].B
+	if secondaryIndex {
+		rangeKey = lastEvaluatedKey["rk2"].B
+	}
+	return base64.RawURLEncoding.EncodeToString(rangeKey)
+}
+
+func decodeZRangeCursor(key, cursor string, secondaryIndex bool) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	rangeKey, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || (secondaryIndex && len(rangeKey) < floatSortKeyNumBytes) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if !secondaryIndex {
+		return compositeKey(key, string(rangeKey)), nil
+	}
+	startKey := compositeKey(key, string(rangeKey[floatSortKeyNumBytes:]))
+	startKey["rk2"] = attributeValue(rangeKey)
+	return startKey, nil
+}
+
+func (b *Backend) ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	minSortKey, maxSortKey := minMaxFloatSortKeys(min, max)
+	return b.zRangePage(key, minSortKey, maxSortKey, cursor, limit, false)
+}
+
+func (b *Backend) ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	minSortKey, maxSortKey := minMaxFloatSortKeys(min, max)
+	return b.zRangePage(key, minSortKey, maxSortKey, cursor, limit, true)
+}
+
+func (b *Backend) zRangePage(key, min, max, cursor string, limit int, reverse bool) (*keyvaluestore.ZRangePage, error) {
+	startKey, err := decodeZRangeCursor(key, cursor, true)
+	if err != nil {
+		return nil, err
+	}
+	members, lastEvaluatedKey, err := b.zRangeByLexPage(key, min, max, startKey, limit, reverse, true)
+	if err != nil {
+		return nil, err
+	}
+	return &keyvaluestore.ZRangePage{
+		Members: members,
+		Cursor:  encodeZRangeCursor(lastEvaluatedKey, true),
+	}, nil
+}
+
+func (b *Backend) ZRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	return b.zLexRangePage(key, min, max, cursor, limit, false)
+}
+
+func (b *Backend) ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	return b.zLexRangePage(key, min, max, cursor, limit, true)
+}
+
+func (b *Backend) zLexRangePage(key, min, max, cursor string, limit int, reverse bool) (*keyvaluestore.ZLexRangePage, error) {
+	startKey, err := decodeZRangeCursor(key, cursor, false)
+	if err != nil {
+		return nil, err
+	}
+	members, lastEvaluatedKey, err := b.zRangeByLexPage(key, min, max, startKey, limit, reverse, false)
+	if err != nil {
+		return nil, err
+	}
+	return &keyvaluestore.ZLexRangePage{
+		Members: members.Values(),
+		Cursor:  encodeZRangeCursor(lastEvaluatedKey, false),
+	}, nil
+}
+
 func queryCondition(key, min, max string, secondaryIndex bool) (string, map[string]*dynamodb.AttributeValue) {
 	minSort := min[1:]
 	maxSort := max[1:]
@@ -636,12 +1218,17 @@ func queryCondition(key, min, max string, secondaryIndex bool) (string, map[stri
 	return condition, attributeValues
 }
 
-func (b *Backend) zRangeByLex(key, min, max string, limit int, reverse, secondaryIndex bool) (members keyvaluestore.ScoredMembers, err error) {
-	var startKey map[string]*dynamodb.AttributeValue
+func (b *Backend) zRangeByLex(key, min, max string, limit int, reverse, secondaryIndex bool) (keyvaluestore.ScoredMembers, error) {
+	members, _, err := b.zRangeByLexPage(key, min, max, nil, limit, reverse, secondaryIndex)
+	return members, err
+}
 
+// Queries a range of members starting after startKey, if given. If the query stops before
+// reaching the end of the range, the returned key is the last item evaluated.
+func (b *Backend) zRangeByLexPage(key, min, max string, startKey map[string]*dynamodb.AttributeValue, limit int, reverse, secondaryIndex bool) (members keyvaluestore.ScoredMembers, lastEvaluatedKey map[string]*dynamodb.AttributeValue, err error) {
 	condition, attributeValues := queryCondition(key, min, max, secondaryIndex)
 	if condition == "" {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	rangeKey := "rk"
@@ -664,9 +1251,9 @@ func (b *Backend) zRangeByLex(key, min, max string, limit int, reverse, secondar
 		if limit > 0 {
 			input.Limit = aws.Int64(int64(limit - len(members)))
 		}
-		result, err := b.Client.Query(input)
+		result, err := b.client().QueryWithContext(b.context(), input)
 		if err != nil {
-			return nil, errors.Wrap(err, "dynamodb query request error")
+			return nil, nil, wrapError(err, "dynamodb query request error")
 		}
 		for _, item := range result.Items {
 			sort := *attributeStringValue(item[rangeKey])
@@ -685,12 +1272,121 @@ func (b *Backend) zRangeByLex(key, min, max string, limit int, reverse, secondar
 				Value: *attributeStringValue(item["v"]),
 			})
 		}
+		startKey = result.LastEvaluatedKey
+		if startKey == nil {
+			break
+		}
+	}
+	return members, startKey, nil
+}
+
+// Hashes are stored with one item per field. Their range keys share a key's range key space with
+// the items of the other types, so they're prefixed with a byte that the range keys of plain values
+// and set items never begin with.
+const hashFieldRangeKeyPrefix = "#"
+
+func hashFieldRangeKey(field string) string {
+	return hashFieldRangeKeyPrefix + field
+}
+
+func (b *Backend) HSet(key, field string, value interface{}) error {
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
+		TableName: aws.String(b.TableName),
+		Item: newItem(key, hashFieldRangeKey(field), map[string]*dynamodb.AttributeValue{
+			"v": attributeValue(value),
+		}),
+	}); err != nil {
+		return wrapError(err, "dynamodb put item request error")
+	}
+	return nil
+}
+
+func (b *Backend) HGet(key, field string) (*string, error) {
+	result, err := b.client().GetItemWithContext(b.context(), &dynamodb.GetItemInput{
+		Key:            compositeKey(key, hashFieldRangeKey(field)),
+		TableName:      aws.String(b.TableName),
+		ConsistentRead: aws.Bool(!b.AllowEventuallyConsistentReads),
+	})
+	if err != nil {
+		return nil, wrapError(err, "dynamodb get item request error")
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	return attributeStringValue(result.Item["v"]), nil
+}
+
+func (b *Backend) HMGet(key string, field string, fields ...string) ([]*string, error) {
+	batch := &BatchOperation{
+		Backend: b,
+	}
+	result := batch.HMGet(key, field, fields...)
+	if err := batch.execReads(); err != nil {
+		return nil, err
+	}
+	return result.Result()
+}
+
+func (b *Backend) HDel(key string, field string, fields ...string) error {
+	batch := &BatchOperation{
+		Backend: b,
+	}
+	batch.HDel(key, field, fields...)
+	return batch.execWrites()
+}
+
+func (b *Backend) HGetAll(key string) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	var startKey map[string]*dynamodb.AttributeValue
+
+	for {
+		result, err := b.client().QueryWithContext(b.context(), &dynamodb.QueryInput{
+			TableName:              aws.String(b.TableName),
+			ConsistentRead:         aws.Bool(!b.AllowEventuallyConsistentReads),
+			KeyConditionExpression: aws.String("hk = :hash AND begins_with(rk, :prefix)"),
+			// Sorted set members can begin with the prefix too.
+			FilterExpression: aws.String("attribute_not_exists(rk2)"),
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+				":hash":   attributeValue(key),
+				":prefix": attributeValue(hashFieldRangeKeyPrefix),
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, wrapError(err, "dynamodb query request error")
+		}
+		for _, item := range result.Items {
+			if v := attributeStringValue(item["v"]); v != nil {
+				fields[strings.TrimPrefix(*attributeStringValue(item["rk"]), hashFieldRangeKeyPrefix)] = *v
+			}
+		}
 		if result.LastEvaluatedKey == nil {
 			break
 		}
 		startKey = result.LastEvaluatedKey
 	}
-	return members, nil
+
+	return fields, nil
+}
+
+func (b *Backend) HIncrBy(key, field string, n int64) (int64, error) {
+	result, err := b.client().UpdateItemWithContext(b.context(), &dynamodb.UpdateItemInput{
+		Key:              compositeKey(key, hashFieldRangeKey(field)),
+		TableName:        aws.String(b.TableName),
+		UpdateExpression: aws.String("ADD v :n"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":n": attributeValue(n),
+		},
+		ReturnValues: aws.String(dynamodb.ReturnValueAllNew),
+	})
+	if err != nil {
+		return 0, wrapError(err, "dynamodb update item request error")
+	}
+	if v := result.Attributes["v"].N; v != nil {
+		return strconv.ParseInt(*v, 10, 64)
+	}
+	return 0, fmt.Errorf("update item output is missing updated value")
 }
 
 func (b *Backend) CAS(key string, transform func(prev *string) (interface{}, error)) (bool, error) {
@@ -700,17 +1396,17 @@ func (b *Backend) CAS(key string, transform func(prev *string) (interface{}, err
 func (b *Backend) checkAndSet(key string, sortKey string, attributeToChange string, transform func(prev *string) (interface{}, error), otherValues map[string]interface{}) (bool, error) {
 	compKey := compositeKey(key, sortKey)
 
-	getResult, err := b.Client.GetItem(&dynamodb.GetItemInput{
+	getResult, err := b.client().GetItemWithContext(b.context(), &dynamodb.GetItemInput{
 		Key:            compKey,
 		TableName:      aws.String(b.TableName),
 		ConsistentRead: aws.Bool(true),
 	})
 	if err != nil {
-		return false, errors.Wrap(err, "dynamodb get item request error")
+		return false, wrapError(err, "dynamodb get item request error")
 	}
 
 	var prev *string
-	if getResult.Item != nil {
+	if getResult.Item != nil && !b.isExpired(getResult.Item) {
 		prev = attributeStringValue(getResult.Item[attributeToChange])
 	}
 
@@ -733,7 +1429,7 @@ func (b *Backend) checkAndSet(key string, sortKey string, attributeToChange stri
 		return b.setNX(key, sortKey, attributeValues)
 	}
 
-	if _, err := b.Client.PutItem(&dynamodb.PutItemInput{
+	if _, err := b.client().PutItemWithContext(b.context(), &dynamodb.PutItemInput{
 		TableName:           aws.String(b.TableName),
 		Item:                newItem(key, sortKey, attributeValues),
 		ConditionExpression: aws.String(fmt.Sprintf("%s = :v", attributeToChange)),
@@ -744,23 +1440,124 @@ func (b *Backend) checkAndSet(key string, sortKey string, attributeToChange stri
 		if err := err.(awserr.Error); err != nil && err.Code() == "ConditionalCheckFailedException" {
 			return false, nil
 		}
-		return false, errors.Wrap(err, "dynamodb put item request error")
+		return false, wrapError(err, "dynamodb put item request error")
 	}
 	return true, nil
 }
 
-const contentiousMethodRetries = 3
-
-func runContentiousMethod(f func() (bool, error)) error {
-	for i := 0; i < contentiousMethodRetries; i++ {
+// Calls f until it succeeds, retrying according to the backend's retry policy whenever it loses a
+// race with a concurrent write.
+func (b *Backend) runContentiousMethod(f func() (bool, error)) error {
+	for attempt := 1; ; attempt++ {
 		success, err := f()
 		if err != nil {
 			return err
 		} else if success {
 			return nil
+		} else if !b.RetryPolicy.Retry(b.context(), attempt, keyvaluestore.ErrContention) {
+			return keyvaluestore.ErrContention
 		}
 	}
-	return fmt.Errorf("unable to run method due to contention, tried %d times", contentiousMethodRetries)
+}
+
+// The scan cursor encodes the last evaluated item's key. Because all of a key's items are returned
+// together, the key of that item has already been reported and is skipped on the next page.
+// Cursors hold the last evaluated key, along with whether its hash key has already been returned.
+// A key's items may span pages, and the key should only be returned once, but only if one of its
+// items hasn't expired.
+func encodeScanCursor(lastEvaluatedKey map[string]*dynamodb.AttributeValue, returned bool) string {
+	hashKey := lastEvaluatedKey["hk"].B
+	rangeKey := lastEvaluatedKey["rk"].B
+	buf := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(hashKey)+len(rangeKey))
+	if returned {
+		buf[0] = 1
+	}
+	buf = buf[:1+binary.PutUvarint(buf[1:], uint64(len(hashKey)))]
+	buf = append(buf, hashKey...)
+	buf = append(buf, rangeKey...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeScanCursor(cursor string) (hashKey, rangeKey string, returned bool, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(buf) == 0 || buf[0] > 1 {
+		return "", "", false, fmt.Errorf("invalid cursor")
+	}
+	returned = buf[0] == 1
+	buf = buf[1:]
+	n, size := binary.Uvarint(buf)
+	if size <= 0 || uint64(len(buf)-size) < n {
+		return "", "", false, fmt.Errorf("invalid cursor")
+	}
+	buf = buf[size:]
+	return string(buf[:n]), string(buf[n:]), returned, nil
+}
+
+func itemKeyType(item map[string]*dynamodb.AttributeValue) keyvaluestore.KeyType {
+	switch {
+	case *attributeStringValue(item["rk"]) == "_":
+		return keyvaluestore.KeyTypeString
+	case item["rk2"] != nil:
+		return keyvaluestore.KeyTypeSortedSet
+	case item["c"] != nil:
+		return keyvaluestore.KeyTypeSet
+	}
+	return keyvaluestore.KeyTypeHash
+}
+
+// Scanning reads the entire table, so it should be reserved for things like administrative tools
+// and migrations. The limit is the number of items to evaluate, which may be more than the number
+// of keys.
+func (b *Backend) Scan(prefix, cursor string, limit int) (*keyvaluestore.ScanPage, error) {
+	input := &dynamodb.ScanInput{
+		TableName:      aws.String(b.TableName),
+		ConsistentRead: aws.Bool(!b.AllowEventuallyConsistentReads),
+	}
+	if prefix != "" {
+		input.FilterExpression = aws.String("begins_with(hk, :prefix)")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":prefix": attributeValue(prefix),
+		}
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(int64(limit))
+	}
+
+	// Keys that have already been returned, either by this page or by the previous one.
+	returned := map[string]struct{}{}
+	if cursor != "" {
+		hashKey, rangeKey, ok, err := decodeScanCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		input.ExclusiveStartKey = compositeKey(hashKey, rangeKey)
+		if ok {
+			returned[hashKey] = struct{}{}
+		}
+	}
+
+	result, err := b.client().ScanWithContext(b.context(), input)
+	if err != nil {
+		return nil, wrapError(err, "dynamodb scan request error")
+	}
+
+	page := &keyvaluestore.ScanPage{}
+	for _, item := range result.Items {
+		key := *attributeStringValue(item["hk"])
+		if _, ok := returned[key]; ok || b.isExpired(item) {
+			continue
+		}
+		returned[key] = struct{}{}
+		page.Keys = append(page.Keys, keyvaluestore.ScannedKey{
+			Key:  key,
+			Type: itemKeyType(item),
+		})
+	}
+	if result.LastEvaluatedKey != nil {
+		_, ok := returned[*attributeStringValue(result.LastEvaluatedKey["hk"])]
+		page.Cursor = encodeScanCursor(result.LastEvaluatedKey, ok)
+	}
+	return page, nil
 }
 
 func CreateDefaultTable(client *dynamodb.DynamoDB, tableName string) error {
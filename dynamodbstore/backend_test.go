@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -12,6 +13,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
 
 	"github.com/theaaf/keyvaluestore"
 	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
@@ -98,3 +100,30 @@ func TestBackend(t *testing.T) {
 		return newTestBackend(client, "TestBackend")
 	})
 }
+
+func TestBackendExpiration(t *testing.T) {
+	client, err := newDynamoDBTestClient()
+	if err != nil {
+		t.Fatal(err)
+	} else if client == nil {
+		t.Skip("no dynamodb server available")
+	}
+
+	keyvaluestoretest.TestBackendExpiration(t, func(clock keyvaluestore.Clock) keyvaluestore.Backend {
+		b := newTestBackend(client, "TestBackendExpiration")
+		b.Clock = clock
+		return b
+	})
+}
+
+func TestBackendValueTooLarge(t *testing.T) {
+	client, err := newDynamoDBTestClient()
+	if err != nil {
+		t.Fatal(err)
+	} else if client == nil {
+		t.Skip("no dynamodb server available")
+	}
+
+	b := newTestBackend(client, "TestBackendValueTooLarge")
+	assert.Equal(t, keyvaluestore.ErrValueTooLarge, b.Set("foo", strings.Repeat("x", 500*1024)))
+}
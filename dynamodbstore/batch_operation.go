@@ -2,10 +2,11 @@ package dynamodbstore
 
 import (
 	"encoding/binary"
+	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/theaaf/keyvaluestore"
@@ -29,6 +30,90 @@ func (g batchedSMembers) Result() ([]string, error) {
 	return g.members, g.err
 }
 
+type batchedHGet struct {
+	*batchedGet
+	key   string
+	field string
+}
+
+type batchedHMGet struct {
+	gets []*batchedGet
+}
+
+func (g batchedHMGet) Result() ([]*string, error) {
+	values := make([]*string, len(g.gets))
+	for i, get := range g.gets {
+		if get.err != nil {
+			return nil, get.err
+		}
+		values[i] = get.value
+	}
+	return values, nil
+}
+
+type batchedScore struct {
+	score *float64
+	err   error
+}
+
+func (g batchedScore) Result() (*float64, error) {
+	return g.score, g.err
+}
+
+type batchedZScore struct {
+	*batchedScore
+	key    string
+	member string
+}
+
+type batchedBool struct {
+	value bool
+	err   error
+}
+
+func (r *batchedBool) Result() (bool, error) {
+	return r.value, r.err
+}
+
+type batchedInt struct {
+	value int64
+	err   error
+}
+
+func (r *batchedInt) Result() (int64, error) {
+	return r.value, r.err
+}
+
+type batchedFloat struct {
+	value float64
+	err   error
+}
+
+func (r *batchedFloat) Result() (float64, error) {
+	return r.value, r.err
+}
+
+type batchedZRange struct {
+	members keyvaluestore.ScoredMembers
+	err     error
+}
+
+type batchedZRangeValues struct {
+	*batchedZRange
+}
+
+func (r batchedZRangeValues) Result() ([]string, error) {
+	return r.members.Values(), r.err
+}
+
+type batchedZRangeWithScores struct {
+	*batchedZRange
+}
+
+func (r batchedZRangeWithScores) Result() (keyvaluestore.ScoredMembers, error) {
+	return r.members, r.err
+}
+
 type batchedWrite struct {
 	request *dynamodb.WriteRequest
 	err     error
@@ -38,13 +123,36 @@ func (w batchedWrite) Result() error {
 	return w.err
 }
 
+type batchedWrites []*batchedWrite
+
+func (w batchedWrites) Result() error {
+	for _, write := range w {
+		if write.err != nil {
+			return write.err
+		}
+	}
+	return nil
+}
+
 type BatchOperation struct {
 	*keyvaluestore.FallbackBatchOperation
 	Backend *Backend
 
 	gets      map[string]*batchedGet
 	smemberss map[string]*batchedSMembers
+	hgets     map[string]batchedHGet
+	zscores   map[string]batchedZScore
 	writes    map[string]*batchedWrite
+
+	// Operations that can't be combined into batch requests are run concurrently instead.
+	concurrent []func() error
+}
+
+// Returns a string that uniquely identifies an item within the batch.
+func batchItemKey(hashKey, rangeKey string) string {
+	var encodedHashKeyLength [8]byte
+	binary.BigEndian.PutUint64(encodedHashKeyLength[:], uint64(len(hashKey)))
+	return string(encodedHashKeyLength[:]) + hashKey + rangeKey
 }
 
 func (op *BatchOperation) Get(key string) keyvaluestore.GetResult {
@@ -71,14 +179,130 @@ func (op *BatchOperation) SMembers(key string) keyvaluestore.SMembersResult {
 	return smembers
 }
 
-func (op *BatchOperation) batchWrite(hashKey, rangeKey string, request *dynamodb.WriteRequest) keyvaluestore.ErrorResult {
+func (op *BatchOperation) HGet(key, field string) keyvaluestore.GetResult {
+	if op.hgets == nil {
+		op.hgets = make(map[string]batchedHGet)
+	}
+	mapKey := batchItemKey(key, hashFieldRangeKey(field))
+	if get, ok := op.hgets[mapKey]; ok {
+		return get.batchedGet
+	}
+	get := batchedHGet{
+		batchedGet: &batchedGet{},
+		key:        key,
+		field:      field,
+	}
+	op.hgets[mapKey] = get
+	return get.batchedGet
+}
+
+func (op *BatchOperation) HMGet(key string, field string, fields ...string) keyvaluestore.HMGetResult {
+	gets := make([]*batchedGet, 1+len(fields))
+	gets[0] = op.HGet(key, field).(*batchedGet)
+	for i, field := range fields {
+		gets[i+1] = op.HGet(key, field).(*batchedGet)
+	}
+	return batchedHMGet{
+		gets: gets,
+	}
+}
+
+func (op *BatchOperation) ZScore(key string, member interface{}) keyvaluestore.ScoreResult {
+	if op.zscores == nil {
+		op.zscores = make(map[string]batchedZScore)
+	}
+	s := *keyvaluestore.ToString(member)
+	mapKey := batchItemKey(key, s)
+	if get, ok := op.zscores[mapKey]; ok {
+		return get.batchedScore
+	}
+	get := batchedZScore{
+		batchedScore: &batchedScore{},
+		key:          key,
+		member:       s,
+	}
+	op.zscores[mapKey] = get
+	return get.batchedScore
+}
+
+func (op *BatchOperation) ZCount(key string, min, max float64) keyvaluestore.IntResult {
+	result := &batchedInt{}
+	op.concurrent = append(op.concurrent, func() error {
+		n, err := op.Backend.ZCount(key, min, max)
+		result.value, result.err = int64(n), err
+		return err
+	})
+	return result
+}
+
+func (op *BatchOperation) ZRangeByScore(key string, min, max float64, limit int) keyvaluestore.ZRangeResult {
+	return batchedZRangeValues{op.zRangeByScoreWithScores(key, min, max, limit)}
+}
+
+func (op *BatchOperation) ZRangeByScoreWithScores(key string, min, max float64, limit int) keyvaluestore.ZRangeWithScoresResult {
+	return batchedZRangeWithScores{op.zRangeByScoreWithScores(key, min, max, limit)}
+}
+
+func (op *BatchOperation) zRangeByScoreWithScores(key string, min, max float64, limit int) *batchedZRange {
+	result := &batchedZRange{}
+	op.concurrent = append(op.concurrent, func() error {
+		result.members, result.err = op.Backend.ZRangeByScoreWithScores(key, min, max, limit)
+		return result.err
+	})
+	return result
+}
+
+func (op *BatchOperation) ZRangeByLex(key string, min, max string, limit int) keyvaluestore.ZRangeResult {
+	result := &batchedZRange{}
+	op.concurrent = append(op.concurrent, func() error {
+		result.members, result.err = op.Backend.zRangeByLex(key, min, max, limit, false, false)
+		return result.err
+	})
+	return batchedZRangeValues{result}
+}
+
+func (op *BatchOperation) ZIncrBy(key string, member string, n float64) keyvaluestore.FloatResult {
+	result := &batchedFloat{}
+	op.concurrent = append(op.concurrent, func() error {
+		result.value, result.err = op.Backend.ZIncrBy(key, member, n)
+		return result.err
+	})
+	return result
+}
+
+func (op *BatchOperation) AddInt(key string, n int64) keyvaluestore.IntResult {
+	result := &batchedInt{}
+	op.concurrent = append(op.concurrent, func() error {
+		result.value, result.err = op.Backend.AddInt(key, n)
+		return result.err
+	})
+	return result
+}
+
+func (op *BatchOperation) SetNX(key string, value interface{}) keyvaluestore.BoolResult {
+	result := &batchedBool{}
+	op.concurrent = append(op.concurrent, func() error {
+		result.value, result.err = op.Backend.SetNX(key, value)
+		return result.err
+	})
+	return result
+}
+
+func (op *BatchOperation) SetXX(key string, value interface{}) keyvaluestore.BoolResult {
+	result := &batchedBool{}
+	op.concurrent = append(op.concurrent, func() error {
+		result.value, result.err = op.Backend.SetXX(key, value)
+		return result.err
+	})
+	return result
+}
+
+func (op *BatchOperation) batchWrite(hashKey, rangeKey string, request *dynamodb.WriteRequest) *batchedWrite {
 	if op.writes == nil {
 		op.writes = make(map[string]*batchedWrite)
 	}
 
-	var encodedHashKeyLength [8]byte
-	binary.BigEndian.PutUint64(encodedHashKeyLength[:], uint64(len(hashKey)))
-	mapKey := string(encodedHashKeyLength[:]) + hashKey + rangeKey
+	mapKey := batchItemKey(hashKey, rangeKey)
 
 	if write, ok := op.writes[mapKey]; ok {
 		write.request = request
@@ -101,6 +325,22 @@ func (op *BatchOperation) Set(key string, value interface{}) keyvaluestore.Error
 	})
 }
 
+func (op *BatchOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.ErrorResult {
+	if ttl <= 0 {
+		return batchedWrite{
+			err: fmt.Errorf("invalid ttl: %v", ttl),
+		}
+	}
+	return op.batchWrite(key, "_", &dynamodb.WriteRequest{
+		PutRequest: &dynamodb.PutRequest{
+			Item: newItem(key, "_", map[string]*dynamodb.AttributeValue{
+				"v":  attributeValue(value),
+				"ex": expirationAttributeValue(op.Backend.now().Add(ttl)),
+			}),
+		},
+	})
+}
+
 func (op *BatchOperation) ZAdd(key string, member interface{}, score float64) keyvaluestore.ErrorResult {
 	s := *keyvaluestore.ToString(member)
 	return op.batchWrite(key, s, &dynamodb.WriteRequest{
@@ -113,16 +353,50 @@ func (op *BatchOperation) ZAdd(key string, member interface{}, score float64) ke
 	})
 }
 
+func (op *BatchOperation) HSet(key, field string, value interface{}) keyvaluestore.ErrorResult {
+	return op.batchWrite(key, hashFieldRangeKey(field), &dynamodb.WriteRequest{
+		PutRequest: &dynamodb.PutRequest{
+			Item: newItem(key, hashFieldRangeKey(field), map[string]*dynamodb.AttributeValue{
+				"v": attributeValue(value),
+			}),
+		},
+	})
+}
+
+func (op *BatchOperation) HDel(key string, field string, fields ...string) keyvaluestore.ErrorResult {
+	writes := make(batchedWrites, 0, 1+len(fields))
+	for _, field := range append([]string{field}, fields...) {
+		writes = append(writes, op.batchWrite(key, hashFieldRangeKey(field), &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: compositeKey(key, hashFieldRangeKey(field)),
+			},
+		}))
+	}
+	return writes
+}
+
 func (op *BatchOperation) execReads() error {
-	keys := make([]map[string]*dynamodb.AttributeValue, len(op.gets)+len(op.smemberss))
-	i := 0
+	keys := make([]map[string]*dynamodb.AttributeValue, 0, len(op.gets)+len(op.smemberss)+len(op.hgets)+len(op.zscores))
+	itemKeys := make(map[string]struct{}, cap(keys))
+	addKey := func(key map[string]*dynamodb.AttributeValue) {
+		// DynamoDB rejects batches that request the same item more than once.
+		itemKey := batchItemKey(*attributeStringValue(key["hk"]), *attributeStringValue(key["rk"]))
+		if _, ok := itemKeys[itemKey]; !ok {
+			itemKeys[itemKey] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
 	for key := range op.gets {
-		keys[i] = compositeKey(key, "_")
-		i++
+		addKey(compositeKey(key, "_"))
 	}
 	for key := range op.smemberss {
-		keys[i] = setKey(key, 0)
-		i++
+		addKey(setKey(key, 0))
+	}
+	for _, get := range op.hgets {
+		addKey(compositeKey(get.key, hashFieldRangeKey(get.field)))
+	}
+	for _, get := range op.zscores {
+		addKey(compositeKey(get.key, get.member))
 	}
 
 	if len(keys) == 0 {
@@ -133,9 +407,8 @@ func (op *BatchOperation) execReads() error {
 
 	for len(keys) > 0 {
 		batch := keys
-		const maxBatchSize = 100
-		if len(batch) > maxBatchSize {
-			batch = keys[:maxBatchSize]
+		if len(batch) > maxBatchGetItems {
+			batch = keys[:maxBatchGetItems]
 		}
 		keys = keys[len(batch):]
 
@@ -149,29 +422,54 @@ func (op *BatchOperation) execReads() error {
 
 			var ret error
 
-			for len(unprocessed) > 0 {
-				result, err := op.Backend.Client.BatchGetItem(&dynamodb.BatchGetItemInput{
+			fail := func(err error) error {
+				for _, key := range batch {
+					key, rangeKey := *attributeStringValue(key["hk"]), *attributeStringValue(key["rk"])
+					if get, ok := op.gets[key]; ok && rangeKey == "_" {
+						get.err = err
+					}
+					if smembers, ok := op.smemberss[key]; ok && rangeKey == firstSetRangeKey {
+						smembers.err = err
+					}
+					if get, ok := op.hgets[batchItemKey(key, rangeKey)]; ok {
+						get.err = err
+					}
+					if get, ok := op.zscores[batchItemKey(key, rangeKey)]; ok {
+						get.err = err
+					}
+				}
+				return err
+			}
+
+			for attempt := 1; len(unprocessed) > 0; attempt++ {
+				// DynamoDB leaves keys unprocessed when the table is throttled, so back off before
+				// asking for them again.
+				if attempt > 1 && !op.Backend.RetryPolicy.Retry(op.Backend.context(), attempt-1, keyvaluestore.ErrThrottled) {
+					return fail(keyvaluestore.ErrThrottled)
+				}
+
+				result, err := op.Backend.client().BatchGetItemWithContext(op.Backend.context(), &dynamodb.BatchGetItemInput{
 					RequestItems: unprocessed,
 				})
 				if err != nil {
-					for _, key := range batch {
-						key := *attributeStringValue(key["hk"])
-						if get, ok := op.gets[key]; ok {
-							get.err = err
-						}
-						if smembers, ok := op.smemberss[key]; ok {
-							smembers.err = err
-						}
-					}
-					return errors.Wrap(err, "dynamodb batch get item request error")
+					return fail(wrapError(err, "dynamodb batch get item request error"))
 				}
 
 				for _, item := range result.Responses[op.Backend.TableName] {
-					key := *attributeStringValue(item["hk"])
-					if get, ok := op.gets[key]; ok {
+					key, rangeKey := *attributeStringValue(item["hk"]), *attributeStringValue(item["rk"])
+					if get, ok := op.hgets[batchItemKey(key, rangeKey)]; ok {
 						get.value = attributeStringValue(item["v"])
 					}
-					if smembers, ok := op.smemberss[key]; ok {
+					if get, ok := op.zscores[batchItemKey(key, rangeKey)]; ok {
+						if rk2 := attributeStringValue(item["rk2"]); rk2 != nil {
+							score := sortKeyFloat(*rk2)
+							get.score = &score
+						}
+					}
+					if get, ok := op.gets[key]; ok && rangeKey == "_" && !op.Backend.isExpired(item) {
+						get.value = attributeStringValue(item["v"])
+					}
+					if smembers, ok := op.smemberss[key]; ok && rangeKey == firstSetRangeKey {
 						if item["c"].BOOL != nil && *item["c"].BOOL {
 							smembers.members, smembers.err = op.Backend.SMembers(key)
 							if smembers.err != nil {
@@ -207,9 +505,8 @@ func (op *BatchOperation) execWrites() error {
 
 	for len(remainingWrites) > 0 {
 		batch := remainingWrites
-		const maxBatchSize = 25
-		if len(batch) > maxBatchSize {
-			batch = remainingWrites[:maxBatchSize]
+		if len(batch) > maxBatchWriteItems {
+			batch = remainingWrites[:maxBatchWriteItems]
 		}
 
 		writeRequests := make([]*dynamodb.WriteRequest, len(batch))
@@ -220,15 +517,23 @@ func (op *BatchOperation) execWrites() error {
 			op.Backend.TableName: writeRequests,
 		}
 
-		for len(unprocessed) > 0 {
-			result, err := op.Backend.Client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+		fail := func(err error) error {
+			for _, w := range remainingWrites {
+				w.err = err
+			}
+			return err
+		}
+
+		for attempt := 1; len(unprocessed) > 0; attempt++ {
+			if attempt > 1 && !op.Backend.RetryPolicy.Retry(op.Backend.context(), attempt-1, keyvaluestore.ErrThrottled) {
+				return fail(keyvaluestore.ErrThrottled)
+			}
+
+			result, err := op.Backend.client().BatchWriteItemWithContext(op.Backend.context(), &dynamodb.BatchWriteItemInput{
 				RequestItems: unprocessed,
 			})
 			if err != nil {
-				for _, w := range remainingWrites {
-					w.err = err
-				}
-				return errors.Wrap(err, "dynamodb batch write item request error")
+				return fail(wrapError(err, "dynamodb batch write item request error"))
 			}
 			unprocessed = result.UnprocessedItems
 		}
@@ -239,11 +544,23 @@ func (op *BatchOperation) execWrites() error {
 	return nil
 }
 
+func (op *BatchOperation) execConcurrent() error {
+	var g errgroup.Group
+	for _, f := range op.concurrent {
+		g.Go(f)
+	}
+	return g.Wait()
+}
+
+// Exec runs the batched reads, then the batched writes, and then the operations that can't be
+// batched, concurrently and in no particular order.
 func (op *BatchOperation) Exec() error {
 	if err := op.execReads(); err != nil {
 		return err
 	} else if err := op.execWrites(); err != nil {
 		return err
+	} else if err := op.execConcurrent(); err != nil {
+		return err
 	}
 	return op.FallbackBatchOperation.Exec()
 }
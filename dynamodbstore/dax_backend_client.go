@@ -1,7 +1,9 @@
 package dynamodbstore
 
 import (
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 
 	"github.com/theaaf/aws-dax-go/dax"
@@ -11,8 +13,8 @@ type DaxBackendClient struct {
 	*dax.Dax
 }
 
-func (c *DaxBackendClient) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, TransactWriteErr) {
-	output, err := c.Dax.TransactWriteItems(input)
+func (c *DaxBackendClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, TransactWriteErr) {
+	output, err := c.Dax.TransactWriteItemsWithContext(ctx, input, opts...)
 	if err == nil {
 		return output, nil
 	}
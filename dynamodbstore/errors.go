@@ -0,0 +1,41 @@
+package dynamodbstore
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+// Returns the keyvaluestore error that corresponds to a DynamoDB error, or nil if there isn't one.
+func keyvaluestoreError(err error) error {
+	awsErr, ok := err.(awserr.Error)
+	if !ok {
+		return nil
+	}
+	switch awsErr.Code() {
+	case "ProvisionedThroughputExceededException", "ThrottlingException", "RequestLimitExceeded":
+		return keyvaluestore.ErrThrottled
+	case "TransactionConflictException":
+		return keyvaluestore.ErrContention
+	case "ValidationException":
+		msg := awsErr.Message()
+		if strings.Contains(msg, "size has exceeded") {
+			return keyvaluestore.ErrValueTooLarge
+		} else if strings.Contains(msg, "incorrect data type") || strings.Contains(msg, "Incorrect operand type") {
+			return keyvaluestore.ErrWrongType
+		}
+	}
+	return nil
+}
+
+// Translates DynamoDB errors into keyvaluestore errors where possible. Other errors are wrapped
+// with the given message.
+func wrapError(err error, message string) error {
+	if kvsErr := keyvaluestoreError(err); kvsErr != nil {
+		return kvsErr
+	}
+	return errors.Wrap(err, message)
+}
@@ -0,0 +1,31 @@
+package dynamodbstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+func TestKeyvaluestoreError(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected error
+	}{
+		{awserr.New("ProvisionedThroughputExceededException", "", nil), keyvaluestore.ErrThrottled},
+		{awserr.New("ThrottlingException", "", nil), keyvaluestore.ErrThrottled},
+		{awserr.New("RequestLimitExceeded", "", nil), keyvaluestore.ErrThrottled},
+		{awserr.New("TransactionConflictException", "", nil), keyvaluestore.ErrContention},
+		{awserr.New("ValidationException", "Item size has exceeded the maximum allowed size", nil), keyvaluestore.ErrValueTooLarge},
+		{awserr.New("ValidationException", "An operand in the update expression has an incorrect data type", nil), keyvaluestore.ErrWrongType},
+		{awserr.New("ValidationException", "Invalid UpdateExpression: Incorrect operand type for operator or function; operator: ADD, operand type: BINARY", nil), keyvaluestore.ErrWrongType},
+		{awserr.New("ValidationException", "One or more parameter values were invalid", nil), nil},
+		{awserr.New("ResourceNotFoundException", "", nil), nil},
+		{fmt.Errorf("foo"), nil},
+	} {
+		assert.Equal(t, tc.expected, keyvaluestoreError(tc.err), "%v", tc.err)
+	}
+}
@@ -1,10 +1,12 @@
 package dynamodbstore
 
 import (
+	"context"
 	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -50,6 +52,15 @@ func (p *BasicProfiler) DynamoDBWriteCapacityConsumed() float64 {
 	return float64(atomic.LoadInt64(&p.writeCapacityConsumedX4)) / 4.0
 }
 
+type profilerContextKey struct{}
+
+// Returns a context that makes backends created with it via WithContext report to the given
+// profiler, in addition to any profilers they already report to. This allows requests to be
+// attributed to whatever is performing them, such as a trace span.
+func ContextWithProfiler(ctx context.Context, profiler Profiler) context.Context {
+	return context.WithValue(ctx, profilerContextKey{}, profiler)
+}
+
 type ProfilingBackendClient struct {
 	Client   BackendClient
 	Profiler Profiler
@@ -69,11 +80,11 @@ func (c *ProfilingBackendClient) profileConsumedWriteCapacity(capacity *dynamodb
 	c.Profiler.ConsumeDynamoDBWriteCapacity(*capacity.CapacityUnits)
 }
 
-func (c *ProfilingBackendClient) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamodb.BatchGetItemOutput, error) {
+func (c *ProfilingBackendClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.BatchGetItem(&copy)
+	output, err := c.Client.BatchGetItemWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("BatchGetItem", time.Since(startTime))
 	if err == nil {
 		for _, capacity := range output.ConsumedCapacity {
@@ -83,11 +94,11 @@ func (c *ProfilingBackendClient) BatchGetItem(input *dynamodb.BatchGetItemInput)
 	return output, err
 }
 
-func (c *ProfilingBackendClient) BatchWriteItem(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+func (c *ProfilingBackendClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.BatchWriteItem(&copy)
+	output, err := c.Client.BatchWriteItemWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("BatchWriteItem", time.Since(startTime))
 	if err == nil {
 		for _, capacity := range output.ConsumedCapacity {
@@ -97,11 +108,11 @@ func (c *ProfilingBackendClient) BatchWriteItem(input *dynamodb.BatchWriteItemIn
 	return output, err
 }
 
-func (c *ProfilingBackendClient) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+func (c *ProfilingBackendClient) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.DeleteItem(&copy)
+	output, err := c.Client.DeleteItemWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("DeleteItem", time.Since(startTime))
 	if err == nil {
 		c.profileConsumedWriteCapacity(output.ConsumedCapacity)
@@ -109,11 +120,11 @@ func (c *ProfilingBackendClient) DeleteItem(input *dynamodb.DeleteItemInput) (*d
 	return output, err
 }
 
-func (c *ProfilingBackendClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+func (c *ProfilingBackendClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.GetItem(&copy)
+	output, err := c.Client.GetItemWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("GetItem", time.Since(startTime))
 	if err == nil {
 		c.profileConsumedReadCapacity(output.ConsumedCapacity)
@@ -121,11 +132,11 @@ func (c *ProfilingBackendClient) GetItem(input *dynamodb.GetItemInput) (*dynamod
 	return output, err
 }
 
-func (c *ProfilingBackendClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+func (c *ProfilingBackendClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.PutItem(&copy)
+	output, err := c.Client.PutItemWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("PutItem", time.Since(startTime))
 	if err == nil {
 		c.profileConsumedWriteCapacity(output.ConsumedCapacity)
@@ -133,11 +144,11 @@ func (c *ProfilingBackendClient) PutItem(input *dynamodb.PutItemInput) (*dynamod
 	return output, err
 }
 
-func (c *ProfilingBackendClient) Query(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+func (c *ProfilingBackendClient) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.Query(&copy)
+	output, err := c.Client.QueryWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("Query", time.Since(startTime))
 	if err == nil {
 		c.profileConsumedReadCapacity(output.ConsumedCapacity)
@@ -145,11 +156,23 @@ func (c *ProfilingBackendClient) Query(input *dynamodb.QueryInput) (*dynamodb.Qu
 	return output, err
 }
 
-func (c *ProfilingBackendClient) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+func (c *ProfilingBackendClient) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	copy := *input
+	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	startTime := time.Now()
+	output, err := c.Client.ScanWithContext(ctx, &copy, opts...)
+	c.Profiler.AddDynamoDBRequestProfile("Scan", time.Since(startTime))
+	if err == nil {
+		c.profileConsumedReadCapacity(output.ConsumedCapacity)
+	}
+	return output, err
+}
+
+func (c *ProfilingBackendClient) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.UpdateItem(&copy)
+	output, err := c.Client.UpdateItemWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("UpdateItem", time.Since(startTime))
 	if err == nil {
 		c.profileConsumedWriteCapacity(output.ConsumedCapacity)
@@ -157,11 +180,25 @@ func (c *ProfilingBackendClient) UpdateItem(input *dynamodb.UpdateItemInput) (*d
 	return output, err
 }
 
-func (c *ProfilingBackendClient) TransactWriteItems(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, TransactWriteErr) {
+func (c *ProfilingBackendClient) TransactGetItemsWithContext(ctx aws.Context, input *dynamodb.TransactGetItemsInput, opts ...request.Option) (*dynamodb.TransactGetItemsOutput, error) {
+	copy := *input
+	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
+	startTime := time.Now()
+	output, err := c.Client.TransactGetItemsWithContext(ctx, &copy, opts...)
+	c.Profiler.AddDynamoDBRequestProfile("TransactGetItem", time.Since(startTime))
+	if err == nil {
+		for _, capacity := range output.ConsumedCapacity {
+			c.profileConsumedReadCapacity(capacity)
+		}
+	}
+	return output, err
+}
+
+func (c *ProfilingBackendClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, TransactWriteErr) {
 	copy := *input
 	copy.ReturnConsumedCapacity = aws.String(dynamodb.ReturnConsumedCapacityTotal)
 	startTime := time.Now()
-	output, err := c.Client.TransactWriteItems(&copy)
+	output, err := c.Client.TransactWriteItemsWithContext(ctx, &copy, opts...)
 	c.Profiler.AddDynamoDBRequestProfile("TransactWriteItem", time.Since(startTime))
 	if err == nil {
 		for _, capacity := range output.ConsumedCapacity {
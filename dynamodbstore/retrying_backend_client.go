@@ -0,0 +1,111 @@
+package dynamodbstore
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+// Retries requests that fail with errors that the policy considers retryable, such as
+// ProvisionedThroughputExceededException.
+type retryingBackendClient struct {
+	Client BackendClient
+	Policy *keyvaluestore.RetryPolicy
+}
+
+var _ BackendClient = (*retryingBackendClient)(nil)
+
+func (c *retryingBackendClient) retry(ctx aws.Context, f func() error) {
+	for attempt := 1; ; attempt++ {
+		if err := f(); err == nil || !c.Policy.Retry(ctx, attempt, keyvaluestoreError(err)) {
+			return
+		}
+	}
+}
+
+func (c *retryingBackendClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (output *dynamodb.BatchGetItemOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.BatchGetItemWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (output *dynamodb.BatchWriteItemOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.BatchWriteItemWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) DeleteItemWithContext(ctx aws.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (output *dynamodb.DeleteItemOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.DeleteItemWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (output *dynamodb.GetItemOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.GetItemWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (output *dynamodb.PutItemOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.PutItemWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (output *dynamodb.QueryOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.QueryWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (output *dynamodb.ScanOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.ScanWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (output *dynamodb.UpdateItemOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.UpdateItemWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+func (c *retryingBackendClient) TransactGetItemsWithContext(ctx aws.Context, input *dynamodb.TransactGetItemsInput, opts ...request.Option) (output *dynamodb.TransactGetItemsOutput, err error) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.TransactGetItemsWithContext(ctx, input, opts...)
+		return err
+	})
+	return
+}
+
+// Canceled transactions aren't retried, even if they were canceled due to conflicts, so that
+// callers such as keyvaluestore.RunTransaction can re-read before trying again.
+func (c *retryingBackendClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (output *dynamodb.TransactWriteItemsOutput, err TransactWriteErr) {
+	c.retry(ctx, func() error {
+		output, err = c.Client.TransactWriteItemsWithContext(ctx, input, opts...)
+		if err == nil {
+			return nil
+		}
+		return err
+	})
+	return
+}
@@ -0,0 +1,148 @@
+package dynamodbstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+)
+
+// Fails requests in ways that should be retried.
+type flakyBackendClient struct {
+	BackendClient
+
+	getItemErrors     []error
+	unprocessedWrites int
+	requests          int
+}
+
+func (c *flakyBackendClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	c.requests++
+	if len(c.getItemErrors) > 0 {
+		err := c.getItemErrors[0]
+		c.getItemErrors = c.getItemErrors[1:]
+		return nil, err
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+func (c *flakyBackendClient) BatchWriteItemWithContext(ctx aws.Context, input *dynamodb.BatchWriteItemInput, opts ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	c.requests++
+	if c.unprocessedWrites > 0 {
+		c.unprocessedWrites--
+		return &dynamodb.BatchWriteItemOutput{
+			UnprocessedItems: input.RequestItems,
+		}, nil
+	}
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+// Always loses the race to update an expired item.
+func (c *flakyBackendClient) UpdateItemWithContext(ctx aws.Context, input *dynamodb.UpdateItemInput, opts ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	c.requests++
+	return nil, awserr.New("ConditionalCheckFailedException", "", nil)
+}
+
+func (c *flakyBackendClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	c.requests++
+	return nil, awserr.New("ConditionalCheckFailedException", "", nil)
+}
+
+func newFlakyTestBackend(client *flakyBackendClient) (*Backend, *keyvaluestoretest.RecordingClock) {
+	clock := keyvaluestoretest.NewRecordingClock(time.Unix(1500000000, 0))
+	return &Backend{
+		Client:    client,
+		TableName: "test",
+		RetryPolicy: &keyvaluestore.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			Clock:       clock,
+		},
+	}, clock
+}
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("Throttled", func(t *testing.T) {
+		client := &flakyBackendClient{
+			getItemErrors: []error{
+				awserr.New("ProvisionedThroughputExceededException", "", nil),
+				awserr.New("ThrottlingException", "", nil),
+			},
+		}
+		b, clock := newFlakyTestBackend(client)
+
+		v, err := b.Get("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+		assert.Equal(t, 3, client.requests)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.Waits())
+	})
+
+	t.Run("ThrottledTooManyTimes", func(t *testing.T) {
+		client := &flakyBackendClient{
+			getItemErrors: []error{
+				awserr.New("ProvisionedThroughputExceededException", "", nil),
+				awserr.New("ProvisionedThroughputExceededException", "", nil),
+				awserr.New("ProvisionedThroughputExceededException", "", nil),
+			},
+		}
+		b, _ := newFlakyTestBackend(client)
+
+		_, err := b.Get("foo")
+		assert.Equal(t, keyvaluestore.ErrThrottled, err)
+		assert.Equal(t, 3, client.requests)
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		client := &flakyBackendClient{
+			getItemErrors: []error{
+				awserr.New("ResourceNotFoundException", "", nil),
+			},
+		}
+		b, clock := newFlakyTestBackend(client)
+
+		_, err := b.Get("foo")
+		assert.Error(t, err)
+		assert.Equal(t, 1, client.requests)
+		assert.Empty(t, clock.Waits())
+	})
+
+	t.Run("UnprocessedItems", func(t *testing.T) {
+		client := &flakyBackendClient{
+			unprocessedWrites: 2,
+		}
+		b, clock := newFlakyTestBackend(client)
+
+		batch := b.Batch()
+		batch.Set("foo", "bar")
+		require.NoError(t, batch.Exec())
+		assert.Equal(t, 3, client.requests)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.Waits())
+
+		client.unprocessedWrites = 3
+		client.requests = 0
+		batch = b.Batch()
+		result := batch.Set("foo", "bar")
+		assert.Equal(t, keyvaluestore.ErrThrottled, batch.Exec())
+		assert.Equal(t, keyvaluestore.ErrThrottled, result.Result())
+		assert.Equal(t, 3, client.requests)
+	})
+
+	t.Run("Contention", func(t *testing.T) {
+		client := &flakyBackendClient{}
+		b, clock := newFlakyTestBackend(client)
+
+		_, err := b.AddInt("foo", 1)
+		assert.Equal(t, keyvaluestore.ErrContention, err)
+		assert.Equal(t, 6, client.requests)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.Waits())
+	})
+}
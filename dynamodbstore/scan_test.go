@@ -0,0 +1,87 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+)
+
+// Scans a fixed list of items, paginating the same way DynamoDB does.
+type scanBackendClient struct {
+	BackendClient
+
+	items []map[string]*dynamodb.AttributeValue
+}
+
+func (c *scanBackendClient) ScanWithContext(ctx aws.Context, input *dynamodb.ScanInput, opts ...request.Option) (*dynamodb.ScanOutput, error) {
+	start := 0
+	if input.ExclusiveStartKey != nil {
+		for i, item := range c.items {
+			if bytes.Equal(item["hk"].B, input.ExclusiveStartKey["hk"].B) && bytes.Equal(item["rk"].B, input.ExclusiveStartKey["rk"].B) {
+				start = i + 1
+			}
+		}
+	}
+	items := c.items[start:]
+	output := &dynamodb.ScanOutput{}
+	if input.Limit != nil && int(*input.Limit) < len(items) {
+		items = items[:*input.Limit]
+		last := items[len(items)-1]
+		output.LastEvaluatedKey = compositeKey(string(last["hk"].B), string(last["rk"].B))
+	}
+	output.Items = items
+	return output, nil
+}
+
+func TestScanExpiredItems(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	expired := expirationAttributeValue(now.Add(-time.Second))
+	live := expirationAttributeValue(now.Add(time.Second))
+
+	client := &scanBackendClient{
+		items: []map[string]*dynamodb.AttributeValue{
+			newItem("a", "_", nil),
+			newItem("b", "x", map[string]*dynamodb.AttributeValue{"ex": expired}),
+			newItem("b", "y", map[string]*dynamodb.AttributeValue{"ex": live}),
+			newItem("c", "x", nil),
+			newItem("c", "y", nil),
+			newItem("d", "x", map[string]*dynamodb.AttributeValue{"ex": live}),
+			newItem("d", "y", map[string]*dynamodb.AttributeValue{"ex": expired}),
+			newItem("e", "x", map[string]*dynamodb.AttributeValue{"ex": expired}),
+			newItem("e", "y", map[string]*dynamodb.AttributeValue{"ex": expired}),
+		},
+	}
+	b := &Backend{
+		Client:    client,
+		TableName: "test",
+		Clock:     keyvaluestoretest.NewFakeClock(now),
+	}
+
+	// Every page boundary should give the same keys, each exactly once.
+	for limit := 0; limit <= len(client.items); limit++ {
+		var keys []string
+		cursor := ""
+		for {
+			page, err := b.Scan("", cursor, limit)
+			require.NoError(t, err)
+			for _, key := range page.Keys {
+				keys = append(keys, key.Key)
+			}
+			if page.Cursor == "" {
+				break
+			}
+			cursor = page.Cursor
+		}
+		sort.Strings(keys)
+		assert.Equal(t, []string{"a", "b", "c", "d"}, keys, "limit %v", limit)
+	}
+}
@@ -0,0 +1,26 @@
+package keyvaluestore
+
+import "errors"
+
+// These errors are returned as-is by every backend, so they can be compared directly.
+var (
+	// The operation gave up after repeatedly conflicting with concurrent writes.
+	ErrContention = errors.New("keyvaluestore: operation failed due to contention")
+
+	// The atomic write or batch has more operations than the backend allows.
+	ErrTooManyOperations = errors.New("keyvaluestore: too many operations")
+
+	// The key holds the wrong kind of value for the operation, such as a non-integer value being
+	// incremented.
+	ErrWrongType = errors.New("keyvaluestore: operation against a key holding the wrong kind of value")
+
+	// The value is larger than the backend allows.
+	ErrValueTooLarge = errors.New("keyvaluestore: value too large")
+
+	// The backend rejected the request due to rate limiting or because it's temporarily unable to
+	// serve it. The request can be retried later.
+	ErrThrottled = errors.New("keyvaluestore: request throttled")
+
+	// The request wasn't made because a circuit breaker is open.
+	ErrCircuitOpen = errors.New("keyvaluestore: circuit breaker is open")
+)
@@ -0,0 +1,1060 @@
+package keyvaluestore
+
+import (
+	"context"
+	"time"
+)
+
+// Operation describes a call made through an InstrumentedBackend. For batches and atomic
+// operations, a single Operation is created when they're executed, with the keys of all of the
+// operations within them.
+type Operation struct {
+	// The name of the Backend method, or "Batch", "AtomicWrite", or "AtomicRead" when those
+	// operations are executed.
+	Name string
+
+	// The keys that the operation reads or writes.
+	Keys []string
+
+	// The context given to the backend's WithContext, or nil. Interceptors may replace it in Before
+	// to pass values to After. For Backend methods, the operation is then performed with the new
+	// context. Batches and atomic operations are always performed with the context that they were
+	// created with.
+	Context context.Context
+
+	// The backend that performs the operation. Interceptors may replace it in Before, for example
+	// to send the operation to a fallback. The operations within batches and atomic operations are
+	// then added to a new one created by the replacement.
+	Backend Backend
+
+	// If an interceptor sets this in Before, the operation isn't performed and fails with this error
+	// instead. After is still invoked.
+	Err error
+
+	// For atomic writes, whether the write failed due to a condition. This is set before After is
+	// invoked.
+	ConditionalFailed bool
+}
+
+// Interceptor is invoked around every operation performed through an InstrumentedBackend.
+type Interceptor interface {
+	// Invoked before the operation is performed.
+	Before(op *Operation)
+
+	// Invoked after the operation completes with its duration and error.
+	After(op *Operation, duration time.Duration, err error)
+}
+
+// InstrumentedBackend wraps a backend, invoking an interceptor around each of its operations,
+// including those of its batches and atomic operations.
+type InstrumentedBackend struct {
+	Backend     Backend
+	Interceptor Interceptor
+
+	ctx context.Context
+}
+
+var _ Backend = &InstrumentedBackend{}
+
+func NewInstrumentedBackend(b Backend, interceptor Interceptor) *InstrumentedBackend {
+	return &InstrumentedBackend{
+		Backend:     b,
+		Interceptor: interceptor,
+	}
+}
+
+type instrumentedOperation struct {
+	*Operation
+	startTime time.Time
+
+	// The backend that performs the operation, using the operation's context.
+	backend Backend
+}
+
+func (b *InstrumentedBackend) begin(name string, keys ...string) *instrumentedOperation {
+	op := &instrumentedOperation{
+		Operation: &Operation{
+			Name:    name,
+			Keys:    keys,
+			Context: b.ctx,
+			Backend: b.Backend,
+		},
+	}
+	b.Interceptor.Before(op.Operation)
+	op.backend = op.Operation.Backend
+	if op.Context != nil && op.Context != b.ctx {
+		op.backend = op.backend.WithContext(op.Context)
+	}
+	op.startTime = time.Now()
+	return op
+}
+
+func (b *InstrumentedBackend) end(op *instrumentedOperation, err error) {
+	b.Interceptor.After(op.Operation, time.Since(op.startTime), err)
+}
+
+// Performs a Backend method with the backend chosen by the interceptor.
+func (b *InstrumentedBackend) do(name string, keys []string, f func(backend Backend) error) error {
+	op := b.begin(name, keys...)
+	err := op.Err
+	if err == nil {
+		err = f(op.backend)
+	}
+	b.end(op, err)
+	return err
+}
+
+// Returns a backend that fails every operation with err.
+func (b *InstrumentedBackend) failing(err error) Backend {
+	return NewInstrumentedBackend(b.Backend, failingInterceptor{err})
+}
+
+type failingInterceptor struct {
+	err error
+}
+
+func (i failingInterceptor) Before(op *Operation) {
+	op.Err = i.err
+}
+
+func (i failingInterceptor) After(op *Operation, duration time.Duration, err error) {}
+
+func (b *InstrumentedBackend) WithContext(ctx context.Context) Backend {
+	return &InstrumentedBackend{
+		Backend:     b.Backend.WithContext(ctx),
+		Interceptor: b.Interceptor,
+		ctx:         ctx,
+	}
+}
+
+func (b *InstrumentedBackend) Batch() BatchOperation {
+	return &instrumentedBatchOperation{
+		batch:   b.Backend.Batch(),
+		backend: b,
+	}
+}
+
+func (b *InstrumentedBackend) AtomicWrite() AtomicWriteOperation {
+	return &instrumentedAtomicWriteOperation{
+		atomicWrite: b.Backend.AtomicWrite(),
+		backend:     b,
+	}
+}
+
+func (b *InstrumentedBackend) AtomicRead() AtomicReadOperation {
+	return &instrumentedAtomicReadOperation{
+		atomicRead: b.Backend.AtomicRead(),
+		backend:    b,
+	}
+}
+
+func (b *InstrumentedBackend) Capabilities() Capabilities {
+	return b.Backend.Capabilities()
+}
+
+func (b *InstrumentedBackend) Delete(key string) (bool, error) {
+	var ret bool
+	err := b.do("Delete", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.Delete(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) Get(key string) (*string, error) {
+	var ret *string
+	err := b.do("Get", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.Get(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) Set(key string, value interface{}) error {
+	return b.do("Set", []string{key}, func(backend Backend) error {
+		return backend.Set(key, value)
+	})
+}
+
+func (b *InstrumentedBackend) CAS(key string, transform func(v *string) (interface{}, error)) (bool, error) {
+	var ret bool
+	err := b.do("CAS", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.CAS(key, transform)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) AddInt(key string, n int64) (int64, error) {
+	var ret int64
+	err := b.do("AddInt", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.AddInt(key, n)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SetXX(key string, value interface{}) (bool, error) {
+	var ret bool
+	err := b.do("SetXX", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SetXX(key, value)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SetNX(key string, value interface{}) (bool, error) {
+	var ret bool
+	err := b.do("SetNX", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SetNX(key, value)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SetEX(key string, value interface{}, ttl time.Duration) error {
+	return b.do("SetEX", []string{key}, func(backend Backend) error {
+		return backend.SetEX(key, value, ttl)
+	})
+}
+
+func (b *InstrumentedBackend) Expire(key string, ttl time.Duration) (bool, error) {
+	var ret bool
+	err := b.do("Expire", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.Expire(key, ttl)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) TTL(key string) (*time.Duration, error) {
+	var ret *time.Duration
+	err := b.do("TTL", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.TTL(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SAdd(key string, member interface{}, members ...interface{}) error {
+	return b.do("SAdd", []string{key}, func(backend Backend) error {
+		return backend.SAdd(key, member, members...)
+	})
+}
+
+func (b *InstrumentedBackend) SRem(key string, member interface{}, members ...interface{}) error {
+	return b.do("SRem", []string{key}, func(backend Backend) error {
+		return backend.SRem(key, member, members...)
+	})
+}
+
+func (b *InstrumentedBackend) SMembers(key string) ([]string, error) {
+	var ret []string
+	err := b.do("SMembers", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SMembers(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SIsMember(key string, member interface{}) (bool, error) {
+	var ret bool
+	err := b.do("SIsMember", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SIsMember(key, member)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SCard(key string) (int, error) {
+	var ret int
+	err := b.do("SCard", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SCard(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SRandMember(key string) (*string, error) {
+	var ret *string
+	err := b.do("SRandMember", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SRandMember(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) SPop(key string) (*string, error) {
+	var ret *string
+	err := b.do("SPop", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.SPop(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZAdd(key string, member interface{}, score float64) error {
+	return b.do("ZAdd", []string{key}, func(backend Backend) error {
+		return backend.ZAdd(key, member, score)
+	})
+}
+
+func (b *InstrumentedBackend) ZScore(key string, member interface{}) (*float64, error) {
+	var ret *float64
+	err := b.do("ZScore", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZScore(key, member)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRem(key string, member interface{}) error {
+	return b.do("ZRem", []string{key}, func(backend Backend) error {
+		return backend.ZRem(key, member)
+	})
+}
+
+func (b *InstrumentedBackend) ZIncrBy(key string, member string, n float64) (float64, error) {
+	var ret float64
+	err := b.do("ZIncrBy", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZIncrBy(key, member, n)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeByScore(key string, min, max float64, limit int) ([]string, error) {
+	var ret []string
+	err := b.do("ZRangeByScore", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeByScore(key, min, max, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeByScoreWithScores(key string, min, max float64, limit int) (ScoredMembers, error) {
+	var ret ScoredMembers
+	err := b.do("ZRangeByScoreWithScores", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeByScoreWithScores(key, min, max, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeByScore(key string, min, max float64, limit int) ([]string, error) {
+	var ret []string
+	err := b.do("ZRevRangeByScore", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeByScore(key, min, max, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeByScoreWithScores(key string, min, max float64, limit int) (ScoredMembers, error) {
+	var ret ScoredMembers
+	err := b.do("ZRevRangeByScoreWithScores", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeByScoreWithScores(key, min, max, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZCount(key string, min, max float64) (int, error) {
+	var ret int
+	err := b.do("ZCount", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZCount(key, min, max)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeByScoreRange(key string, r ScoreRange) (ScoredMembers, error) {
+	var ret ScoredMembers
+	err := b.do("ZRangeByScoreRange", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeByScoreRange(key, r)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeByScoreRange(key string, r ScoreRange) (ScoredMembers, error) {
+	var ret ScoredMembers
+	err := b.do("ZRevRangeByScoreRange", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeByScoreRange(key, r)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZCountRange(key string, min, max ScoreBound) (int, error) {
+	var ret int
+	err := b.do("ZCountRange", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZCountRange(key, min, max)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZLexCount(key string, min, max string) (int, error) {
+	var ret int
+	err := b.do("ZLexCount", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZLexCount(key, min, max)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeByLex(key string, min, max string, limit int) ([]string, error) {
+	var ret []string
+	err := b.do("ZRangeByLex", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeByLex(key, min, max, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeByLex(key string, min, max string, limit int) ([]string, error) {
+	var ret []string
+	err := b.do("ZRevRangeByLex", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeByLex(key, min, max, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*ZRangePage, error) {
+	var ret *ZRangePage
+	err := b.do("ZRangeByScorePage", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeByScorePage(key, min, max, cursor, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*ZRangePage, error) {
+	var ret *ZRangePage
+	err := b.do("ZRevRangeByScorePage", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeByScorePage(key, min, max, cursor, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeByLexPage(key string, min, max, cursor string, limit int) (*ZLexRangePage, error) {
+	var ret *ZLexRangePage
+	err := b.do("ZRangeByLexPage", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeByLexPage(key, min, max, cursor, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*ZLexRangePage, error) {
+	var ret *ZLexRangePage
+	err := b.do("ZRevRangeByLexPage", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeByLexPage(key, min, max, cursor, limit)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZCard(key string) (int, error) {
+	var ret int
+	err := b.do("ZCard", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZCard(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRank(key string, member interface{}) (*int, error) {
+	var ret *int
+	err := b.do("ZRank", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRank(key, member)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRank(key string, member interface{}) (*int, error) {
+	var ret *int
+	err := b.do("ZRevRank", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRank(key, member)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRange(key string, start, stop int) ([]string, error) {
+	var ret []string
+	err := b.do("ZRange", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRange(key, start, stop)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRangeWithScores(key string, start, stop int) (ScoredMembers, error) {
+	var ret ScoredMembers
+	err := b.do("ZRangeWithScores", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRangeWithScores(key, start, stop)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRange(key string, start, stop int) ([]string, error) {
+	var ret []string
+	err := b.do("ZRevRange", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRange(key, start, stop)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) ZRevRangeWithScores(key string, start, stop int) (ScoredMembers, error) {
+	var ret ScoredMembers
+	err := b.do("ZRevRangeWithScores", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.ZRevRangeWithScores(key, start, stop)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) HSet(key, field string, value interface{}) error {
+	return b.do("HSet", []string{key}, func(backend Backend) error {
+		return backend.HSet(key, field, value)
+	})
+}
+
+func (b *InstrumentedBackend) HGet(key, field string) (*string, error) {
+	var ret *string
+	err := b.do("HGet", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.HGet(key, field)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) HMGet(key string, field string, fields ...string) ([]*string, error) {
+	var ret []*string
+	err := b.do("HMGet", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.HMGet(key, field, fields...)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) HDel(key string, field string, fields ...string) error {
+	return b.do("HDel", []string{key}, func(backend Backend) error {
+		return backend.HDel(key, field, fields...)
+	})
+}
+
+func (b *InstrumentedBackend) HGetAll(key string) (map[string]string, error) {
+	var ret map[string]string
+	err := b.do("HGetAll", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.HGetAll(key)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) HIncrBy(key, field string, n int64) (int64, error) {
+	var ret int64
+	err := b.do("HIncrBy", []string{key}, func(backend Backend) (err error) {
+		ret, err = backend.HIncrBy(key, field, n)
+		return err
+	})
+	return ret, err
+}
+
+func (b *InstrumentedBackend) Scan(prefix, cursor string, limit int) (*ScanPage, error) {
+	var ret *ScanPage
+	err := b.do("Scan", nil, func(backend Backend) (err error) {
+		ret, err = backend.Scan(prefix, cursor, limit)
+		return err
+	})
+	return ret, err
+}
+
+// Operations are added to the batch that's created with the backend right away. They're recorded so
+// that they can be replayed on another batch if the interceptor replaces the backend or rejects the
+// batch.
+type instrumentedBatchOperation struct {
+	batch   BatchOperation
+	backend *InstrumentedBackend
+	keys    []string
+	ops     []func(batch BatchOperation)
+}
+
+func (op *instrumentedBatchOperation) add(key string, f func(batch BatchOperation)) {
+	op.keys = append(op.keys, key)
+	op.ops = append(op.ops, f)
+	f(op.batch)
+}
+
+func (op *instrumentedBatchOperation) replay(batch BatchOperation) BatchOperation {
+	for _, f := range op.ops {
+		f(batch)
+	}
+	return batch
+}
+
+func (op *instrumentedBatchOperation) Get(key string) GetResult {
+	r := &instrumentedGetResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.GetResult = batch.Get(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) Delete(key string) DeleteResult {
+	r := &instrumentedDeleteResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.DeleteResult = batch.Delete(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) Set(key string, value interface{}) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.Set(key, value)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SetNX(key string, value interface{}) BoolResult {
+	r := &instrumentedBoolResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.BoolResult = batch.SetNX(key, value)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SetXX(key string, value interface{}) BoolResult {
+	r := &instrumentedBoolResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.BoolResult = batch.SetXX(key, value)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) AddInt(key string, n int64) IntResult {
+	r := &instrumentedIntResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.IntResult = batch.AddInt(key, n)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SetEX(key string, value interface{}, ttl time.Duration) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.SetEX(key, value, ttl)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) Expire(key string, ttl time.Duration) BoolResult {
+	r := &instrumentedBoolResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.BoolResult = batch.Expire(key, ttl)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SMembers(key string) SMembersResult {
+	r := &instrumentedSMembersResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.SMembersResult = batch.SMembers(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SAdd(key string, member interface{}, members ...interface{}) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.SAdd(key, member, members...)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SRem(key string, member interface{}, members ...interface{}) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.SRem(key, member, members...)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SIsMember(key string, member interface{}) BoolResult {
+	r := &instrumentedBoolResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.BoolResult = batch.SIsMember(key, member)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SCard(key string) IntResult {
+	r := &instrumentedIntResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.IntResult = batch.SCard(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SRandMember(key string) GetResult {
+	r := &instrumentedGetResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.GetResult = batch.SRandMember(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) SPop(key string) GetResult {
+	r := &instrumentedGetResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.GetResult = batch.SPop(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZAdd(key string, member interface{}, score float64) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.ZAdd(key, member, score)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZRem(key string, member interface{}) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.ZRem(key, member)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZScore(key string, member interface{}) ScoreResult {
+	r := &instrumentedScoreResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ScoreResult = batch.ZScore(key, member)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZIncrBy(key string, member string, n float64) FloatResult {
+	r := &instrumentedFloatResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.FloatResult = batch.ZIncrBy(key, member, n)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZCount(key string, min, max float64) IntResult {
+	r := &instrumentedIntResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.IntResult = batch.ZCount(key, min, max)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZRangeByScore(key string, min, max float64, limit int) ZRangeResult {
+	r := &instrumentedZRangeResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ZRangeResult = batch.ZRangeByScore(key, min, max, limit)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZRangeByScoreWithScores(key string, min, max float64, limit int) ZRangeWithScoresResult {
+	r := &instrumentedZRangeWithScoresResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ZRangeWithScoresResult = batch.ZRangeByScoreWithScores(key, min, max, limit)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) ZRangeByLex(key string, min, max string, limit int) ZRangeResult {
+	r := &instrumentedZRangeResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ZRangeResult = batch.ZRangeByLex(key, min, max, limit)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) HSet(key, field string, value interface{}) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.HSet(key, field, value)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) HGet(key, field string) GetResult {
+	r := &instrumentedGetResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.GetResult = batch.HGet(key, field)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) HMGet(key string, field string, fields ...string) HMGetResult {
+	r := &instrumentedHMGetResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.HMGetResult = batch.HMGet(key, field, fields...)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) HDel(key string, field string, fields ...string) ErrorResult {
+	r := &instrumentedErrorResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.ErrorResult = batch.HDel(key, field, fields...)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) HGetAll(key string) HGetAllResult {
+	r := &instrumentedHGetAllResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.HGetAllResult = batch.HGetAll(key)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) HIncrBy(key, field string, n int64) IntResult {
+	r := &instrumentedIntResult{}
+	op.add(key, func(batch BatchOperation) {
+		r.IntResult = batch.HIncrBy(key, field, n)
+	})
+	return r
+}
+
+func (op *instrumentedBatchOperation) Exec() error {
+	iop := op.backend.begin("Batch", op.keys...)
+	var err error
+	if iop.Err != nil {
+		err = iop.Err
+		op.replay(&FallbackBatchOperation{
+			Backend: op.backend.failing(err),
+		}).Exec()
+	} else if iop.Operation.Backend != op.backend.Backend {
+		err = op.replay(iop.Operation.Backend.Batch()).Exec()
+	} else {
+		err = op.batch.Exec()
+	}
+	op.backend.end(iop, err)
+	return err
+}
+
+type instrumentedAtomicWriteOperation struct {
+	atomicWrite AtomicWriteOperation
+	backend     *InstrumentedBackend
+	keys        []string
+	ops         []func(write AtomicWriteOperation) AtomicWriteResult
+	results     []*instrumentedAtomicWriteResult
+}
+
+func (op *instrumentedAtomicWriteOperation) add(key string, f func(write AtomicWriteOperation) AtomicWriteResult) AtomicWriteResult {
+	r := &instrumentedAtomicWriteResult{f(op.atomicWrite)}
+	op.keys = append(op.keys, key)
+	op.ops = append(op.ops, f)
+	op.results = append(op.results, r)
+	return r
+}
+
+func (op *instrumentedAtomicWriteOperation) SetNX(key string, value interface{}) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.SetNX(key, value)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) CAS(key string, oldValue, newValue string) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.CAS(key, oldValue, newValue)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) Delete(key string) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.Delete(key)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) SetEX(key string, value interface{}, ttl time.Duration) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.SetEX(key, value, ttl)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) Set(key string, value interface{}) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.Set(key, value)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) SAdd(key string, member interface{}, members ...interface{}) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.SAdd(key, member, members...)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) SRem(key string, member interface{}, members ...interface{}) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.SRem(key, member, members...)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) ZAdd(key string, member interface{}, score float64) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.ZAdd(key, member, score)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) ZRem(key string, member interface{}) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.ZRem(key, member)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) SetXX(key string, value interface{}) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.SetXX(key, value)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) AddInt(key string, n int64) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.AddInt(key, n)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) Check(key string, expected string) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.Check(key, expected)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) CheckExists(key string) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.CheckExists(key)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) CheckNotExists(key string) AtomicWriteResult {
+	return op.add(key, func(write AtomicWriteOperation) AtomicWriteResult {
+		return write.CheckNotExists(key)
+	})
+}
+
+func (op *instrumentedAtomicWriteOperation) Exec() (bool, error) {
+	iop := op.backend.begin("AtomicWrite", op.keys...)
+	var ok bool
+	var err error
+	if iop.Err != nil {
+		err = iop.Err
+		for _, r := range op.results {
+			r.AtomicWriteResult = failedAtomicWriteResult{}
+		}
+	} else if iop.Operation.Backend != op.backend.Backend {
+		write := iop.Operation.Backend.AtomicWrite()
+		for i, f := range op.ops {
+			op.results[i].AtomicWriteResult = f(write)
+		}
+		ok, err = write.Exec()
+	} else {
+		ok, err = op.atomicWrite.Exec()
+	}
+	iop.ConditionalFailed = !ok && err == nil
+	op.backend.end(iop, err)
+	return ok, err
+}
+
+type instrumentedAtomicReadOperation struct {
+	atomicRead AtomicReadOperation
+	backend    *InstrumentedBackend
+	keys       []string
+	ops        []func(read AtomicReadOperation)
+}
+
+func (op *instrumentedAtomicReadOperation) add(key string, f func(read AtomicReadOperation)) {
+	op.keys = append(op.keys, key)
+	op.ops = append(op.ops, f)
+	f(op.atomicRead)
+}
+
+func (op *instrumentedAtomicReadOperation) replay(read AtomicReadOperation) AtomicReadOperation {
+	for _, f := range op.ops {
+		f(read)
+	}
+	return read
+}
+
+func (op *instrumentedAtomicReadOperation) Get(key string) GetResult {
+	r := &instrumentedGetResult{}
+	op.add(key, func(read AtomicReadOperation) {
+		r.GetResult = read.Get(key)
+	})
+	return r
+}
+
+func (op *instrumentedAtomicReadOperation) SMembers(key string) SMembersResult {
+	r := &instrumentedSMembersResult{}
+	op.add(key, func(read AtomicReadOperation) {
+		r.SMembersResult = read.SMembers(key)
+	})
+	return r
+}
+
+func (op *instrumentedAtomicReadOperation) ZScore(key string, member interface{}) ScoreResult {
+	r := &instrumentedScoreResult{}
+	op.add(key, func(read AtomicReadOperation) {
+		r.ScoreResult = read.ZScore(key, member)
+	})
+	return r
+}
+
+func (op *instrumentedAtomicReadOperation) Exec() error {
+	iop := op.backend.begin("AtomicRead", op.keys...)
+	var err error
+	if iop.Err != nil {
+		err = iop.Err
+		op.replay(&FallbackBatchOperation{
+			Backend: op.backend.failing(err),
+		}).Exec()
+	} else if iop.Operation.Backend != op.backend.Backend {
+		err = op.replay(iop.Operation.Backend.AtomicRead()).Exec()
+	} else {
+		err = op.atomicRead.Exec()
+	}
+	op.backend.end(iop, err)
+	return err
+}
+
+// The results of batches and atomic operations. They're rebound when their operations are
+// replayed.
+type (
+	instrumentedDeleteResult           struct{ DeleteResult }
+	instrumentedGetResult              struct{ GetResult }
+	instrumentedSMembersResult         struct{ SMembersResult }
+	instrumentedErrorResult            struct{ ErrorResult }
+	instrumentedBoolResult             struct{ BoolResult }
+	instrumentedIntResult              struct{ IntResult }
+	instrumentedFloatResult            struct{ FloatResult }
+	instrumentedScoreResult            struct{ ScoreResult }
+	instrumentedZRangeResult           struct{ ZRangeResult }
+	instrumentedZRangeWithScoresResult struct{ ZRangeWithScoresResult }
+	instrumentedHMGetResult            struct{ HMGetResult }
+	instrumentedHGetAllResult          struct{ HGetAllResult }
+	instrumentedAtomicWriteResult      struct{ AtomicWriteResult }
+)
+
+// The result of an atomic write that was rejected before it was performed.
+type failedAtomicWriteResult struct{}
+
+func (failedAtomicWriteResult) ConditionalFailed() bool {
+	return false
+}
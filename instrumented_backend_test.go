@@ -0,0 +1,200 @@
+package keyvaluestore_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+type recordedOperation struct {
+	keyvaluestore.Operation
+	Err error
+}
+
+type recordingInterceptor struct {
+	mutex      sync.Mutex
+	before     []string
+	operations []recordedOperation
+}
+
+func (i *recordingInterceptor) Before(op *keyvaluestore.Operation) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.before = append(i.before, op.Name)
+}
+
+func (i *recordingInterceptor) After(op *keyvaluestore.Operation, duration time.Duration, err error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.operations = append(i.operations, recordedOperation{*op, err})
+}
+
+type contextKey struct{}
+
+// Invokes a function before each operation.
+type beforeInterceptor func(op *keyvaluestore.Operation)
+
+func (f beforeInterceptor) Before(op *keyvaluestore.Operation) {
+	f(op)
+}
+
+func (f beforeInterceptor) After(op *keyvaluestore.Operation, duration time.Duration, err error) {}
+
+func TestInstrumentedBackend(t *testing.T) {
+	keyvaluestoretest.TestBackend(t, func() keyvaluestore.Backend {
+		return keyvaluestore.NewInstrumentedBackend(memorystore.NewBackend(), &keyvaluestore.MethodProfiler{})
+	})
+
+	t.Run("Interceptor", func(t *testing.T) {
+		interceptor := &recordingInterceptor{}
+		ctx := context.WithValue(context.Background(), contextKey{}, "foo")
+		b := keyvaluestore.NewInstrumentedBackend(memorystore.NewBackend(), interceptor).WithContext(ctx)
+
+		require.NoError(t, b.Set("foo", "bar"))
+		_, err := b.AddInt("foo", 1)
+		assert.Equal(t, keyvaluestore.ErrWrongType, err)
+
+		batch := b.Batch()
+		batch.Get("a")
+		batch.SAdd("b", "x")
+		require.NoError(t, batch.Exec())
+
+		tx := b.AtomicWrite()
+		tx.SetNX("foo", "baz")
+		tx.Set("c", "x")
+		ok, err := tx.Exec()
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		read := b.AtomicRead()
+		read.Get("foo")
+		require.NoError(t, read.Exec())
+
+		assert.Equal(t, []string{"Set", "AddInt", "Batch", "AtomicWrite", "AtomicRead"}, interceptor.before)
+		require.Len(t, interceptor.operations, 5)
+		for _, op := range interceptor.operations {
+			assert.Equal(t, "foo", op.Context.Value(contextKey{}))
+		}
+
+		assert.Equal(t, []string{"foo"}, interceptor.operations[0].Keys)
+		assert.NoError(t, interceptor.operations[0].Err)
+		assert.Equal(t, keyvaluestore.ErrWrongType, interceptor.operations[1].Err)
+		assert.Equal(t, []string{"a", "b"}, interceptor.operations[2].Keys)
+		assert.Equal(t, []string{"foo", "c"}, interceptor.operations[3].Keys)
+		assert.True(t, interceptor.operations[3].ConditionalFailed)
+		assert.Equal(t, []string{"foo"}, interceptor.operations[4].Keys)
+	})
+
+	t.Run("ReplaceBackend", func(t *testing.T) {
+		original := memorystore.NewBackend()
+		replacement := memorystore.NewBackend()
+		require.NoError(t, replacement.Set("foo", "bar"))
+		b := keyvaluestore.NewInstrumentedBackend(original, beforeInterceptor(func(op *keyvaluestore.Operation) {
+			op.Backend = replacement
+		}))
+
+		v, err := b.Get("foo")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", *v)
+
+		batch := b.Batch()
+		get := batch.Get("foo")
+		batch.Set("baz", "x")
+		require.NoError(t, batch.Exec())
+		v, err = get.Result()
+		require.NoError(t, err)
+		assert.Equal(t, "bar", *v)
+
+		tx := b.AtomicWrite()
+		setNX := tx.SetNX("foo", "x")
+		ok, err := tx.Exec()
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.True(t, setNX.ConditionalFailed())
+
+		read := b.AtomicRead()
+		readGet := read.Get("baz")
+		require.NoError(t, read.Exec())
+		v, err = readGet.Result()
+		require.NoError(t, err)
+		assert.Equal(t, "x", *v)
+
+		v, err = original.Get("baz")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Reject", func(t *testing.T) {
+		failure := fmt.Errorf("failure")
+		memory := memorystore.NewBackend()
+		b := keyvaluestore.NewInstrumentedBackend(memory, beforeInterceptor(func(op *keyvaluestore.Operation) {
+			op.Err = failure
+		}))
+
+		assert.Equal(t, failure, b.Set("foo", "bar"))
+
+		batch := b.Batch()
+		get := batch.Get("foo")
+		batch.Set("foo", "bar")
+		assert.Equal(t, failure, batch.Exec())
+		_, err := get.Result()
+		assert.Equal(t, failure, err)
+
+		tx := b.AtomicWrite()
+		setNX := tx.SetNX("foo", "bar")
+		_, err = tx.Exec()
+		assert.Equal(t, failure, err)
+		assert.False(t, setNX.ConditionalFailed())
+
+		read := b.AtomicRead()
+		readGet := read.Get("foo")
+		assert.Equal(t, failure, read.Exec())
+		_, err = readGet.Result()
+		assert.Equal(t, failure, err)
+
+		v, err := memory.Get("foo")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+}
+
+func TestMethodProfiler(t *testing.T) {
+	profiler := &keyvaluestore.MethodProfiler{}
+	b := keyvaluestore.NewInstrumentedBackend(memorystore.NewBackend(), profiler)
+
+	for i := 0; i < 3; i++ {
+		_, err := b.Get(fmt.Sprintf("foo%v", i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, b.Set("foo", "bar"))
+	_, err := b.AddInt("foo", 1)
+	assert.Error(t, err)
+
+	batch := b.Batch()
+	batch.Get("foo")
+	require.NoError(t, batch.Exec())
+
+	get := profiler.Profile("Get")
+	assert.Equal(t, 3, get.Count)
+	assert.Equal(t, 0, get.ErrorCount)
+	assert.True(t, get.MaxDuration <= get.Duration)
+
+	addInt := profiler.Profile("AddInt")
+	assert.Equal(t, 1, addInt.Count)
+	assert.Equal(t, 1, addInt.ErrorCount)
+
+	assert.Equal(t, keyvaluestore.MethodProfile{}, profiler.Profile("Delete"))
+
+	profiles := profiler.Profiles()
+	assert.Len(t, profiles, 4)
+	assert.Equal(t, 1, profiles["Batch"].Count)
+}
@@ -1,6 +1,10 @@
 package keyvaluestorecache
 
-import "github.com/theaaf/keyvaluestore"
+import (
+	"time"
+
+	"github.com/theaaf/keyvaluestore"
+)
 
 type readCacheAtomicWriteOperation struct {
 	ReadCache   *ReadCache
@@ -14,6 +18,11 @@ func (op *readCacheAtomicWriteOperation) SetNX(key string, value interface{}) ke
 	return op.atomicWrite.SetNX(key, value)
 }
 
+func (op *readCacheAtomicWriteOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.SetEX(key, value, ttl)
+}
+
 func (op *readCacheAtomicWriteOperation) CAS(key string, oldValue, newValue string) keyvaluestore.AtomicWriteResult {
 	op.invalidations = append(op.invalidations, key)
 	return op.atomicWrite.CAS(key, oldValue, newValue)
@@ -24,6 +33,53 @@ func (op *readCacheAtomicWriteOperation) Delete(key string) keyvaluestore.Atomic
 	return op.atomicWrite.Delete(key)
 }
 
+func (op *readCacheAtomicWriteOperation) Set(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.Set(key, value)
+}
+
+func (op *readCacheAtomicWriteOperation) SAdd(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.SAdd(key, member, members...)
+}
+
+func (op *readCacheAtomicWriteOperation) SRem(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.SRem(key, member, members...)
+}
+
+func (op *readCacheAtomicWriteOperation) ZAdd(key string, member interface{}, score float64) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.ZAdd(key, member, score)
+}
+
+func (op *readCacheAtomicWriteOperation) ZRem(key string, member interface{}) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.ZRem(key, member)
+}
+
+func (op *readCacheAtomicWriteOperation) SetXX(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.SetXX(key, value)
+}
+
+func (op *readCacheAtomicWriteOperation) AddInt(key string, n int64) keyvaluestore.AtomicWriteResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.atomicWrite.AddInt(key, n)
+}
+
+func (op *readCacheAtomicWriteOperation) Check(key string, expected string) keyvaluestore.AtomicWriteResult {
+	return op.atomicWrite.Check(key, expected)
+}
+
+func (op *readCacheAtomicWriteOperation) CheckExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.atomicWrite.CheckExists(key)
+}
+
+func (op *readCacheAtomicWriteOperation) CheckNotExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.atomicWrite.CheckNotExists(key)
+}
+
 func (op *readCacheAtomicWriteOperation) Exec() (bool, error) {
 	ret, err := op.atomicWrite.Exec()
 	for _, key := range op.invalidations {
@@ -1,6 +1,10 @@
 package keyvaluestorecache
 
-import "github.com/theaaf/keyvaluestore"
+import (
+	"time"
+
+	"github.com/theaaf/keyvaluestore"
+)
 
 type readCacheBatchOperation struct {
 	ReadCache *ReadCache
@@ -8,8 +12,10 @@ type readCacheBatchOperation struct {
 	tryCache       []func()
 	getMisses      []boGetMiss
 	smembersMisses []boSMembersMiss
+	zMisses        []func()
 	batch          keyvaluestore.BatchOperation
 	invalidations  []string
+	uncachedReads  int
 	firstError     error
 }
 
@@ -65,6 +71,16 @@ func (op *readCacheBatchOperation) Set(key string, value interface{}) keyvaluest
 	return op.batch.Set(key, value)
 }
 
+func (op *readCacheBatchOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.ErrorResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.SetEX(key, value, ttl)
+}
+
+func (op *readCacheBatchOperation) Expire(key string, ttl time.Duration) keyvaluestore.BoolResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.Expire(key, ttl)
+}
+
 type boSMembersResult struct {
 	members []string
 	err     error
@@ -105,6 +121,11 @@ func (op *readCacheBatchOperation) SRem(key string, member interface{}, members
 	return op.batch.SRem(key, member, members...)
 }
 
+func (op *readCacheBatchOperation) SPop(key string) keyvaluestore.GetResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.SPop(key)
+}
+
 func (op *readCacheBatchOperation) ZAdd(key string, member interface{}, score float64) keyvaluestore.ErrorResult {
 	op.invalidations = append(op.invalidations, key)
 	return op.batch.ZAdd(key, member, score)
@@ -115,11 +136,234 @@ func (op *readCacheBatchOperation) ZRem(key string, member interface{}) keyvalue
 	return op.batch.ZRem(key, member)
 }
 
+func (op *readCacheBatchOperation) SetNX(key string, value interface{}) keyvaluestore.BoolResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.SetNX(key, value)
+}
+
+func (op *readCacheBatchOperation) SetXX(key string, value interface{}) keyvaluestore.BoolResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.SetXX(key, value)
+}
+
+func (op *readCacheBatchOperation) AddInt(key string, n int64) keyvaluestore.IntResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.AddInt(key, n)
+}
+
+func (op *readCacheBatchOperation) ZIncrBy(key string, member string, n float64) keyvaluestore.FloatResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.ZIncrBy(key, member, n)
+}
+
+// Sorted set reads are served from the same subcache entries as the ReadCache methods.
+
+type boScoreResult struct {
+	score *float64
+	err   error
+}
+
+func (r *boScoreResult) Result() (*float64, error) {
+	return r.score, r.err
+}
+
+func (op *readCacheBatchOperation) ZScore(key string, member interface{}) keyvaluestore.ScoreResult {
+	result := &boScoreResult{}
+	subkey := concatKeys("zs", *keyvaluestore.ToString(member))
+	op.tryCache = append(op.tryCache, func() {
+		if v, ok := op.ReadCache.loadZ(key, subkey); ok {
+			entry := v.(readCacheZScoreEntry)
+			result.score, result.err = entry.score, entry.err
+			op.cacheHit(result.err)
+			return
+		}
+		source := op.batch.ZScore(key, member)
+		op.zMisses = append(op.zMisses, func() {
+			result.score, result.err = source.Result()
+			op.ReadCache.storeZ(key, subkey, readCacheZScoreEntry{
+				score: result.score,
+				err:   result.err,
+			})
+		})
+	})
+	return result
+}
+
+type boIntResult struct {
+	value int64
+	err   error
+}
+
+func (r *boIntResult) Result() (int64, error) {
+	return r.value, r.err
+}
+
+func (op *readCacheBatchOperation) ZCount(key string, min, max float64) keyvaluestore.IntResult {
+	result := &boIntResult{}
+	subkey := concatKeys("zc", floatKey(min), floatKey(max))
+	op.tryCache = append(op.tryCache, func() {
+		if v, ok := op.ReadCache.loadZ(key, subkey); ok {
+			entry := v.(readCacheZCountEntry)
+			result.value, result.err = int64(entry.count), entry.err
+			op.cacheHit(result.err)
+			return
+		}
+		source := op.batch.ZCount(key, min, max)
+		op.zMisses = append(op.zMisses, func() {
+			result.value, result.err = source.Result()
+			op.ReadCache.storeZ(key, subkey, readCacheZCountEntry{
+				count: int(result.value),
+				err:   result.err,
+			})
+		})
+	})
+	return result
+}
+
+type boZRangeResult struct {
+	members keyvaluestore.ScoredMembers
+	err     error
+}
+
+type boZRangeValuesResult struct {
+	*boZRangeResult
+}
+
+func (r boZRangeValuesResult) Result() ([]string, error) {
+	return r.members.Values(), r.err
+}
+
+type boZRangeWithScoresResult struct {
+	*boZRangeResult
+}
+
+func (r boZRangeWithScoresResult) Result() (keyvaluestore.ScoredMembers, error) {
+	return r.members, r.err
+}
+
+func (op *readCacheBatchOperation) ZRangeByScore(key string, min, max float64, limit int) keyvaluestore.ZRangeResult {
+	return boZRangeValuesResult{op.zRangeByScoreWithScores(key, min, max, limit)}
+}
+
+func (op *readCacheBatchOperation) ZRangeByScoreWithScores(key string, min, max float64, limit int) keyvaluestore.ZRangeWithScoresResult {
+	return boZRangeWithScoresResult{op.zRangeByScoreWithScores(key, min, max, limit)}
+}
+
+func (op *readCacheBatchOperation) zRangeByScoreWithScores(key string, min, max float64, limit int) *boZRangeResult {
+	result := &boZRangeResult{}
+	subkey := concatKeys("zrbs", floatKey(min), floatKey(max))
+	op.tryCache = append(op.tryCache, func() {
+		if v, ok := op.ReadCache.loadZ(key, subkey); ok {
+			entry := v.(readCacheZRangeEntry)
+			if members, ok := entry.membersForLimit(limit); ok {
+				result.members, result.err = members, entry.err
+				op.cacheHit(result.err)
+				return
+			}
+		}
+		source := op.batch.ZRangeByScoreWithScores(key, min, max, limit)
+		op.zMisses = append(op.zMisses, func() {
+			result.members, result.err = source.Result()
+			op.ReadCache.storeZ(key, subkey, readCacheZRangeEntry{
+				members: result.members,
+				limit:   limit,
+				err:     result.err,
+			})
+		})
+	})
+	return result
+}
+
+func (op *readCacheBatchOperation) ZRangeByLex(key string, min, max string, limit int) keyvaluestore.ZRangeResult {
+	result := &boZRangeResult{}
+	subkey := concatKeys("zrbl", min, max)
+	op.tryCache = append(op.tryCache, func() {
+		if v, ok := op.ReadCache.loadZ(key, subkey); ok {
+			entry := v.(readCacheZRangeEntry)
+			if members, ok := entry.membersForLimit(limit); ok {
+				result.members, result.err = members, entry.err
+				op.cacheHit(result.err)
+				return
+			}
+		}
+		source := op.batch.ZRangeByLex(key, min, max, limit)
+		op.zMisses = append(op.zMisses, func() {
+			var members []string
+			members, result.err = source.Result()
+			result.members = make(keyvaluestore.ScoredMembers, len(members))
+			for i, member := range members {
+				result.members[i] = &keyvaluestore.ScoredMember{Value: member}
+			}
+			op.ReadCache.storeZ(key, subkey, readCacheZRangeEntry{
+				members: result.members,
+				limit:   limit,
+				err:     result.err,
+			})
+		})
+	})
+	return boZRangeValuesResult{result}
+}
+
+func (op *readCacheBatchOperation) cacheHit(err error) {
+	if err != nil && op.firstError == nil {
+		op.firstError = err
+	}
+}
+
+func (op *readCacheBatchOperation) HSet(key, field string, value interface{}) keyvaluestore.ErrorResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.HSet(key, field, value)
+}
+
+func (op *readCacheBatchOperation) HDel(key string, field string, fields ...string) keyvaluestore.ErrorResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.HDel(key, field, fields...)
+}
+
+func (op *readCacheBatchOperation) HIncrBy(key, field string, n int64) keyvaluestore.IntResult {
+	op.invalidations = append(op.invalidations, key)
+	return op.batch.HIncrBy(key, field, n)
+}
+
+// Hash reads within batches aren't cached.
+
+func (op *readCacheBatchOperation) HGet(key, field string) keyvaluestore.GetResult {
+	op.uncachedReads++
+	return op.batch.HGet(key, field)
+}
+
+func (op *readCacheBatchOperation) HMGet(key string, field string, fields ...string) keyvaluestore.HMGetResult {
+	op.uncachedReads++
+	return op.batch.HMGet(key, field, fields...)
+}
+
+func (op *readCacheBatchOperation) HGetAll(key string) keyvaluestore.HGetAllResult {
+	op.uncachedReads++
+	return op.batch.HGetAll(key)
+}
+
+// Other set reads within batches aren't cached either.
+
+func (op *readCacheBatchOperation) SIsMember(key string, member interface{}) keyvaluestore.BoolResult {
+	op.uncachedReads++
+	return op.batch.SIsMember(key, member)
+}
+
+func (op *readCacheBatchOperation) SCard(key string) keyvaluestore.IntResult {
+	op.uncachedReads++
+	return op.batch.SCard(key)
+}
+
+func (op *readCacheBatchOperation) SRandMember(key string) keyvaluestore.GetResult {
+	op.uncachedReads++
+	return op.batch.SRandMember(key)
+}
+
 func (op *readCacheBatchOperation) Exec() error {
 	for _, f := range op.tryCache {
 		f()
 	}
-	if op.firstError != nil || len(op.getMisses)+len(op.smembersMisses)+len(op.invalidations) == 0 {
+	if op.firstError != nil || len(op.getMisses)+len(op.smembersMisses)+len(op.zMisses)+len(op.invalidations)+op.uncachedReads == 0 {
 		return op.firstError
 	}
 	err := op.batch.Exec()
@@ -138,6 +382,9 @@ func (op *readCacheBatchOperation) Exec() error {
 			err:     miss.Dest.err,
 		})
 	}
+	for _, miss := range op.zMisses {
+		miss()
+	}
 	for _, key := range op.invalidations {
 		op.ReadCache.cache.Delete(key)
 	}
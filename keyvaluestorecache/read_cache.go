@@ -1,21 +1,27 @@
 package keyvaluestorecache
 
 import (
+	"context"
 	"encoding/binary"
 	"math"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/theaaf/keyvaluestore"
 )
 
 // Read cache caches reads permanently, or until they're invalidated by a write operation on the
-// cache.
+// cache. Expirations are not tracked, so a cached value may outlive its key's TTL.
 type ReadCache struct {
 	backend keyvaluestore.Backend
 	cache   *sync.Map
+	ctx     context.Context
 
 	eventuallyConsistentCache *sync.Map
 	eventuallyConsistentReads bool
+
+	noStore bool
 }
 
 var _ keyvaluestore.Backend = &ReadCache{}
@@ -24,10 +30,20 @@ func NewReadCache(b keyvaluestore.Backend) *ReadCache {
 	return &ReadCache{
 		backend:                   b,
 		cache:                     &sync.Map{},
+		ctx:                       context.Background(),
 		eventuallyConsistentCache: &sync.Map{},
 	}
 }
 
+// Returns a new ReadCache that shares the receiver's underlying cache, but performs its backend
+// operations within the given context. Results obtained after the context is done are never cached.
+func (c *ReadCache) WithContext(ctx context.Context) keyvaluestore.Backend {
+	ret := *c
+	ret.backend = c.backend.WithContext(ctx)
+	ret.ctx = ctx
+	return &ret
+}
+
 // Returns a new ReadCache that shares the receiver's underlying cache.
 func (c *ReadCache) WithBackend(b keyvaluestore.Backend) *ReadCache {
 	ret := *c
@@ -48,6 +64,16 @@ func (c *ReadCache) WithEventuallyConsistentReads() *ReadCache {
 	return &ret
 }
 
+// Returns a new ReadCache that shares the receiver's underlying cache, but never adds to it. Reads
+// that miss the cache are passed to the backend and their results are forgotten. Combined with
+// WithBackend, this can serve the values that are already cached while the original backend is
+// unavailable, without caching the errors of the replacement.
+func (c *ReadCache) WithoutStoring() *ReadCache {
+	ret := *c
+	ret.noStore = true
+	return &ret
+}
+
 func (c *ReadCache) load(key string) (interface{}, bool) {
 	if c.eventuallyConsistentReads {
 		return c.eventuallyConsistentCache.Load(key)
@@ -55,7 +81,15 @@ func (c *ReadCache) load(key string) (interface{}, bool) {
 	return c.cache.Load(key)
 }
 
+// Results obtained after the context is done are never cached.
+func (c *ReadCache) shouldStore() bool {
+	return !c.noStore && c.ctx.Err() == nil
+}
+
 func (c *ReadCache) store(key string, value interface{}) {
+	if !c.shouldStore() {
+		return
+	}
 	if c.eventuallyConsistentReads {
 		c.eventuallyConsistentCache.Store(key, value)
 	} else {
@@ -70,6 +104,19 @@ func (c *ReadCache) AtomicWrite() keyvaluestore.AtomicWriteOperation {
 	}
 }
 
+// Reports the backend's capabilities. Because cached values aren't refreshed when other clients
+// write to the backend, reads are always eventually consistent.
+func (c *ReadCache) Capabilities() keyvaluestore.Capabilities {
+	ret := c.backend.Capabilities()
+	ret.EventuallyConsistentReads = true
+	return ret
+}
+
+// Atomic reads bypass the cache since cached values may not form a consistent snapshot.
+func (c *ReadCache) AtomicRead() keyvaluestore.AtomicReadOperation {
+	return c.backend.AtomicRead()
+}
+
 func (c *ReadCache) Batch() keyvaluestore.BatchOperation {
 	return &readCacheBatchOperation{
 		ReadCache: c,
@@ -104,6 +151,23 @@ func (c *ReadCache) Set(key string, value interface{}) error {
 	return err
 }
 
+func (c *ReadCache) SetEX(key string, value interface{}, ttl time.Duration) error {
+	err := c.backend.SetEX(key, value, ttl)
+	c.Invalidate(key)
+	return err
+}
+
+func (c *ReadCache) Expire(key string, ttl time.Duration) (bool, error) {
+	ok, err := c.backend.Expire(key, ttl)
+	c.Invalidate(key)
+	return ok, err
+}
+
+// TTL is never cached.
+func (c *ReadCache) TTL(key string) (*time.Duration, error) {
+	return c.backend.TTL(key)
+}
+
 func (c *ReadCache) CAS(key string, transform func(v *string) (interface{}, error)) (success bool, err error) {
 	success, err = c.backend.CAS(key, transform)
 	c.Invalidate(key)
@@ -155,6 +219,40 @@ func (c *ReadCache) SMembers(key string) ([]string, error) {
 	return entry.members, entry.err
 }
 
+// SIsMember and SCard are answered from the cache if the set's members have already been read.
+// Otherwise they go straight to the backend.
+func (c *ReadCache) SIsMember(key string, member interface{}) (bool, error) {
+	v, _ := c.load(key)
+	if entry, ok := v.(readCacheSMembersEntry); ok && entry.err == nil {
+		s := *keyvaluestore.ToString(member)
+		for _, m := range entry.members {
+			if m == s {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return c.backend.SIsMember(key, member)
+}
+
+func (c *ReadCache) SCard(key string) (int, error) {
+	v, _ := c.load(key)
+	if entry, ok := v.(readCacheSMembersEntry); ok && entry.err == nil {
+		return len(entry.members), nil
+	}
+	return c.backend.SCard(key)
+}
+
+func (c *ReadCache) SRandMember(key string) (*string, error) {
+	return c.backend.SRandMember(key)
+}
+
+func (c *ReadCache) SPop(key string) (*string, error) {
+	member, err := c.backend.SPop(key)
+	c.Invalidate(key)
+	return member, err
+}
+
 func (c *ReadCache) ZAdd(key string, member interface{}, score float64) error {
 	err := c.backend.ZAdd(key, member, score)
 	c.Invalidate(key)
@@ -177,6 +275,9 @@ func (c *ReadCache) ZScore(key string, member interface{}) (*float64, error) {
 		}
 	}
 	score, err := c.backend.ZScore(key, member)
+	if !c.shouldStore() {
+		return score, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -219,6 +320,83 @@ func (c *ReadCache) ZCount(key string, min, max float64) (int, error) {
 		}
 	}
 	count, err := c.backend.ZCount(key, min, max)
+	if !c.shouldStore() {
+		return count, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZCountEntry{
+		count: count,
+		err:   err,
+	}
+	c.store(key, zEntry)
+	return count, err
+}
+
+func scoreBoundKey(bound keyvaluestore.ScoreBound) string {
+	if bound.Exclusive {
+		return "(" + floatKey(bound.Score)
+	}
+	return "[" + floatKey(bound.Score)
+}
+
+func (c *ReadCache) ZRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	return c.zRangeByScoreRange(key, r, false)
+}
+
+func (c *ReadCache) ZRevRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	return c.zRangeByScoreRange(key, r, true)
+}
+
+func (c *ReadCache) zRangeByScoreRange(key string, r keyvaluestore.ScoreRange, reverse bool) (keyvaluestore.ScoredMembers, error) {
+	prefix := "zrbsr"
+	if reverse {
+		prefix = "zrrbsr"
+	}
+	subkey := concatKeys(prefix, scoreBoundKey(r.Min), scoreBoundKey(r.Max), strconv.Itoa(r.Offset), strconv.Itoa(r.Limit))
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok {
+			return entry.members, entry.err
+		}
+	}
+	var members keyvaluestore.ScoredMembers
+	var err error
+	if reverse {
+		members, err = c.backend.ZRevRangeByScoreRange(key, r)
+	} else {
+		members, err = c.backend.ZRangeByScoreRange(key, r)
+	}
+	if !c.shouldStore() {
+		return members, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZRangeEntry{
+		members: members,
+		limit:   r.Limit,
+		err:     err,
+	}
+	c.store(key, zEntry)
+	return members, err
+}
+
+func (c *ReadCache) ZCountRange(key string, min, max keyvaluestore.ScoreBound) (int, error) {
+	subkey := concatKeys("zcr", scoreBoundKey(min), scoreBoundKey(max))
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZCountEntry); ok {
+			return entry.count, entry.err
+		}
+	}
+	count, err := c.backend.ZCountRange(key, min, max)
+	if !c.shouldStore() {
+		return count, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -240,6 +418,9 @@ func (c *ReadCache) ZLexCount(key string, min, max string) (int, error) {
 		}
 	}
 	count, err := c.backend.ZLexCount(key, min, max)
+	if !c.shouldStore() {
+		return count, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -257,6 +438,18 @@ type readCacheZRangeEntry struct {
 	err     error
 }
 
+// Returns the cached members for a query with the given limit, or false if the entry doesn't hold
+// enough of them.
+func (e readCacheZRangeEntry) membersForLimit(limit int) (keyvaluestore.ScoredMembers, bool) {
+	if e.limit != 0 && (limit == 0 || limit > e.limit) {
+		return nil, false
+	}
+	if limit != 0 && len(e.members) > limit {
+		return e.members[:limit], true
+	}
+	return e.members, true
+}
+
 func floatKey(f float64) string {
 	n := math.Float64bits(f)
 	buf := make([]byte, 8)
@@ -274,11 +467,16 @@ func (c *ReadCache) ZRangeByScoreWithScores(key string, min, max float64, limit
 	v, _ := c.load(key)
 	zEntry, ok := v.(readCacheZEntry)
 	if ok {
-		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok && limit <= entry.limit {
-			return entry.members, entry.err
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok {
+			if members, ok := entry.membersForLimit(limit); ok {
+				return members, entry.err
+			}
 		}
 	}
 	members, err := c.backend.ZRangeByScoreWithScores(key, min, max, limit)
+	if !c.shouldStore() {
+		return members, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -301,11 +499,16 @@ func (c *ReadCache) ZRevRangeByScoreWithScores(key string, min, max float64, lim
 	v, _ := c.load(key)
 	zEntry, ok := v.(readCacheZEntry)
 	if ok {
-		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok && limit <= entry.limit {
-			return entry.members, entry.err
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok {
+			if members, ok := entry.membersForLimit(limit); ok {
+				return members, entry.err
+			}
 		}
 	}
 	members, err := c.backend.ZRevRangeByScoreWithScores(key, min, max, limit)
+	if !c.shouldStore() {
+		return members, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -323,11 +526,16 @@ func (c *ReadCache) ZRangeByLex(key string, min, max string, limit int) ([]strin
 	v, _ := c.load(key)
 	zEntry, ok := v.(readCacheZEntry)
 	if ok {
-		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok && limit <= entry.limit {
-			return entry.members.Values(), entry.err
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok {
+			if members, ok := entry.membersForLimit(limit); ok {
+				return members.Values(), entry.err
+			}
 		}
 	}
 	members, err := c.backend.ZRangeByLex(key, min, max, limit)
+	if !c.shouldStore() {
+		return members, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -352,11 +560,16 @@ func (c *ReadCache) ZRevRangeByLex(key string, min, max string, limit int) ([]st
 	v, _ := c.load(key)
 	zEntry, ok := v.(readCacheZEntry)
 	if ok {
-		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok && limit <= entry.limit {
-			return entry.members.Values(), entry.err
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok {
+			if members, ok := entry.membersForLimit(limit); ok {
+				return members.Values(), entry.err
+			}
 		}
 	}
 	members, err := c.backend.ZRevRangeByLex(key, min, max, limit)
+	if !c.shouldStore() {
+		return members, err
+	}
 	if zEntry.subcache == nil {
 		zEntry.subcache = make(map[string]interface{})
 	}
@@ -376,10 +589,377 @@ func (c *ReadCache) ZRevRangeByLex(key string, min, max string, limit int) ([]st
 	return members, err
 }
 
+type readCacheZRangePageEntry struct {
+	page *keyvaluestore.ZRangePage
+	err  error
+}
+
+func (c *ReadCache) ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	subkey := concatKeys("zrbsp", floatKey(min), floatKey(max), cursor, strconv.Itoa(limit))
+	return c.zRangeByScorePage(key, subkey, func() (*keyvaluestore.ZRangePage, error) {
+		return c.backend.ZRangeByScorePage(key, min, max, cursor, limit)
+	})
+}
+
+func (c *ReadCache) ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	subkey := concatKeys("zrrbsp", floatKey(min), floatKey(max), cursor, strconv.Itoa(limit))
+	return c.zRangeByScorePage(key, subkey, func() (*keyvaluestore.ZRangePage, error) {
+		return c.backend.ZRevRangeByScorePage(key, min, max, cursor, limit)
+	})
+}
+
+func (c *ReadCache) zRangeByScorePage(key, subkey string, f func() (*keyvaluestore.ZRangePage, error)) (*keyvaluestore.ZRangePage, error) {
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangePageEntry); ok {
+			return entry.page, entry.err
+		}
+	}
+	page, err := f()
+	if !c.shouldStore() {
+		return page, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZRangePageEntry{
+		page: page,
+		err:  err,
+	}
+	c.store(key, zEntry)
+	return page, err
+}
+
+type readCacheZLexRangePageEntry struct {
+	page *keyvaluestore.ZLexRangePage
+	err  error
+}
+
+func (c *ReadCache) ZRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	subkey := concatKeys("zrblp", min, max, cursor, strconv.Itoa(limit))
+	return c.zRangeByLexPage(key, subkey, func() (*keyvaluestore.ZLexRangePage, error) {
+		return c.backend.ZRangeByLexPage(key, min, max, cursor, limit)
+	})
+}
+
+func (c *ReadCache) ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	subkey := concatKeys("zrrblp", min, max, cursor, strconv.Itoa(limit))
+	return c.zRangeByLexPage(key, subkey, func() (*keyvaluestore.ZLexRangePage, error) {
+		return c.backend.ZRevRangeByLexPage(key, min, max, cursor, limit)
+	})
+}
+
+func (c *ReadCache) zRangeByLexPage(key, subkey string, f func() (*keyvaluestore.ZLexRangePage, error)) (*keyvaluestore.ZLexRangePage, error) {
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZLexRangePageEntry); ok {
+			return entry.page, entry.err
+		}
+	}
+	page, err := f()
+	if !c.shouldStore() {
+		return page, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZLexRangePageEntry{
+		page: page,
+		err:  err,
+	}
+	c.store(key, zEntry)
+	return page, err
+}
+
+func (c *ReadCache) ZCard(key string) (int, error) {
+	subkey := "zcard"
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZCountEntry); ok {
+			return entry.count, entry.err
+		}
+	}
+	count, err := c.backend.ZCard(key)
+	if !c.shouldStore() {
+		return count, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZCountEntry{
+		count: count,
+		err:   err,
+	}
+	c.store(key, zEntry)
+	return count, err
+}
+
+type readCacheZRankEntry struct {
+	rank *int
+	err  error
+}
+
+func (c *ReadCache) ZRank(key string, member interface{}) (*int, error) {
+	return c.zRank(key, member, false)
+}
+
+func (c *ReadCache) ZRevRank(key string, member interface{}) (*int, error) {
+	return c.zRank(key, member, true)
+}
+
+func (c *ReadCache) zRank(key string, member interface{}, reverse bool) (*int, error) {
+	s := *keyvaluestore.ToString(member)
+	subkey := concatKeys("zrank", s)
+	if reverse {
+		subkey = concatKeys("zrrank", s)
+	}
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRankEntry); ok {
+			return entry.rank, entry.err
+		}
+	}
+	var rank *int
+	var err error
+	if reverse {
+		rank, err = c.backend.ZRevRank(key, member)
+	} else {
+		rank, err = c.backend.ZRank(key, member)
+	}
+	if !c.shouldStore() {
+		return rank, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZRankEntry{
+		rank: rank,
+		err:  err,
+	}
+	c.store(key, zEntry)
+	return rank, err
+}
+
+func (c *ReadCache) ZRange(key string, start, stop int) ([]string, error) {
+	members, err := c.ZRangeWithScores(key, start, stop)
+	return members.Values(), err
+}
+
+func (c *ReadCache) ZRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	return c.zRange(key, start, stop, false)
+}
+
+func (c *ReadCache) ZRevRange(key string, start, stop int) ([]string, error) {
+	members, err := c.ZRevRangeWithScores(key, start, stop)
+	return members.Values(), err
+}
+
+func (c *ReadCache) ZRevRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	return c.zRange(key, start, stop, true)
+}
+
+func (c *ReadCache) zRange(key string, start, stop int, reverse bool) (keyvaluestore.ScoredMembers, error) {
+	subkey := concatKeys("zr", strconv.Itoa(start), strconv.Itoa(stop))
+	if reverse {
+		subkey = concatKeys("zrr", strconv.Itoa(start), strconv.Itoa(stop))
+	}
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if ok {
+		if entry, ok := zEntry.subcache[subkey].(readCacheZRangeEntry); ok {
+			return entry.members, entry.err
+		}
+	}
+	var members keyvaluestore.ScoredMembers
+	var err error
+	if reverse {
+		members, err = c.backend.ZRevRangeWithScores(key, start, stop)
+	} else {
+		members, err = c.backend.ZRangeWithScores(key, start, stop)
+	}
+	if !c.shouldStore() {
+		return members, err
+	}
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = readCacheZRangeEntry{
+		members: members,
+		err:     err,
+	}
+	c.store(key, zEntry)
+	return members, err
+}
+
+func (c *ReadCache) HSet(key, field string, value interface{}) error {
+	err := c.backend.HSet(key, field, value)
+	c.Invalidate(key)
+	return err
+}
+
+func (c *ReadCache) HDel(key string, field string, fields ...string) error {
+	err := c.backend.HDel(key, field, fields...)
+	c.Invalidate(key)
+	return err
+}
+
+func (c *ReadCache) HIncrBy(key, field string, n int64) (int64, error) {
+	n, err := c.backend.HIncrBy(key, field, n)
+	c.Invalidate(key)
+	return n, err
+}
+
+type readCacheHEntry struct {
+	subcache map[string]interface{}
+}
+
+type readCacheHGetAllEntry struct {
+	fields map[string]string
+	err    error
+}
+
+// Returns the cached value of a hash field, if there is one.
+func (e readCacheHEntry) hget(field string) (readCacheGetEntry, bool) {
+	if entry, ok := e.subcache[concatKeys("hg", field)].(readCacheGetEntry); ok {
+		return entry, true
+	}
+	if entry, ok := e.subcache["hga"].(readCacheHGetAllEntry); ok && entry.err == nil {
+		if v, ok := entry.fields[field]; ok {
+			return readCacheGetEntry{value: &v}, true
+		}
+		return readCacheGetEntry{}, true
+	}
+	return readCacheGetEntry{}, false
+}
+
+func (c *ReadCache) HGet(key, field string) (*string, error) {
+	v, _ := c.load(key)
+	hEntry, _ := v.(readCacheHEntry)
+	if entry, ok := hEntry.hget(field); ok {
+		return entry.value, entry.err
+	}
+	value, err := c.backend.HGet(key, field)
+	if !c.shouldStore() {
+		return value, err
+	}
+	if hEntry.subcache == nil {
+		hEntry.subcache = make(map[string]interface{})
+	}
+	hEntry.subcache[concatKeys("hg", field)] = readCacheGetEntry{
+		value: value,
+		err:   err,
+	}
+	c.store(key, hEntry)
+	return value, err
+}
+
+func (c *ReadCache) HMGet(key string, field string, fields ...string) ([]*string, error) {
+	fields = append([]string{field}, fields...)
+
+	v, _ := c.load(key)
+	hEntry, _ := v.(readCacheHEntry)
+	values := make([]*string, len(fields))
+	hit := true
+	for i, field := range fields {
+		entry, ok := hEntry.hget(field)
+		if !ok || entry.err != nil {
+			hit = false
+			break
+		}
+		values[i] = entry.value
+	}
+	if hit {
+		return values, nil
+	}
+
+	values, err := c.backend.HMGet(key, fields[0], fields[1:]...)
+	if err != nil || !c.shouldStore() {
+		return values, err
+	}
+	if hEntry.subcache == nil {
+		hEntry.subcache = make(map[string]interface{})
+	}
+	for i, field := range fields {
+		hEntry.subcache[concatKeys("hg", field)] = readCacheGetEntry{
+			value: values[i],
+		}
+	}
+	c.store(key, hEntry)
+	return values, err
+}
+
+func (c *ReadCache) HGetAll(key string) (map[string]string, error) {
+	v, _ := c.load(key)
+	hEntry, ok := v.(readCacheHEntry)
+	if ok {
+		if entry, ok := hEntry.subcache["hga"].(readCacheHGetAllEntry); ok {
+			return copyFields(entry.fields), entry.err
+		}
+	}
+	fields, err := c.backend.HGetAll(key)
+	if !c.shouldStore() {
+		return fields, err
+	}
+	if hEntry.subcache == nil {
+		hEntry.subcache = make(map[string]interface{})
+	}
+	hEntry.subcache["hga"] = readCacheHGetAllEntry{
+		fields: copyFields(fields),
+		err:    err,
+	}
+	c.store(key, hEntry)
+	return fields, err
+}
+
+func copyFields(fields map[string]string) map[string]string {
+	if fields == nil {
+		return nil
+	}
+	ret := make(map[string]string, len(fields))
+	for k, v := range fields {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Scans are never cached.
+func (c *ReadCache) Scan(prefix, cursor string, limit int) (*keyvaluestore.ScanPage, error) {
+	return c.backend.Scan(prefix, cursor, limit)
+}
+
 func (c *ReadCache) Invalidate(key string) {
 	c.cache.Delete(key)
 }
 
+// Gets an entry from the subcache of a sorted set.
+func (c *ReadCache) loadZ(key, subkey string) (interface{}, bool) {
+	v, _ := c.load(key)
+	zEntry, ok := v.(readCacheZEntry)
+	if !ok {
+		return nil, false
+	}
+	entry, ok := zEntry.subcache[subkey]
+	return entry, ok
+}
+
+// Stores an entry in the subcache of a sorted set.
+func (c *ReadCache) storeZ(key, subkey string, entry interface{}) {
+	if !c.shouldStore() {
+		return
+	}
+	v, _ := c.load(key)
+	zEntry, _ := v.(readCacheZEntry)
+	if zEntry.subcache == nil {
+		zEntry.subcache = make(map[string]interface{})
+	}
+	zEntry.subcache[subkey] = entry
+	c.store(key, zEntry)
+}
+
 func concatKeys(s ...string) string {
 	l := 0
 	for _, s := range s {
@@ -3,6 +3,8 @@ package keyvaluestorecache_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/theaaf/keyvaluestore"
 	"github.com/theaaf/keyvaluestore/keyvaluestorecache"
 	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
@@ -14,3 +16,95 @@ func TestReadCache(t *testing.T) {
 		return keyvaluestorecache.NewReadCache(memorystore.NewBackend())
 	})
 }
+
+func TestReadCacheCapabilities(t *testing.T) {
+	backend := memorystore.NewBackend()
+	assert.False(t, backend.Capabilities().EventuallyConsistentReads)
+
+	capabilities := keyvaluestorecache.NewReadCache(backend).Capabilities()
+	assert.True(t, capabilities.EventuallyConsistentReads)
+	assert.Equal(t, backend.Capabilities().MaxAtomicWriteOperations, capabilities.MaxAtomicWriteOperations)
+}
+
+func TestReadCacheWithoutStoring(t *testing.T) {
+	backend := memorystore.NewBackend()
+	assert.NoError(t, backend.Set("foo", "bar"))
+	assert.NoError(t, backend.Set("baz", "qux"))
+
+	cache := keyvaluestorecache.NewReadCache(backend)
+	_, err := cache.Get("foo")
+	assert.NoError(t, err)
+
+	withoutStoring := cache.WithoutStoring()
+	_, err = withoutStoring.Get("baz")
+	assert.NoError(t, err)
+	_, err = withoutStoring.ZScore("z", "x")
+	assert.NoError(t, err)
+
+	assert.NoError(t, backend.Set("foo", "x"))
+	assert.NoError(t, backend.Set("baz", "x"))
+	assert.NoError(t, backend.ZAdd("z", "x", 1))
+
+	// Values that were already cached are shared, but new reads weren't cached.
+	v, err := withoutStoring.Get("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "bar", *v)
+	v, err = cache.Get("baz")
+	assert.NoError(t, err)
+	assert.Equal(t, "x", *v)
+	score, err := cache.ZScore("z", "x")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, *score)
+}
+
+func TestReadCacheZRangeLimits(t *testing.T) {
+	t.Run("ZRangeByScore", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		for i, member := range []string{"a", "b", "c", "d"} {
+			assert.NoError(t, backend.ZAdd("z", member, float64(i+1)))
+		}
+
+		cache := keyvaluestorecache.NewReadCache(backend)
+		members, err := cache.ZRangeByScore("z", 0, 10, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, members)
+
+		// Changes to the backend reveal whether the next reads are served from the cache.
+		assert.NoError(t, backend.ZAdd("z", "e", 0.5))
+
+		// A smaller limit is served from the cache and truncated.
+		members, err = cache.ZRangeByScore("z", 0, 10, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, members)
+
+		// No limit needs all of the members, which the cache doesn't have.
+		members, err = cache.ZRangeByScore("z", 0, 10, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"e", "a", "b", "c", "d"}, members)
+	})
+
+	t.Run("ZRangeByLex", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		for _, member := range []string{"b", "c", "d", "e"} {
+			assert.NoError(t, backend.ZAdd("z", member, 0))
+		}
+
+		cache := keyvaluestorecache.NewReadCache(backend)
+		members, err := cache.ZRangeByLex("z", "-", "+", 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "c", "d"}, members)
+
+		// Changes to the backend reveal whether the next reads are served from the cache.
+		assert.NoError(t, backend.ZAdd("z", "a", 0))
+
+		// A smaller limit is served from the cache and truncated.
+		members, err = cache.ZRangeByLex("z", "-", "+", 2)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b", "c"}, members)
+
+		// No limit needs all of the members, which the cache doesn't have.
+		members, err = cache.ZRangeByLex("z", "-", "+", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c", "d", "e"}, members)
+	})
+}
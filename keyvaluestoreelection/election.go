@@ -0,0 +1,225 @@
+// Package keyvaluestoreelection elects a single leader among any number of candidates using a
+// keyvaluestorelock lock.
+//
+// The leader holds the lock and renews its lease in the background. Each term is identified by the
+// lock's fencing token, so terms always increase and a deposed leader's writes can be rejected by
+// resources that remember the greatest term they've seen.
+package keyvaluestoreelection
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestorelock"
+)
+
+// Options configures an Election. The zero value is usable.
+type Options struct {
+	// How long leadership lasts without being renewed. If the leader can't reach the backend, other
+	// candidates can take over once this much time has passed. Defaults to 30 seconds.
+	LeaseDuration time.Duration
+
+	// How often the leader renews its lease. Defaults to a third of the lease duration.
+	RenewInterval time.Duration
+
+	// How often Campaign checks whether leadership is available. Defaults to 1 second.
+	RetryInterval time.Duration
+
+	// Identifies the candidate. A candidate that campaigns while it's still the leader, for example
+	// after restarting, resumes its term. If empty, a random identifier is used for each term.
+	Candidate string
+
+	// If given, this is called whenever the candidate becomes the leader or stops being the leader.
+	// It must not block.
+	OnChange func(Leadership)
+
+	// Defaults to keyvaluestore.SystemClock.
+	Clock keyvaluestore.Clock
+}
+
+// Leadership describes a candidate's leadership.
+type Leadership struct {
+	IsLeader bool
+
+	// The term that the candidate was elected for, or the term that it just lost. Terms increase
+	// with every new leader.
+	Term int64
+}
+
+// Election lets a candidate campaign for leadership of a key. It's safe for concurrent use, but
+// Campaign shouldn't be called again until the previous call returns.
+type Election struct {
+	key           string
+	locker        *keyvaluestorelock.Locker
+	renewInterval time.Duration
+	onChange      func(Leadership)
+	clock         keyvaluestore.Clock
+
+	mutex sync.Mutex
+	lock  *keyvaluestorelock.Lock
+	stop  chan struct{}
+	done  chan struct{}
+
+	// When the lease expires, as of the last successful renewal. The renewal goroutine may not
+	// notice right away, so leadership is checked against this directly.
+	expiration time.Time
+}
+
+func NewElection(b keyvaluestore.Backend, key string, opts *Options) *Election {
+	lockOptions := &keyvaluestorelock.Options{
+		RetryInterval: time.Second,
+	}
+	e := &Election{
+		key:   key,
+		clock: keyvaluestore.SystemClock,
+	}
+	if opts != nil {
+		lockOptions.LeaseDuration = opts.LeaseDuration
+		if opts.RetryInterval > 0 {
+			lockOptions.RetryInterval = opts.RetryInterval
+		}
+		lockOptions.Owner = opts.Candidate
+		lockOptions.Clock = opts.Clock
+		if opts.Clock != nil {
+			e.clock = opts.Clock
+		}
+		e.renewInterval = opts.RenewInterval
+		e.onChange = opts.OnChange
+	}
+	e.locker = keyvaluestorelock.NewLocker(b, lockOptions)
+	return e
+}
+
+// Campaign waits until the candidate is elected or the context is done, and returns the term that
+// it was elected for. While elected, the candidate renews its lease in the background until it
+// resigns or fails to renew before the lease expires.
+func (e *Election) Campaign(ctx context.Context) (int64, error) {
+	e.mutex.Lock()
+	lock, stop, done := e.lock, e.stop, e.done
+	if lock != nil && e.clock.Now().Before(e.expiration) {
+		e.mutex.Unlock()
+		return lock.FencingToken, nil
+	}
+	e.lock, e.stop, e.done = nil, nil, nil
+	e.mutex.Unlock()
+
+	if lock != nil {
+		// The lease expired before the renewal goroutine noticed.
+		close(stop)
+		<-done
+		e.notify(Leadership{
+			Term: lock.FencingToken,
+		})
+	}
+
+	lock, err := e.locker.Acquire(ctx, e.key)
+	if err != nil {
+		return 0, err
+	}
+
+	renewInterval := e.renewInterval
+	if renewInterval <= 0 {
+		renewInterval = lock.Expiration.Sub(e.clock.Now()) / 3
+	}
+	stop, done = make(chan struct{}), make(chan struct{})
+
+	e.mutex.Lock()
+	e.lock, e.stop, e.done = lock, stop, done
+	e.expiration = lock.Expiration
+	e.mutex.Unlock()
+
+	go e.renew(lock, renewInterval, stop, done)
+	e.notify(Leadership{
+		IsLeader: true,
+		Term:     lock.FencingToken,
+	})
+	return lock.FencingToken, nil
+}
+
+// Leader returns the candidate's term if it's currently the leader. Once the lease expires, the
+// candidate isn't the leader, even if it hasn't found out why its renewals are failing yet.
+func (e *Election) Leader() (int64, bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.lock == nil || !e.clock.Now().Before(e.expiration) {
+		return 0, false
+	}
+	return e.lock.FencingToken, true
+}
+
+// Resign gives up leadership so that another candidate can be elected without waiting for the lease
+// to expire. It does nothing if the candidate isn't the leader.
+func (e *Election) Resign() error {
+	e.mutex.Lock()
+	lock, stop, done := e.lock, e.stop, e.done
+	e.lock, e.stop, e.done = nil, nil, nil
+	e.mutex.Unlock()
+
+	if lock == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+	err := lock.Release()
+	e.notify(Leadership{
+		Term: lock.FencingToken,
+	})
+	if err == keyvaluestorelock.ErrNotHeld {
+		// The lease already expired, so there's nothing left to give up.
+		return nil
+	}
+	return err
+}
+
+func (e *Election) renew(lock *keyvaluestorelock.Lock, interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		// Renewals are attempted no later than the lease's expiration so that a lost lease is
+		// noticed when it expires.
+		wait := interval
+		if d := lock.Expiration.Sub(e.clock.Now()); d < wait {
+			wait = d
+		}
+		select {
+		case <-e.clock.After(wait):
+		case <-stop:
+			return
+		}
+
+		// Errors other than ErrNotHeld might be temporary, so they're retried until the lease
+		// expires.
+		if err := lock.Refresh(); err == nil {
+			e.mutex.Lock()
+			if e.lock == lock {
+				e.expiration = lock.Expiration
+			}
+			e.mutex.Unlock()
+			continue
+		} else if err != keyvaluestorelock.ErrNotHeld && e.clock.Now().Before(lock.Expiration) {
+			continue
+		}
+
+		e.mutex.Lock()
+		lost := e.lock == lock
+		if lost {
+			e.lock, e.stop, e.done = nil, nil, nil
+		}
+		e.mutex.Unlock()
+
+		if lost {
+			e.notify(Leadership{
+				Term: lock.FencingToken,
+			})
+		}
+		return
+	}
+}
+
+func (e *Election) notify(l Leadership) {
+	if e.onChange != nil {
+		e.onChange(l)
+	}
+}
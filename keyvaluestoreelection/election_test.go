@@ -0,0 +1,262 @@
+package keyvaluestoreelection_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoreelection"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+// Simulates a candidate that can't reach the backend.
+type partitionedBackend struct {
+	keyvaluestore.Backend
+	partitioned int32
+}
+
+func (b *partitionedBackend) CAS(key string, transform func(v *string) (interface{}, error)) (bool, error) {
+	if atomic.LoadInt32(&b.partitioned) != 0 {
+		return false, fmt.Errorf("partitioned")
+	}
+	return b.Backend.CAS(key, transform)
+}
+
+type observer struct {
+	mutex   sync.Mutex
+	changes []keyvaluestoreelection.Leadership
+}
+
+func (o *observer) OnChange(l keyvaluestoreelection.Leadership) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.changes = append(o.changes, l)
+}
+
+func (o *observer) Changes() []keyvaluestoreelection.Leadership {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]keyvaluestoreelection.Leadership(nil), o.changes...)
+}
+
+// Advances the clock in steps until the condition is true. The renewal goroutines wait on the clock
+// asynchronously, so a single advance might happen before they start waiting.
+func advanceUntil(t *testing.T, clock *keyvaluestoretest.FakeClock, step time.Duration, condition func() bool) {
+	for i := 0; !condition(); i++ {
+		require.True(t, i < 1000, "condition not met")
+		clock.Advance(step)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newCandidate(b keyvaluestore.Backend, clock keyvaluestore.Clock, o *observer) *keyvaluestoreelection.Election {
+	return keyvaluestoreelection.NewElection(b, "leader", &keyvaluestoreelection.Options{
+		LeaseDuration: 30 * time.Second,
+		RetryInterval: time.Second,
+		OnChange:      o.OnChange,
+		Clock:         clock,
+	})
+}
+
+func TestElection(t *testing.T) {
+	t.Run("Resign", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		clock := keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0))
+		var aObserver, bObserver observer
+		a := newCandidate(backend, clock, &aObserver)
+		b := newCandidate(backend, clock, &bObserver)
+
+		aTerm, err := a.Campaign(context.Background())
+		require.NoError(t, err)
+		term, ok := a.Leader()
+		assert.True(t, ok)
+		assert.Equal(t, aTerm, term)
+
+		// Campaigning again while elected keeps the term.
+		term, err = a.Campaign(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, aTerm, term)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = b.Campaign(ctx)
+		assert.Equal(t, context.Canceled, err)
+
+		var bTerm int64
+		campaigned := make(chan struct{})
+		go func() {
+			defer close(campaigned)
+			var err error
+			bTerm, err = b.Campaign(context.Background())
+			assert.NoError(t, err)
+		}()
+
+		// The leader keeps renewing its lease well past its original expiration.
+		start := clock.Now()
+		advanceUntil(t, clock, time.Second, func() bool {
+			return clock.Now().Sub(start) > 2*time.Minute
+		})
+		_, ok = a.Leader()
+		assert.True(t, ok)
+		_, ok = b.Leader()
+		assert.False(t, ok)
+
+		require.NoError(t, a.Resign())
+		_, ok = a.Leader()
+		assert.False(t, ok)
+		assert.NoError(t, a.Resign())
+
+		advanceUntil(t, clock, time.Second, func() bool {
+			select {
+			case <-campaigned:
+				return true
+			default:
+				return false
+			}
+		})
+		assert.True(t, bTerm > aTerm)
+		require.NoError(t, b.Resign())
+
+		assert.Equal(t, []keyvaluestoreelection.Leadership{
+			{IsLeader: true, Term: aTerm},
+			{IsLeader: false, Term: aTerm},
+		}, aObserver.Changes())
+		assert.Equal(t, []keyvaluestoreelection.Leadership{
+			{IsLeader: true, Term: bTerm},
+			{IsLeader: false, Term: bTerm},
+		}, bObserver.Changes())
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		partitioned := &partitionedBackend{
+			Backend: backend,
+		}
+		clock := keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0))
+		var aObserver, bObserver observer
+		a := newCandidate(partitioned, clock, &aObserver)
+		b := newCandidate(backend, clock, &bObserver)
+
+		aTerm, err := a.Campaign(context.Background())
+		require.NoError(t, err)
+
+		// Once the leader can't renew its lease, it steps down when the lease expires.
+		atomic.StoreInt32(&partitioned.partitioned, 1)
+		start := clock.Now()
+		advanceUntil(t, clock, time.Second, func() bool {
+			_, ok := a.Leader()
+			return !ok
+		})
+		assert.True(t, clock.Now().Sub(start) >= 30*time.Second)
+		assert.Equal(t, []keyvaluestoreelection.Leadership{
+			{IsLeader: true, Term: aTerm},
+			{IsLeader: false, Term: aTerm},
+		}, aObserver.Changes())
+
+		bTerm, err := b.Campaign(context.Background())
+		require.NoError(t, err)
+		assert.True(t, bTerm > aTerm)
+
+		// Resigning after the lease is lost is a no-op.
+		atomic.StoreInt32(&partitioned.partitioned, 0)
+		assert.NoError(t, a.Resign())
+		_, ok := b.Leader()
+		assert.True(t, ok)
+	})
+
+	t.Run("ExpirationBoundary", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		partitioned := &partitionedBackend{
+			Backend: backend,
+		}
+		clock := keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0))
+		var aObserver observer
+		a := newCandidate(partitioned, clock, &aObserver)
+		b := newCandidate(backend, clock, &observer{})
+
+		aTerm, err := a.Campaign(context.Background())
+		require.NoError(t, err)
+		expiration := clock.Now().Add(30 * time.Second)
+
+		atomic.StoreInt32(&partitioned.partitioned, 1)
+		advanceUntil(t, clock, time.Second, func() bool {
+			return !clock.Now().Before(expiration.Add(-time.Second))
+		})
+		term, ok := a.Leader()
+		assert.True(t, ok)
+		assert.Equal(t, aTerm, term)
+
+		// Leadership ends as soon as the lease expires, without waiting for the next renewal.
+		clock.Advance(time.Second)
+		_, ok = a.Leader()
+		assert.False(t, ok)
+
+		bTerm, err := b.Campaign(context.Background())
+		require.NoError(t, err)
+		assert.True(t, bTerm > aTerm)
+
+		// The renewal goroutine wakes up at the expiration to report the loss.
+		advanceUntil(t, clock, 0, func() bool {
+			return len(aObserver.Changes()) == 2
+		})
+		assert.Equal(t, []keyvaluestoreelection.Leadership{
+			{IsLeader: true, Term: aTerm},
+			{IsLeader: false, Term: aTerm},
+		}, aObserver.Changes())
+	})
+
+	t.Run("Deposed", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		clock := keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0))
+		var aObserver observer
+		a := newCandidate(backend, clock, &aObserver)
+
+		aTerm, err := a.Campaign(context.Background())
+		require.NoError(t, err)
+
+		// If the lock is lost, for example because it was deleted, the next renewal notices.
+		_, err = backend.Delete("leader")
+		require.NoError(t, err)
+		advanceUntil(t, clock, time.Second, func() bool {
+			_, ok := a.Leader()
+			return !ok
+		})
+		assert.Equal(t, []keyvaluestoreelection.Leadership{
+			{IsLeader: true, Term: aTerm},
+			{IsLeader: false, Term: aTerm},
+		}, aObserver.Changes())
+
+		term, err := a.Campaign(context.Background())
+		require.NoError(t, err)
+		assert.True(t, term > aTerm)
+	})
+
+	t.Run("Candidate", func(t *testing.T) {
+		backend := memorystore.NewBackend()
+		newElection := func() *keyvaluestoreelection.Election {
+			return keyvaluestoreelection.NewElection(backend, "leader", &keyvaluestoreelection.Options{
+				Candidate: "worker-1",
+			})
+		}
+
+		a := newElection()
+		term, err := a.Campaign(context.Background())
+		require.NoError(t, err)
+
+		// A restarted candidate resumes its term.
+		restarted := newElection()
+		restartedTerm, err := restarted.Campaign(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, term, restartedTerm)
+		require.NoError(t, restarted.Resign())
+		assert.NoError(t, a.Resign())
+	})
+}
@@ -0,0 +1,290 @@
+// Package keyvaluestorelock implements leased locks on top of any keyvaluestore.Backend.
+//
+// A lock's key holds its fencing token, the time its lease expires, and its owner's token. The key
+// is only ever changed with CAS, so a lock whose lease has expired can be taken over without
+// coordinating with its previous owner. Released locks keep their fencing tokens rather than being
+// deleted, so that each new owner's token is greater than the last.
+package keyvaluestorelock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+var (
+	// The lock is held by another owner.
+	ErrNotAcquired = errors.New("keyvaluestorelock: lock is held by another owner")
+
+	// The lock's lease expired and it was acquired by another owner, or it was released.
+	ErrNotHeld = errors.New("keyvaluestorelock: lock is no longer held")
+)
+
+// Options configures a Locker. The zero value is usable.
+type Options struct {
+	// How long a lock is held for unless it's refreshed. Defaults to 30 seconds.
+	LeaseDuration time.Duration
+
+	// How long Acquire waits between attempts. Defaults to 100 milliseconds.
+	RetryInterval time.Duration
+
+	// Identifies the owner of the locks. Acquiring a lock that the same owner already holds
+	// succeeds, which lets a process that restarts reclaim its locks. If empty, a random token is
+	// generated for each acquisition.
+	Owner string
+
+	// The clock used for leases and retries. Leases are compared against the clocks of every
+	// client, so they should be much longer than the clocks' skew. Defaults to
+	// keyvaluestore.SystemClock.
+	Clock keyvaluestore.Clock
+}
+
+// Locker acquires locks stored in a backend.
+type Locker struct {
+	backend       keyvaluestore.Backend
+	leaseDuration time.Duration
+	retryInterval time.Duration
+	owner         string
+	clock         keyvaluestore.Clock
+}
+
+func NewLocker(b keyvaluestore.Backend, opts *Options) *Locker {
+	ret := &Locker{
+		backend:       b,
+		leaseDuration: 30 * time.Second,
+		retryInterval: 100 * time.Millisecond,
+		clock:         keyvaluestore.SystemClock,
+	}
+	if opts != nil {
+		if opts.LeaseDuration > 0 {
+			ret.leaseDuration = opts.LeaseDuration
+		}
+		if opts.RetryInterval > 0 {
+			ret.retryInterval = opts.RetryInterval
+		}
+		if opts.Clock != nil {
+			ret.clock = opts.Clock
+		}
+		ret.owner = opts.Owner
+	}
+	return ret
+}
+
+// Lock is a held lock.
+type Lock struct {
+	// The key of the lock.
+	Key string
+
+	// Identifies the lock's owner.
+	Owner string
+
+	// Increases every time the lock is acquired by a new owner. Resources protected by the lock can
+	// reject writes with a lower token than they've already seen, which protects them from owners
+	// whose leases expired without them noticing.
+	FencingToken int64
+
+	// When the lease expires, as of the last acquisition or refresh.
+	Expiration time.Time
+
+	locker *Locker
+}
+
+// The fencing token counter is kept beside the lock.
+func fencingTokenKey(key string) string {
+	return key + ":fencing-token"
+}
+
+type lockValue struct {
+	fencingToken int64
+	expiration   int64
+	owner        string
+}
+
+func (v lockValue) String() string {
+	return fmt.Sprintf("%d %d %s", v.fencingToken, v.expiration, v.owner)
+}
+
+func parseLockValue(s string) (lockValue, error) {
+	parts := strings.SplitN(s, " ", 3)
+	if len(parts) != 3 {
+		return lockValue{}, fmt.Errorf("malformed lock value")
+	}
+	fencingToken, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return lockValue{}, fmt.Errorf("malformed lock fencing token")
+	}
+	expiration, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return lockValue{}, fmt.Errorf("malformed lock expiration")
+	}
+	return lockValue{
+		fencingToken: fencingToken,
+		expiration:   expiration,
+		owner:        parts[2],
+	}, nil
+}
+
+func (v lockValue) isHeld(now time.Time) bool {
+	return v.owner != "" && v.expiration > now.UnixNano()
+}
+
+func newOwnerToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// TryAcquire acquires the lock if it's available, or returns ErrNotAcquired if it isn't.
+func (l *Locker) TryAcquire(key string) (*Lock, error) {
+	owner := l.owner
+	if owner == "" {
+		var err error
+		if owner, err = newOwnerToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	var fencingToken int64
+	for {
+		// The fencing token is taken before the lock so that it can be compared with the previous
+		// owner's. If another client took a greater token but acquired the lock first, this
+		// client's token is stale and a new one is needed.
+		if fencingToken == 0 {
+			var err error
+			if fencingToken, err = l.backend.AddInt(fencingTokenKey(key), 1); err != nil {
+				return nil, err
+			}
+		}
+
+		var value lockValue
+		var heldByOther, staleToken bool
+		success, err := l.backend.CAS(key, func(prev *string) (interface{}, error) {
+			heldByOther, staleToken = false, false
+			now := l.clock.Now()
+			value = lockValue{
+				fencingToken: fencingToken,
+				expiration:   now.Add(l.leaseDuration).UnixNano(),
+				owner:        owner,
+			}
+			if prev == nil {
+				return value.String(), nil
+			}
+			prevValue, err := parseLockValue(*prev)
+			if err != nil {
+				return nil, err
+			}
+			if prevValue.owner == owner && prevValue.isHeld(now) {
+				// The owner already holds the lock, so it keeps its fencing token.
+				value.fencingToken = prevValue.fencingToken
+			} else if prevValue.isHeld(now) {
+				heldByOther = true
+				return nil, nil
+			} else if prevValue.fencingToken >= fencingToken {
+				staleToken = true
+				return nil, nil
+			}
+			return value.String(), nil
+		})
+		if err != nil {
+			return nil, err
+		} else if heldByOther {
+			return nil, ErrNotAcquired
+		} else if staleToken {
+			fencingToken = 0
+			continue
+		} else if !success {
+			// The lock changed while the transform was running.
+			continue
+		}
+
+		return &Lock{
+			Key:          key,
+			Owner:        owner,
+			FencingToken: value.fencingToken,
+			Expiration:   time.Unix(0, value.expiration),
+			locker:       l,
+		}, nil
+	}
+}
+
+// Acquire waits until the lock can be acquired or the context is done.
+func (l *Locker) Acquire(ctx context.Context, key string) (*Lock, error) {
+	for {
+		lock, err := l.TryAcquire(key)
+		if err != ErrNotAcquired {
+			return lock, err
+		}
+		select {
+		case <-l.clock.After(l.retryInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Modifies the lock if it's still held by its owner. The transform returns the new value.
+func (lock *Lock) update(transform func(now time.Time, v lockValue) lockValue) (lockValue, error) {
+	l := lock.locker
+	var value lockValue
+	notHeld := false
+	for {
+		success, err := l.backend.CAS(lock.Key, func(prev *string) (interface{}, error) {
+			notHeld = false
+			if prev == nil {
+				notHeld = true
+				return nil, nil
+			}
+			prevValue, err := parseLockValue(*prev)
+			if err != nil {
+				return nil, err
+			}
+			now := l.clock.Now()
+			if prevValue.owner != lock.Owner || prevValue.fencingToken != lock.FencingToken || !prevValue.isHeld(now) {
+				notHeld = true
+				return nil, nil
+			}
+			value = transform(now, prevValue)
+			return value.String(), nil
+		})
+		if err != nil {
+			return value, err
+		} else if notHeld {
+			return value, ErrNotHeld
+		} else if success {
+			return value, nil
+		}
+	}
+}
+
+// Refresh extends the lease so that it expires a full lease duration from now. It returns
+// ErrNotHeld if the lease has already expired.
+func (lock *Lock) Refresh() error {
+	value, err := lock.update(func(now time.Time, v lockValue) lockValue {
+		v.expiration = now.Add(lock.locker.leaseDuration).UnixNano()
+		return v
+	})
+	if err == nil {
+		lock.Expiration = time.Unix(0, value.expiration)
+	}
+	return err
+}
+
+// Release makes the lock available to other owners. It returns ErrNotHeld if the lease has
+// already expired.
+func (lock *Lock) Release() error {
+	_, err := lock.update(func(now time.Time, v lockValue) lockValue {
+		return lockValue{
+			fencingToken: v.fencingToken,
+		}
+	})
+	return err
+}
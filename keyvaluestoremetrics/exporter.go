@@ -0,0 +1,280 @@
+// Package keyvaluestoremetrics exports keyvaluestore profiling data in the Prometheus text
+// exposition format.
+package keyvaluestoremetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/dynamodbstore"
+	"github.com/theaaf/keyvaluestore/redisstore"
+)
+
+// DefaultBuckets are the upper bounds of the latency histogram buckets, in seconds.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Exporter collects metrics and serves them to Prometheus. It can be used as a Redis profiler, a
+// DynamoDB profiler, and an interceptor for keyvaluestore.InstrumentedBackend, so any combination
+// of the three can be exported together.
+type Exporter struct {
+	// The upper bounds of the latency histogram buckets, in seconds. If nil, DefaultBuckets is
+	// used. It must not be changed once the exporter is in use.
+	Buckets []float64
+
+	initOnce sync.Once
+	mutex    sync.Mutex
+	families []*metricFamily
+
+	operations         *metricFamily
+	operationErrors    *metricFamily
+	operationDurations *metricFamily
+
+	redisCommands         *metricFamily
+	redisRoundTrips       *metricFamily
+	redisRequestDurations *metricFamily
+
+	dynamoDBRequests              *metricFamily
+	dynamoDBRequestDurations      *metricFamily
+	dynamoDBReadCapacityConsumed  *metricFamily
+	dynamoDBWriteCapacityConsumed *metricFamily
+}
+
+var _ redisstore.Profiler = (*Exporter)(nil)
+var _ dynamodbstore.Profiler = (*Exporter)(nil)
+var _ keyvaluestore.Interceptor = (*Exporter)(nil)
+var _ http.Handler = (*Exporter)(nil)
+
+const (
+	metricTypeCounter   = "counter"
+	metricTypeHistogram = "histogram"
+)
+
+type metricFamily struct {
+	name       string
+	help       string
+	metricType string
+	labelName  string
+
+	counters   map[string]float64
+	histograms map[string]*histogram
+}
+
+type histogram struct {
+	// The number of observations in each bucket, not including those in lower buckets.
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+func (e *Exporter) init() {
+	e.initOnce.Do(e.initFamilies)
+}
+
+func (e *Exporter) initFamilies() {
+	family := func(name, metricType, labelName, help string) *metricFamily {
+		f := &metricFamily{
+			name:       "keyvaluestore_" + name,
+			help:       help,
+			metricType: metricType,
+			labelName:  labelName,
+			counters:   map[string]float64{},
+			histograms: map[string]*histogram{},
+		}
+		e.families = append(e.families, f)
+		return f
+	}
+
+	e.operations = family("operations_total", metricTypeCounter, "method", "Backend operations performed.")
+	e.operationErrors = family("operation_errors_total", metricTypeCounter, "method", "Backend operations that returned an error.")
+	e.operationDurations = family("operation_duration_seconds", metricTypeHistogram, "method", "Latency of backend operations.")
+
+	e.redisCommands = family("redis_commands_total", metricTypeCounter, "command", "Redis commands sent, including those in pipelines.")
+	e.redisRoundTrips = family("redis_round_trips_total", metricTypeCounter, "", "Redis round trips made.")
+	e.redisRequestDurations = family("redis_request_duration_seconds", metricTypeHistogram, "operation", "Latency of Redis commands and pipelines.")
+
+	e.dynamoDBRequests = family("dynamodb_requests_total", metricTypeCounter, "operation", "DynamoDB requests made.")
+	e.dynamoDBRequestDurations = family("dynamodb_request_duration_seconds", metricTypeHistogram, "operation", "Latency of DynamoDB requests.")
+	e.dynamoDBReadCapacityConsumed = family("dynamodb_consumed_read_capacity_units_total", metricTypeCounter, "", "DynamoDB read capacity units consumed.")
+	e.dynamoDBWriteCapacityConsumed = family("dynamodb_consumed_write_capacity_units_total", metricTypeCounter, "", "DynamoDB write capacity units consumed.")
+}
+
+func (e *Exporter) buckets() []float64 {
+	if e.Buckets == nil {
+		return DefaultBuckets
+	}
+	return e.Buckets
+}
+
+func (e *Exporter) add(f *metricFamily, label string, n float64) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	f.counters[label] += n
+}
+
+func (e *Exporter) observe(f *metricFamily, label string, duration time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	buckets := e.buckets()
+	h, ok := f.histograms[label]
+	if !ok {
+		h = &histogram{
+			bucketCounts: make([]uint64, len(buckets)),
+		}
+		f.histograms[label] = h
+	}
+
+	seconds := duration.Seconds()
+	h.count++
+	h.sum += seconds
+	if i := sort.SearchFloat64s(buckets, seconds); i < len(buckets) {
+		h.bucketCounts[i]++
+	}
+}
+
+func (e *Exporter) Before(op *keyvaluestore.Operation) {}
+
+func (e *Exporter) After(op *keyvaluestore.Operation, duration time.Duration, err error) {
+	e.init()
+	e.add(e.operations, op.Name, 1)
+	if err != nil {
+		e.add(e.operationErrors, op.Name, 1)
+	}
+	e.observe(e.operationDurations, op.Name, duration)
+}
+
+func (e *Exporter) AddRedisCommandProfile(cmd redis.Cmder, duration time.Duration) {
+	e.init()
+	e.add(e.redisCommands, cmd.Name(), 1)
+	e.add(e.redisRoundTrips, "", 1)
+	e.observe(e.redisRequestDurations, cmd.Name(), duration)
+}
+
+func (e *Exporter) AddRedisPipelineProfile(cmds []redis.Cmder, duration time.Duration) {
+	e.init()
+	for _, cmd := range cmds {
+		e.add(e.redisCommands, cmd.Name(), 1)
+	}
+	e.add(e.redisRoundTrips, "", 1)
+	e.observe(e.redisRequestDurations, "pipeline", duration)
+}
+
+func (e *Exporter) ConsumeDynamoDBReadCapacity(capacity float64) {
+	e.init()
+	e.add(e.dynamoDBReadCapacityConsumed, "", capacity)
+}
+
+func (e *Exporter) ConsumeDynamoDBWriteCapacity(capacity float64) {
+	e.init()
+	e.add(e.dynamoDBWriteCapacityConsumed, "", capacity)
+}
+
+func (e *Exporter) AddDynamoDBRequestProfile(operationName string, duration time.Duration) {
+	e.init()
+	e.add(e.dynamoDBRequests, operationName, 1)
+	e.observe(e.dynamoDBRequestDurations, operationName, duration)
+}
+
+// Serves the metrics in the Prometheus text exposition format.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.WriteTo(w)
+}
+
+// Writes the metrics in the Prometheus text exposition format.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	e.init()
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	buckets := e.buckets()
+
+	for _, f := range e.families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.metricType)
+
+		switch f.metricType {
+		case metricTypeCounter:
+			if f.labelName == "" {
+				fmt.Fprintf(bw, "%s %s\n", f.name, formatFloat(f.counters[""]))
+				continue
+			}
+			for _, label := range sortedKeys(f.counters) {
+				fmt.Fprintf(bw, "%s{%s} %s\n", f.name, formatLabel(f.labelName, label), formatFloat(f.counters[label]))
+			}
+		case metricTypeHistogram:
+			labels := make([]string, 0, len(f.histograms))
+			for label := range f.histograms {
+				labels = append(labels, label)
+			}
+			sort.Strings(labels)
+
+			for _, label := range labels {
+				h := f.histograms[label]
+				labelPair := formatLabel(f.labelName, label)
+				var cumulative uint64
+				for i, bound := range buckets {
+					cumulative += h.bucketCounts[i]
+					fmt.Fprintf(bw, "%s_bucket{%s,le=\"%s\"} %d\n", f.name, labelPair, formatFloat(bound), cumulative)
+				}
+				fmt.Fprintf(bw, "%s_bucket{%s,le=\"+Inf\"} %d\n", f.name, labelPair, h.count)
+				fmt.Fprintf(bw, "%s_sum{%s} %s\n", f.name, labelPair, formatFloat(h.sum))
+				fmt.Fprintf(bw, "%s_count{%s} %d\n", f.name, labelPair, h.count)
+			}
+		}
+	}
+
+	err := bw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabel(name, value string) string {
+	return name + `="` + labelValueReplacer.Replace(value) + `"`
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,141 @@
+package keyvaluestoremetrics_test
+
+import (
+	"bufio"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoremetrics"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+var sampleRegexp = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{.*\})? (\S+)$`)
+
+// Parses the text exposition format into a map of samples keyed by name and labels, verifying that
+// every sample belongs to a family declared with a TYPE comment.
+func parseMetrics(t *testing.T, r io.Reader) map[string]float64 {
+	samples := map[string]float64{}
+	types := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			fields := strings.Fields(line)
+			require.Len(t, fields, 4, line)
+			types[fields[2]] = fields[3]
+			continue
+		} else if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+
+		match := sampleRegexp.FindStringSubmatch(line)
+		require.NotNil(t, match, "invalid sample: %v", line)
+
+		name := match[1]
+		family := name
+		if types[family] == "" {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				family = strings.TrimSuffix(family, suffix)
+				if types[family] == "histogram" {
+					break
+				}
+			}
+		}
+		require.NotEmpty(t, types[family], "sample without type: %v", line)
+
+		value, err := strconv.ParseFloat(match[3], 64)
+		require.NoError(t, err)
+		samples[name+match[2]] = value
+	}
+	require.NoError(t, scanner.Err())
+	return samples
+}
+
+func TestExporter(t *testing.T) {
+	exporter := &keyvaluestoremetrics.Exporter{
+		Buckets: []float64{0.01, 0.1, 1},
+	}
+
+	b := keyvaluestore.NewInstrumentedBackend(memorystore.NewBackend(), exporter)
+	require.NoError(t, b.Set("foo", "bar"))
+	_, err := b.Get("foo")
+	require.NoError(t, err)
+	_, err = b.AddInt("foo", 1)
+	require.Error(t, err)
+
+	exporter.AddRedisCommandProfile(redis.NewStringCmd("get", "foo"), 5*time.Millisecond)
+	exporter.AddRedisPipelineProfile([]redis.Cmder{
+		redis.NewStringCmd("get", "foo"),
+		redis.NewStatusCmd("set", "foo", "bar"),
+	}, 50*time.Millisecond)
+
+	exporter.AddDynamoDBRequestProfile("GetItem", 20*time.Millisecond)
+	exporter.AddDynamoDBRequestProfile("GetItem", 2*time.Second)
+	exporter.ConsumeDynamoDBReadCapacity(0.5)
+	exporter.ConsumeDynamoDBReadCapacity(1)
+	exporter.ConsumeDynamoDBWriteCapacity(2)
+
+	w := httptest.NewRecorder()
+	exporter.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+
+	samples := parseMetrics(t, w.Body)
+
+	assert.Equal(t, 1.0, samples[`keyvaluestore_operations_total{method="Get"}`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_operation_errors_total{method="AddInt"}`])
+	assert.NotContains(t, samples, `keyvaluestore_operation_errors_total{method="Get"}`)
+	assert.Equal(t, 1.0, samples[`keyvaluestore_operation_duration_seconds_count{method="Set"}`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_operation_duration_seconds_bucket{method="Set",le="+Inf"}`])
+
+	assert.Equal(t, 2.0, samples[`keyvaluestore_redis_commands_total{command="get"}`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_redis_commands_total{command="set"}`])
+	assert.Equal(t, 2.0, samples[`keyvaluestore_redis_round_trips_total`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_redis_request_duration_seconds_bucket{operation="get",le="0.01"}`])
+	assert.Equal(t, 0.0, samples[`keyvaluestore_redis_request_duration_seconds_bucket{operation="pipeline",le="0.01"}`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_redis_request_duration_seconds_bucket{operation="pipeline",le="0.1"}`])
+
+	assert.Equal(t, 2.0, samples[`keyvaluestore_dynamodb_requests_total{operation="GetItem"}`])
+	assert.Equal(t, 0.0, samples[`keyvaluestore_dynamodb_request_duration_seconds_bucket{operation="GetItem",le="0.01"}`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_dynamodb_request_duration_seconds_bucket{operation="GetItem",le="0.1"}`])
+	assert.Equal(t, 1.0, samples[`keyvaluestore_dynamodb_request_duration_seconds_bucket{operation="GetItem",le="1"}`])
+	assert.Equal(t, 2.0, samples[`keyvaluestore_dynamodb_request_duration_seconds_bucket{operation="GetItem",le="+Inf"}`])
+	assert.InDelta(t, 2.02, samples[`keyvaluestore_dynamodb_request_duration_seconds_sum{operation="GetItem"}`], 1e-9)
+	assert.Equal(t, 1.5, samples[`keyvaluestore_dynamodb_consumed_read_capacity_units_total`])
+	assert.Equal(t, 2.0, samples[`keyvaluestore_dynamodb_consumed_write_capacity_units_total`])
+}
+
+func TestExporterEmpty(t *testing.T) {
+	var buf strings.Builder
+	_, err := (&keyvaluestoremetrics.Exporter{}).WriteTo(&buf)
+	require.NoError(t, err)
+
+	samples := parseMetrics(t, strings.NewReader(buf.String()))
+	assert.Equal(t, map[string]float64{
+		"keyvaluestore_redis_round_trips_total":                      0,
+		"keyvaluestore_dynamodb_consumed_read_capacity_units_total":  0,
+		"keyvaluestore_dynamodb_consumed_write_capacity_units_total": 0,
+	}, samples)
+}
+
+func TestExporterLabelEscaping(t *testing.T) {
+	exporter := &keyvaluestoremetrics.Exporter{}
+	exporter.AddDynamoDBRequestProfile("a\"b\\c\nd", time.Millisecond)
+
+	var buf strings.Builder
+	_, err := exporter.WriteTo(&buf)
+	require.NoError(t, err)
+
+	samples := parseMetrics(t, strings.NewReader(buf.String()))
+	assert.Equal(t, 1.0, samples[`keyvaluestore_dynamodb_requests_total{operation="a\"b\\c\nd"}`])
+}
@@ -0,0 +1,109 @@
+package keyvaluestoreratelimit
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/redisstore"
+)
+
+// FixedWindow counts requests in consecutive windows, starting a new count at the beginning of each
+// one. Each window's count is kept at its own key, which expires along with the window.
+type FixedWindow struct {
+	limiter
+}
+
+var _ Limiter = (*FixedWindow)(nil)
+
+func NewFixedWindow(b keyvaluestore.Backend, opts Options) *FixedWindow {
+	return &FixedWindow{
+		limiter: newLimiter(b, opts),
+	}
+}
+
+func (l *FixedWindow) Allow(key string) (*Result, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *FixedWindow) AllowN(key string, n int64) (*Result, error) {
+	if err := l.check(n); err != nil {
+		return nil, err
+	}
+
+	now := l.clock.Now()
+	windowIndex := now.UnixNano() / int64(l.window)
+	windowKey := l.prefix + key + ":" + strconv.FormatInt(windowIndex, 10)
+	resetAt := time.Unix(0, (windowIndex+1)*int64(l.window))
+
+	var allowed bool
+	var count int64
+	var err error
+	if rb, ok := l.backend.(*redisstore.Backend); ok {
+		allowed, count, err = l.allowRedis(rb, windowKey, n)
+	} else {
+		allowed, count, err = l.allow(windowKey, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Result{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: l.limit - count,
+		ResetAt:   resetAt,
+	}
+	if ret.Remaining < 0 {
+		// Requests that were since refunded may still be counted.
+		ret.Remaining = 0
+	}
+	if !allowed {
+		ret.RetryAfter = nonNegative(resetAt.Sub(now))
+	}
+	return ret, nil
+}
+
+// Counts the requests, then refunds them if they exceed the limit. Concurrent requests may briefly
+// see each other's refunded counts, so they may be denied when they'd fit, but the limit is never
+// exceeded.
+func (l *FixedWindow) allow(windowKey string, n int64) (bool, int64, error) {
+	count, err := l.backend.AddInt(windowKey, n)
+	if err != nil {
+		return false, 0, err
+	}
+	if count == n {
+		// This is the window's first request. The key only needs to outlive the window.
+		if _, err := l.backend.Expire(windowKey, l.window); err != nil {
+			return false, 0, err
+		}
+	}
+	if count <= l.limit {
+		return true, count, nil
+	}
+	count, err = l.backend.AddInt(windowKey, -n)
+	return false, count, err
+}
+
+var fixedWindowScript = redis.NewScript(`
+local key, n, limit, ttl = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), ARGV[3]
+local count = redis.call('incrby', key, n)
+if count == n then
+	redis.call('pexpire', key, ttl)
+end
+if count > limit then
+	return {0, redis.call('decrby', key, n)}
+end
+return {1, count}
+`)
+
+func (l *FixedWindow) allowRedis(b *redisstore.Backend, windowKey string, n int64) (bool, int64, error) {
+	result, err := b.RunScript(fixedWindowScript, []string{windowKey}, n, l.limit, milliseconds(l.window))
+	if err != nil {
+		return false, 0, err
+	}
+	values := result.([]interface{})
+	return values[0].(int64) == 1, values[1].(int64), nil
+}
@@ -0,0 +1,134 @@
+// Package keyvaluestoreratelimit implements rate limiters on top of any keyvaluestore.Backend.
+//
+// Three algorithms are available. Fixed windows are the cheapest, but allow bursts of up to twice
+// the limit around the boundaries between windows. Sliding window logs are exact, but store an
+// entry for every request in the window. Token buckets are exact and store a single value, but
+// allow bursts of up to the full limit.
+//
+// Limiters for redisstore backends check limits with a single script instead of several commands.
+// This only applies when the limiter is given a *redisstore.Backend itself. Backends that wrap one,
+// such as prefixed, instrumented, or circuit breaker backends, use the generic implementations,
+// which enforce the same limits with more round trips.
+package keyvaluestoreratelimit
+
+import (
+	"errors"
+	"time"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+// ErrExceedsLimit is returned when more requests are made at once than the limit allows, so they
+// could never be allowed.
+var ErrExceedsLimit = errors.New("keyvaluestoreratelimit: request count exceeds the limit")
+
+// Options configures a limiter. Limit and Window are required. The constructors panic if Limit isn't
+// positive or Window is less than a microsecond.
+type Options struct {
+	// The number of requests allowed per window.
+	Limit int64
+
+	// The period that the limit applies to. Token buckets refill at a rate of Limit tokens per
+	// Window.
+	Window time.Duration
+
+	// Prepended to the keys given to the limiter to form the keys that its state is stored at.
+	// Defaults to "ratelimit:".
+	Prefix string
+
+	// The clock used to divide time into windows. Limits are shared by every client, so their clocks
+	// should agree to well within the window. Defaults to keyvaluestore.SystemClock.
+	Clock keyvaluestore.Clock
+}
+
+// Result describes the outcome of a request.
+type Result struct {
+	// True if the request was allowed and counted against the limit.
+	Allowed bool
+
+	// The number of requests allowed per window.
+	Limit int64
+
+	// The number of requests that can still be made right away.
+	Remaining int64
+
+	// When the full limit will be available again if no more requests are made.
+	ResetAt time.Time
+
+	// If the request wasn't allowed, how long to wait before trying it again.
+	RetryAfter time.Duration
+}
+
+// Limiter limits the rate of requests for each key.
+type Limiter interface {
+	// Allow is shorthand for AllowN(key, 1).
+	Allow(key string) (*Result, error)
+
+	// AllowN checks whether n requests can be made now, and counts them if they can. Requests that
+	// aren't allowed aren't counted.
+	AllowN(key string, n int64) (*Result, error)
+}
+
+type limiter struct {
+	backend keyvaluestore.Backend
+	limit   int64
+	window  time.Duration
+	prefix  string
+	clock   keyvaluestore.Clock
+}
+
+func newLimiter(b keyvaluestore.Backend, opts Options) limiter {
+	if opts.Limit <= 0 {
+		panic("keyvaluestoreratelimit: Limit must be positive")
+	} else if opts.Window < time.Microsecond {
+		// Times are stored as whole microseconds.
+		panic("keyvaluestoreratelimit: Window must be at least a microsecond")
+	}
+
+	ret := limiter{
+		backend: b,
+		limit:   opts.Limit,
+		window:  opts.Window,
+		prefix:  opts.Prefix,
+		clock:   opts.Clock,
+	}
+	if ret.prefix == "" {
+		ret.prefix = "ratelimit:"
+	}
+	if ret.clock == nil {
+		ret.clock = keyvaluestore.SystemClock
+	}
+	return ret
+}
+
+func (l *limiter) check(n int64) error {
+	if n > l.limit {
+		return ErrExceedsLimit
+	}
+	return nil
+}
+
+// Times are stored as whole microseconds, which float64 scores can represent exactly.
+func microseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Microsecond)
+}
+
+func fromMicroseconds(us int64) time.Time {
+	return time.Unix(0, us*int64(time.Microsecond))
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// Redis expirations are in whole milliseconds, so they're rounded up.
+func milliseconds(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+func (l *limiter) windowMicroseconds() int64 {
+	return int64(l.window / time.Microsecond)
+}
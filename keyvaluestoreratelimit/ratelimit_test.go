@@ -0,0 +1,294 @@
+package keyvaluestoreratelimit_test
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoreratelimit"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+	"github.com/theaaf/keyvaluestore/memorystore"
+	"github.com/theaaf/keyvaluestore/redisstore"
+)
+
+// Uses a different database than the redisstore tests, which may run at the same time.
+func newRedisTestClient() *redis.Client {
+	addr := os.Getenv("REDIS_ADDRESS")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr: addr,
+		DB:   2,
+	})
+	if err := client.Ping().Err(); err != nil {
+		return nil
+	}
+	return client
+}
+
+// Runs the test against memorystore, which uses the generic implementations, and against Redis,
+// which uses scripts.
+func testBackends(t *testing.T, f func(t *testing.T, b keyvaluestore.Backend, clock *keyvaluestoretest.FakeClock)) {
+	// The start of a minute, so that fixed windows line up with it.
+	start := time.Unix(1500000000, 0)
+
+	t.Run("Memory", func(t *testing.T) {
+		clock := keyvaluestoretest.NewFakeClock(start)
+		f(t, memorystore.NewBackendWithClock(clock), clock)
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		client := newRedisTestClient()
+		if client == nil {
+			t.Skip("no redis server available")
+		}
+		require.NoError(t, client.FlushDB().Err())
+		f(t, &redisstore.Backend{
+			Client: client,
+		}, keyvaluestoretest.NewFakeClock(start))
+	})
+}
+
+func TestFixedWindow(t *testing.T) {
+	testBackends(t, func(t *testing.T, b keyvaluestore.Backend, clock *keyvaluestoretest.FakeClock) {
+		start := clock.Now()
+		limiter := keyvaluestoreratelimit.NewFixedWindow(b, keyvaluestoreratelimit.Options{
+			Limit:  3,
+			Window: time.Minute,
+			Clock:  clock,
+		})
+
+		clock.Advance(10 * time.Second)
+		for i := int64(1); i <= 3; i++ {
+			result, err := limiter.Allow("user")
+			require.NoError(t, err)
+			assert.Equal(t, &keyvaluestoreratelimit.Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 3 - i,
+				ResetAt:   start.Add(time.Minute),
+			}, result)
+		}
+
+		result, err := limiter.Allow("user")
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Limit:      3,
+			ResetAt:    start.Add(time.Minute),
+			RetryAfter: 50 * time.Second,
+		}, result)
+
+		_, err = limiter.AllowN("user", 4)
+		assert.Equal(t, keyvaluestoreratelimit.ErrExceedsLimit, err)
+
+		// Other keys have their own limits.
+		result, err = limiter.AllowN("other", 3)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+
+		// The count starts over with the next window.
+		clock.Advance(50 * time.Second)
+		result, err = limiter.AllowN("user", 2)
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Allowed:   true,
+			Limit:     3,
+			Remaining: 1,
+			ResetAt:   start.Add(2 * time.Minute),
+		}, result)
+
+		// Denied requests aren't counted.
+		result, err = limiter.AllowN("user", 2)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		result, err = limiter.Allow("user")
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+}
+
+func TestSlidingWindowLog(t *testing.T) {
+	testBackends(t, func(t *testing.T, b keyvaluestore.Backend, clock *keyvaluestoretest.FakeClock) {
+		start := clock.Now()
+		limiter := keyvaluestoreratelimit.NewSlidingWindowLog(b, keyvaluestoreratelimit.Options{
+			Limit:  3,
+			Window: time.Minute,
+			Clock:  clock,
+		})
+
+		for i := int64(1); i <= 3; i++ {
+			result, err := limiter.Allow("user")
+			require.NoError(t, err)
+			assert.Equal(t, &keyvaluestoreratelimit.Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 3 - i,
+				ResetAt:   clock.Now().Add(time.Minute),
+			}, result)
+			clock.Advance(10 * time.Second)
+		}
+
+		// The first request leaves the window a minute after it was made.
+		result, err := limiter.Allow("user")
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Limit:      3,
+			ResetAt:    start.Add(80 * time.Second),
+			RetryAfter: 30 * time.Second,
+		}, result)
+
+		// Two requests need the first two to leave the window.
+		result, err = limiter.AllowN("user", 2)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 40*time.Second, result.RetryAfter)
+
+		_, err = limiter.AllowN("user", 4)
+		assert.Equal(t, keyvaluestoreratelimit.ErrExceedsLimit, err)
+
+		clock.Advance(30 * time.Second)
+		result, err = limiter.Allow("user")
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Allowed:   true,
+			Limit:     3,
+			Remaining: 0,
+			ResetAt:   clock.Now().Add(time.Minute),
+		}, result)
+
+		clock.Advance(5 * time.Minute)
+		result, err = limiter.AllowN("user", 3)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.EqualValues(t, 0, result.Remaining)
+	})
+}
+
+func TestTokenBucket(t *testing.T) {
+	testBackends(t, func(t *testing.T, b keyvaluestore.Backend, clock *keyvaluestoretest.FakeClock) {
+		limiter := keyvaluestoreratelimit.NewTokenBucket(b, keyvaluestoreratelimit.Options{
+			Limit:  10,
+			Window: 10 * time.Second,
+			Clock:  clock,
+		})
+
+		result, err := limiter.AllowN("user", 10)
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Allowed:   true,
+			Limit:     10,
+			Remaining: 0,
+			ResetAt:   clock.Now().Add(10 * time.Second),
+		}, result)
+
+		// Tokens are refilled at one per second.
+		clock.Advance(500 * time.Millisecond)
+		result, err = limiter.Allow("user")
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Limit:      10,
+			ResetAt:    clock.Now().Add(9500 * time.Millisecond),
+			RetryAfter: 500 * time.Millisecond,
+		}, result)
+
+		result, err = limiter.AllowN("user", 2)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 1500*time.Millisecond, result.RetryAfter)
+
+		_, err = limiter.AllowN("user", 11)
+		assert.Equal(t, keyvaluestoreratelimit.ErrExceedsLimit, err)
+
+		clock.Advance(2500 * time.Millisecond)
+		result, err = limiter.AllowN("user", 2)
+		require.NoError(t, err)
+		assert.Equal(t, &keyvaluestoreratelimit.Result{
+			Allowed:   true,
+			Limit:     10,
+			Remaining: 1,
+			ResetAt:   clock.Now().Add(9 * time.Second),
+		}, result)
+
+		// The bucket never holds more than the limit.
+		clock.Advance(time.Minute)
+		result, err = limiter.Allow("user")
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.EqualValues(t, 9, result.Remaining)
+	})
+}
+
+var limiterConstructors = map[string]func(b keyvaluestore.Backend, opts keyvaluestoreratelimit.Options) keyvaluestoreratelimit.Limiter{
+	"FixedWindow": func(b keyvaluestore.Backend, opts keyvaluestoreratelimit.Options) keyvaluestoreratelimit.Limiter {
+		return keyvaluestoreratelimit.NewFixedWindow(b, opts)
+	},
+	"SlidingWindowLog": func(b keyvaluestore.Backend, opts keyvaluestoreratelimit.Options) keyvaluestoreratelimit.Limiter {
+		return keyvaluestoreratelimit.NewSlidingWindowLog(b, opts)
+	},
+	"TokenBucket": func(b keyvaluestore.Backend, opts keyvaluestoreratelimit.Options) keyvaluestoreratelimit.Limiter {
+		return keyvaluestoreratelimit.NewTokenBucket(b, opts)
+	},
+}
+
+func TestInvalidOptions(t *testing.T) {
+	for name, newLimiter := range limiterConstructors {
+		newLimiter := newLimiter
+		t.Run(name, func(t *testing.T) {
+			b := memorystore.NewBackend()
+			for _, opts := range []keyvaluestoreratelimit.Options{
+				{Window: time.Minute},
+				{Limit: -1, Window: time.Minute},
+				{Limit: 10},
+				{Limit: 10, Window: time.Nanosecond},
+			} {
+				assert.Panics(t, func() {
+					newLimiter(b, opts)
+				})
+			}
+		})
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	for name, newLimiter := range limiterConstructors {
+		newLimiter := newLimiter
+		t.Run(name, func(t *testing.T) {
+			testBackends(t, func(t *testing.T, b keyvaluestore.Backend, clock *keyvaluestoretest.FakeClock) {
+				limiter := newLimiter(b, keyvaluestoreratelimit.Options{
+					Limit:  10,
+					Window: time.Minute,
+					Clock:  clock,
+				})
+
+				var mutex sync.Mutex
+				allowed := 0
+				var wg sync.WaitGroup
+				for i := 0; i < 20; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						result, err := limiter.Allow("user")
+						if assert.NoError(t, err) && result.Allowed {
+							mutex.Lock()
+							allowed++
+							mutex.Unlock()
+						}
+					}()
+				}
+				wg.Wait()
+
+				// Concurrent requests may be denied while others are being refunded, but the limit
+				// is never exceeded.
+				assert.True(t, allowed > 0)
+				assert.True(t, allowed <= 10)
+			})
+		})
+	}
+}
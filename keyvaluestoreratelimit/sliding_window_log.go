@@ -0,0 +1,180 @@
+package keyvaluestoreratelimit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"strconv"
+
+	"github.com/go-redis/redis"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/redisstore"
+)
+
+// SlidingWindowLog records the time of every request in a sorted set and counts the ones within the
+// last window.
+type SlidingWindowLog struct {
+	limiter
+}
+
+var _ Limiter = (*SlidingWindowLog)(nil)
+
+func NewSlidingWindowLog(b keyvaluestore.Backend, opts Options) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		limiter: newLimiter(b, opts),
+	}
+}
+
+func (l *SlidingWindowLog) Allow(key string) (*Result, error) {
+	return l.AllowN(key, 1)
+}
+
+// The outcome of a request, in microseconds.
+type slidingWindowLogResult struct {
+	allowed bool
+
+	// The number of requests in the window, including these ones if they were allowed.
+	count int64
+
+	// If the requests weren't allowed, the time of the request that must leave the window before
+	// they can be.
+	retryAt int64
+
+	// The time of the latest request in the window.
+	latest int64
+}
+
+func (l *SlidingWindowLog) AllowN(key string, n int64) (*Result, error) {
+	if err := l.check(n); err != nil {
+		return nil, err
+	}
+
+	// Each request needs a distinct member, even if other clients make requests at the same time.
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	memberPrefix := hex.EncodeToString(buf) + ":"
+
+	now := microseconds(l.clock.Now())
+	logKey := l.prefix + key
+
+	var result slidingWindowLogResult
+	var err error
+	if rb, ok := l.backend.(*redisstore.Backend); ok {
+		result, err = l.allowRedis(rb, logKey, now, n, memberPrefix)
+	} else {
+		result, err = l.allow(logKey, now, n, memberPrefix)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Result{
+		Allowed:   result.allowed,
+		Limit:     l.limit,
+		Remaining: l.limit - result.count,
+		ResetAt:   fromMicroseconds(result.latest).Add(l.window),
+	}
+	if ret.Remaining < 0 {
+		ret.Remaining = 0
+	}
+	if !result.allowed {
+		ret.RetryAfter = nonNegative(fromMicroseconds(result.retryAt).Add(l.window).Sub(fromMicroseconds(now)))
+	}
+	return ret, nil
+}
+
+// Adds the requests to the log, then removes them again if the log has too many entries. Concurrent
+// requests may see each other's removed entries, so they may be denied when they'd fit, but the limit
+// is never exceeded.
+func (l *SlidingWindowLog) allow(logKey string, now, n int64, memberPrefix string) (slidingWindowLogResult, error) {
+	var result slidingWindowLogResult
+
+	// Requests at or before this time have left the window. Times are whole microseconds, so
+	// requests within the window are at least one greater.
+	expired := float64(now - l.windowMicroseconds())
+
+	old, err := l.backend.ZRangeByScore(logKey, math.Inf(-1), expired, 0)
+	if err != nil {
+		return result, err
+	}
+	for _, member := range old {
+		if err := l.backend.ZRem(logKey, member); err != nil {
+			return result, err
+		}
+	}
+
+	members := make([]string, n)
+	for i := range members {
+		members[i] = memberPrefix + strconv.Itoa(i)
+		if err := l.backend.ZAdd(logKey, members[i], float64(now)); err != nil {
+			return result, err
+		}
+	}
+	if _, err := l.backend.Expire(logKey, l.window); err != nil {
+		return result, err
+	}
+
+	count, err := l.backend.ZCount(logKey, expired+1, math.Inf(1))
+	if err != nil {
+		return result, err
+	}
+	result.count = int64(count)
+	result.latest = now
+	if result.count <= l.limit {
+		result.allowed = true
+		return result, nil
+	}
+
+	for _, member := range members {
+		if err := l.backend.ZRem(logKey, member); err != nil {
+			return result, err
+		}
+	}
+
+	entries, err := l.backend.ZRangeByScoreWithScores(logKey, expired+1, math.Inf(1), 0)
+	if err != nil {
+		return result, err
+	}
+	result.count = int64(len(entries))
+	if excess := result.count + n - l.limit; excess > 0 && excess <= result.count {
+		result.retryAt = int64(entries[excess-1].Score)
+		result.latest = int64(entries[len(entries)-1].Score)
+	} else {
+		// The entries that filled the log were removed in the meantime.
+		result.retryAt = int64(expired)
+	}
+	return result, nil
+}
+
+var slidingWindowLogScript = redis.NewScript(`
+local key, now, window, n, limit, prefix = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4]), ARGV[5]
+redis.call('zremrangebyscore', key, '-inf', now - window)
+local count = redis.call('zcard', key)
+if count + n > limit then
+	local retryAt = redis.call('zrange', key, count + n - limit - 1, count + n - limit - 1, 'withscores')[2]
+	local latest = redis.call('zrange', key, -1, -1, 'withscores')[2]
+	return {0, count, tonumber(retryAt), tonumber(latest)}
+end
+for i = 0, n - 1 do
+	redis.call('zadd', key, now, prefix .. i)
+end
+redis.call('pexpire', key, ARGV[6])
+return {1, count + n, 0, now}
+`)
+
+func (l *SlidingWindowLog) allowRedis(b *redisstore.Backend, logKey string, now, n int64, memberPrefix string) (slidingWindowLogResult, error) {
+	result, err := b.RunScript(slidingWindowLogScript, []string{logKey}, now, l.windowMicroseconds(), n, l.limit, memberPrefix, milliseconds(l.window))
+	if err != nil {
+		return slidingWindowLogResult{}, err
+	}
+	values := result.([]interface{})
+	return slidingWindowLogResult{
+		allowed: values[0].(int64) == 1,
+		count:   values[1].(int64),
+		retryAt: values[2].(int64),
+		latest:  values[3].(int64),
+	}, nil
+}
@@ -0,0 +1,177 @@
+package keyvaluestoreratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/redisstore"
+)
+
+// TokenBucket holds up to Limit tokens for each key, which are refilled continuously at a rate of
+// Limit per Window. Each request takes a token. The bucket is stored as the number of tokens it held
+// when it was last taken from, along with the time, and is updated with CAS.
+type TokenBucket struct {
+	limiter
+}
+
+var _ Limiter = (*TokenBucket)(nil)
+
+func NewTokenBucket(b keyvaluestore.Backend, opts Options) *TokenBucket {
+	return &TokenBucket{
+		limiter: newLimiter(b, opts),
+	}
+}
+
+func (l *TokenBucket) Allow(key string) (*Result, error) {
+	return l.AllowN(key, 1)
+}
+
+func (l *TokenBucket) AllowN(key string, n int64) (*Result, error) {
+	if err := l.check(n); err != nil {
+		return nil, err
+	}
+
+	now := microseconds(l.clock.Now())
+	bucketKey := l.prefix + key
+
+	var allowed bool
+	var tokens float64
+	var err error
+	if rb, ok := l.backend.(*redisstore.Backend); ok {
+		allowed, tokens, err = l.allowRedis(rb, bucketKey, now, n)
+	} else {
+		allowed, tokens, err = l.allow(bucketKey, now, n)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Result{
+		Allowed:   allowed,
+		Limit:     l.limit,
+		Remaining: int64(tokens),
+		ResetAt:   fromMicroseconds(now).Add(l.refillDuration(float64(l.limit) - tokens)),
+	}
+	if !allowed {
+		ret.RetryAfter = l.refillDuration(float64(n) - tokens)
+	}
+	return ret, nil
+}
+
+// Returns how long it takes to refill the given number of tokens.
+func (l *TokenBucket) refillDuration(tokens float64) time.Duration {
+	return nonNegative(time.Duration(math.Ceil(tokens * float64(l.window) / float64(l.limit))))
+}
+
+type tokenBucketValue struct {
+	tokens float64
+	time   int64
+}
+
+func (v tokenBucketValue) String() string {
+	return strconv.FormatFloat(v.tokens, 'g', -1, 64) + " " + strconv.FormatInt(v.time, 10)
+}
+
+func parseTokenBucketValue(s string) (tokenBucketValue, error) {
+	parts := strings.SplitN(s, " ", 2)
+	if len(parts) != 2 {
+		return tokenBucketValue{}, fmt.Errorf("malformed token bucket value")
+	}
+	tokens, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return tokenBucketValue{}, fmt.Errorf("malformed token bucket tokens")
+	}
+	t, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return tokenBucketValue{}, fmt.Errorf("malformed token bucket time")
+	}
+	return tokenBucketValue{
+		tokens: tokens,
+		time:   t,
+	}, nil
+}
+
+// Returns the number of tokens in the bucket at the given time.
+func (l *TokenBucket) refill(v tokenBucketValue, now int64) float64 {
+	elapsed := now - v.time
+	if elapsed < 0 {
+		// Another client's clock is ahead of this one's.
+		elapsed = 0
+	}
+	return math.Min(float64(l.limit), v.tokens+float64(elapsed)*float64(l.limit)/float64(l.windowMicroseconds()))
+}
+
+func (l *TokenBucket) allow(bucketKey string, now, n int64) (bool, float64, error) {
+	var allowed bool
+	var tokens float64
+	for {
+		success, err := l.backend.CAS(bucketKey, func(prev *string) (interface{}, error) {
+			allowed, tokens = false, float64(l.limit)
+			if prev != nil {
+				v, err := parseTokenBucketValue(*prev)
+				if err != nil {
+					return nil, err
+				}
+				tokens = l.refill(v, now)
+			}
+			if tokens < float64(n) {
+				return nil, nil
+			}
+			allowed, tokens = true, tokens-float64(n)
+			return tokenBucketValue{
+				tokens: tokens,
+				time:   now,
+			}.String(), nil
+		})
+		if err != nil {
+			return false, 0, err
+		} else if !allowed {
+			return false, tokens, nil
+		} else if success {
+			break
+		}
+	}
+
+	// A bucket that isn't taken from is full again after a window, so it doesn't need to be kept any
+	// longer than that.
+	if _, err := l.backend.Expire(bucketKey, l.window); err != nil {
+		return false, 0, err
+	}
+	return true, tokens, nil
+}
+
+var tokenBucketScript = redis.NewScript(`
+local key, now, window, n, limit = KEYS[1], tonumber(ARGV[1]), tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+local tokens = limit
+local prev = redis.call('get', key)
+if prev then
+	local sep = string.find(prev, ' ', 1, true)
+	local elapsed = math.max(0, now - tonumber(string.sub(prev, sep + 1)))
+	tokens = math.min(limit, tonumber(string.sub(prev, 1, sep - 1)) + elapsed * limit / window)
+end
+if tokens < n then
+	return {0, string.format('%.17g', tokens)}
+end
+tokens = tokens - n
+redis.call('set', key, string.format('%.17g %d', tokens, now), 'px', ARGV[5])
+return {1, string.format('%.17g', tokens)}
+`)
+
+func (l *TokenBucket) allowRedis(b *redisstore.Backend, bucketKey string, now, n int64) (bool, float64, error) {
+	result, err := b.RunScript(tokenBucketScript, []string{bucketKey}, now, l.windowMicroseconds(), n, l.limit, milliseconds(l.window))
+	if err != nil {
+		return false, 0, err
+	}
+	values := result.([]interface{})
+	tokens, err := strconv.ParseFloat(values[1].(string), 64)
+	if err != nil {
+		return false, 0, err
+	}
+	return values[0].(int64) == 1, tokens, nil
+}
@@ -1,12 +1,14 @@
 package keyvaluestoretest
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strconv"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -108,795 +110,2259 @@ func TestBackendAtomicWrite(t *testing.T, newBackend func() keyvaluestore.Backen
 		assert.NoError(t, err)
 		assert.True(t, ok)
 	})
-}
-
-func TestBackend(t *testing.T, newBackend func() keyvaluestore.Backend) {
 	t.Run("Set", func(t *testing.T) {
-		t.Run("BinaryMarshaler", func(t *testing.T) {
-			b := newBackend()
+		assert.NoError(t, b.Set("foo", "bar"))
+		_, err := b.Delete("notset")
+		assert.NoError(t, err)
 
-			assert.NoError(t, b.Set("foo", &testBinaryMarshaler{}))
+		tx := b.AtomicWrite()
+		defer assertConditionFail(t, tx.SetNX("foo", "bar"))
+		tx.Set("set", "a")
+		ok, err := tx.Exec()
+		assert.NoError(t, err)
+		assert.False(t, ok)
 
-			v, err := b.Get("foo")
-			require.NotNil(t, v)
-			assert.NoError(t, err)
-			assert.Equal(t, "bar", *v)
-		})
+		got, err := b.Get("set")
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+
+		tx = b.AtomicWrite()
+		defer assertConditionPass(t, tx.SetNX("notset", "bar"))
+		defer assertConditionPass(t, tx.Set("set", "a"))
+		ok, err = tx.Exec()
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		got, err = b.Get("set")
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "a", *got)
+		}
 	})
 
-	t.Run("AddInt", func(t *testing.T) {
-		b := newBackend()
+	t.Run("Sets", func(t *testing.T) {
+		assert.NoError(t, b.Set("foo", "bar"))
+		_, err := b.Delete("user")
+		assert.NoError(t, err)
+		assert.NoError(t, b.SAdd("index", "x", "y"))
 
-		t.Run("New", func(t *testing.T) {
-			n, err := b.AddInt("foo", 2)
-			assert.EqualValues(t, 2, n)
-			assert.NoError(t, err)
+		tx := b.AtomicWrite()
+		defer assertConditionFail(t, tx.SetNX("foo", "bar"))
+		tx.SAdd("index", "a")
+		tx.SRem("index", "x")
+		ok, err := tx.Exec()
+		assert.NoError(t, err)
+		assert.False(t, ok)
 
-			v, err := b.Get("foo")
-			require.NotNil(t, v)
-			assert.NoError(t, err)
-			assert.Equal(t, "2", *v)
-		})
+		members, err := b.SMembers("index")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"x", "y"}, members)
 
-		t.Run("Existing", func(t *testing.T) {
-			assert.NoError(t, b.Set("foo", 1))
+		tx = b.AtomicWrite()
+		defer assertConditionPass(t, tx.SetNX("user", "a"))
+		defer assertConditionPass(t, tx.SAdd("index", "a", "b"))
+		defer assertConditionPass(t, tx.SRem("index2", "x"))
+		ok, err = tx.Exec()
+		assert.NoError(t, err)
+		assert.True(t, ok)
 
-			v, err := b.Get("foo")
-			require.NotNil(t, v)
-			assert.NoError(t, err)
-			assert.Equal(t, "1", *v)
+		members, err = b.SMembers("index")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a", "b", "x", "y"}, members)
 
-			n, err := b.AddInt("foo", 2)
-			assert.EqualValues(t, 3, n)
-			assert.NoError(t, err)
+		tx = b.AtomicWrite()
+		tx.SRem("index", "a", "x")
+		ok, err = tx.Exec()
+		assert.NoError(t, err)
+		assert.True(t, ok)
 
-			v, err = b.Get("foo")
-			require.NotNil(t, v)
-			assert.NoError(t, err)
-			assert.Equal(t, "3", *v)
-		})
+		members, err = b.SMembers("index")
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"b", "y"}, members)
 	})
 
-	t.Run("Delete", func(t *testing.T) {
-		b := newBackend()
+	t.Run("SortedSets", func(t *testing.T) {
+		assert.NoError(t, b.Set("foo", "bar"))
+		_, err := b.Delete("zuser")
+		assert.NoError(t, err)
+		assert.NoError(t, b.ZAdd("zindex", "x", 1.0))
 
-		success, err := b.Delete("foo")
-		assert.False(t, success)
+		tx := b.AtomicWrite()
+		defer assertConditionFail(t, tx.SetNX("foo", "bar"))
+		tx.ZAdd("zindex", "a", 2.0)
+		tx.ZRem("zindex", "x")
+		ok, err := tx.Exec()
 		assert.NoError(t, err)
+		assert.False(t, ok)
 
-		assert.NoError(t, b.Set("foo", "bar"))
-		v, err := b.Get("foo")
-		assert.NotNil(t, v)
+		members, err := b.ZRangeByScore("zindex", math.Inf(-1), math.Inf(1), 0)
 		assert.NoError(t, err)
+		assert.Equal(t, []string{"x"}, members)
 
-		success, err = b.Delete("foo")
+		tx = b.AtomicWrite()
+		defer assertConditionPass(t, tx.SetNX("zuser", "a"))
+		defer assertConditionPass(t, tx.ZAdd("zindex", "a", 2.0))
+		defer assertConditionPass(t, tx.ZRem("zindex", "x"))
+		ok, err = tx.Exec()
 		assert.NoError(t, err)
-		assert.True(t, success)
-		v, err = b.Get("foo")
-		assert.Nil(t, v)
+		assert.True(t, ok)
+
+		members, err = b.ZRangeByScore("zindex", math.Inf(-1), math.Inf(1), 0)
 		assert.NoError(t, err)
-	})
+		assert.Equal(t, []string{"a"}, members)
 
-	t.Run("SetNX", func(t *testing.T) {
-		b := newBackend()
+		score, err := b.ZScore("zindex", "a")
+		assert.NoError(t, err)
+		if assert.NotNil(t, score) {
+			assert.Equal(t, 2.0, *score)
+		}
+	})
 
-		didSet, err := b.SetNX("foo", "bar")
-		assert.True(t, didSet)
+	t.Run("Checks", func(t *testing.T) {
+		assert.NoError(t, b.Set("foo", "bar"))
+		assert.NoError(t, b.Set("version", "1"))
+		_, err := b.Delete("missing")
 		assert.NoError(t, err)
+		assert.NoError(t, b.Set("counter", 10))
 
-		v, err := b.Get("foo")
-		assert.NotNil(t, v)
+		tx := b.AtomicWrite()
+		defer assertConditionFail(t, tx.Check("version", "2"))
+		defer assertConditionPass(t, tx.CheckExists("foo"))
+		defer assertConditionPass(t, tx.CheckNotExists("missing"))
+		tx.AddInt("counter", 1)
+		ok, err := tx.Exec()
 		assert.NoError(t, err)
+		assert.False(t, ok)
 
-		didSet, err = b.SetNX("foo", "bar")
-		assert.False(t, didSet)
+		tx = b.AtomicWrite()
+		defer assertConditionPass(t, tx.Check("version", "1"))
+		defer assertConditionFail(t, tx.CheckExists("missing"))
+		tx.AddInt("counter", 1)
+		ok, err = tx.Exec()
 		assert.NoError(t, err)
-	})
+		assert.False(t, ok)
 
-	t.Run("SetXX", func(t *testing.T) {
-		b := newBackend()
+		tx = b.AtomicWrite()
+		defer assertConditionFail(t, tx.CheckNotExists("version"))
+		tx.AddInt("counter", 1)
+		ok, err = tx.Exec()
+		assert.NoError(t, err)
+		assert.False(t, ok)
 
-		didSet, err := b.SetXX("foo", "bar")
-		assert.False(t, didSet)
+		got, err := b.Get("counter")
 		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "10", *got)
+		}
 
-		v, err := b.Get("foo")
-		assert.Nil(t, v)
+		tx = b.AtomicWrite()
+		defer assertConditionPass(t, tx.Check("version", "1"))
+		defer assertConditionPass(t, tx.CheckExists("foo"))
+		defer assertConditionPass(t, tx.CheckNotExists("missing"))
+		defer assertConditionPass(t, tx.AddInt("counter", 5))
+		ok, err = tx.Exec()
 		assert.NoError(t, err)
+		assert.True(t, ok)
 
-		assert.NoError(t, b.Set("foo", "x"))
+		got, err = b.Get("counter")
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "15", *got)
+		}
 
-		didSet, err = b.SetXX("foo", "bar")
-		assert.True(t, didSet)
+		got, err = b.Get("version")
 		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "1", *got)
+		}
 
-		v, err = b.Get("foo")
-		assert.NotNil(t, v)
-		assert.Equal(t, "bar", *v)
+		got, err = b.Get("missing")
 		assert.NoError(t, err)
+		assert.Nil(t, got)
 	})
 
-	t.Run("SAdd", func(t *testing.T) {
-		b := newBackend()
+	t.Run("AddInt", func(t *testing.T) {
+		_, err := b.Delete("newcounter")
+		assert.NoError(t, err)
 
-		assert.NoError(t, b.SAdd("foo", "bar"))
+		tx := b.AtomicWrite()
+		defer assertConditionPass(t, tx.AddInt("newcounter", -2))
+		ok, err := tx.Exec()
+		assert.NoError(t, err)
+		assert.True(t, ok)
 
-		members, err := b.SMembers("foo")
-		assert.Equal(t, []string{"bar"}, members)
+		n, err := b.AddInt("newcounter", 0)
 		assert.NoError(t, err)
+		assert.Equal(t, int64(-2), n)
+	})
 
-		assert.NoError(t, b.SAdd("foo", "baz"))
-		assert.NoError(t, b.SAdd("foo", "baz"))
+	t.Run("SetXX", func(t *testing.T) {
+		assert.NoError(t, b.Set("foo", "bar"))
+		_, err := b.Delete("notset")
+		assert.NoError(t, err)
 
-		members, err = b.SMembers("foo")
-		assert.ElementsMatch(t, []string{"bar", "baz"}, members)
+		tx := b.AtomicWrite()
+		defer assertConditionPass(t, tx.SetXX("foo", "baz"))
+		defer assertConditionFail(t, tx.SetXX("notset", "baz"))
+		ok, err := tx.Exec()
 		assert.NoError(t, err)
+		assert.False(t, ok)
 
-		// DynamoDB has a 400KB size limit for items. Make sure sets work fine when they grow larger
-		// than that.
-		t.Run("LargeSet", func(t *testing.T) {
-			b := newBackend()
+		got, err := b.Get("foo")
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "bar", *got)
+		}
 
-			bigPrefix := strings.Repeat("x", 10000)
-			expected := make([]string, 90)
-			for i := 0; i < len(expected); i++ {
-				s := bigPrefix + strconv.FormatInt(int64(i), 10)
-				expected[i] = s
-				require.NoError(t, b.SAdd("foo", s))
-			}
+		tx = b.AtomicWrite()
+		defer assertConditionPass(t, tx.SetXX("foo", "baz"))
+		ok, err = tx.Exec()
+		assert.NoError(t, err)
+		assert.True(t, ok)
 
-			members, err := b.SMembers("foo")
-			require.NoError(t, err)
-			assert.ElementsMatch(t, expected, members)
+		got, err = b.Get("foo")
+		assert.NoError(t, err)
+		if assert.NotNil(t, got) {
+			assert.Equal(t, "baz", *got)
+		}
 
-			require.NoError(t, b.SRem("foo", expected[len(expected)-1]))
-			expected = expected[:len(expected)-1]
+		got, err = b.Get("notset")
+		assert.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
 
-			members, err = b.SMembers("foo")
-			require.NoError(t, err)
-			assert.ElementsMatch(t, expected, members)
+func TestBackendAtomicRead(t *testing.T, newBackend func() keyvaluestore.Backend) {
+	b := newBackend()
 
-			require.NoError(t, b.SRem("foo", expected[0]))
-			require.NoError(t, b.SAdd("foo", expected[len(expected)-1]))
-			expected = expected[1:]
+	assert.NoError(t, b.Set("foo", "bar"))
+	_, err := b.Delete("notset")
+	assert.NoError(t, err)
+	assert.NoError(t, b.SAdd("set", "a", "b"))
+	assert.NoError(t, b.ZAdd("zset", "a", 1.5))
+
+	tx := b.AtomicRead()
+	foo := tx.Get("foo")
+	notset := tx.Get("notset")
+	set := tx.SMembers("set")
+	emptySet := tx.SMembers("emptyset")
+	score := tx.ZScore("zset", "a")
+	missingScore := tx.ZScore("zset", "b")
+	require.NoError(t, tx.Exec())
+
+	v, err := foo.Result()
+	assert.NoError(t, err)
+	if assert.NotNil(t, v) {
+		assert.Equal(t, "bar", *v)
+	}
+
+	v, err = notset.Result()
+	assert.NoError(t, err)
+	assert.Nil(t, v)
+
+	members, err := set.Result()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, members)
+
+	members, err = emptySet.Result()
+	assert.NoError(t, err)
+	assert.Empty(t, members)
+
+	f, err := score.Result()
+	assert.NoError(t, err)
+	if assert.NotNil(t, f) {
+		assert.Equal(t, 1.5, *f)
+	}
+
+	f, err = missingScore.Result()
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+
+	t.Run("TooManyOperations", func(t *testing.T) {
+		max := b.Capabilities().MaxAtomicReadOperations
+		if max == 0 {
+			t.Skip("backend has no atomic read limit")
+		}
 
-			members, err = b.SMembers("foo")
-			require.NoError(t, err)
-			assert.ElementsMatch(t, expected, members)
-		})
+		tx := b.AtomicRead()
+		for i := 0; i <= max; i++ {
+			tx.Get(fmt.Sprintf("key%v", i))
+		}
+		assert.Equal(t, keyvaluestore.ErrTooManyOperations, tx.Exec())
 	})
+}
 
-	t.Run("SRem", func(t *testing.T) {
-		b := newBackend()
+func TestBackendContext(t *testing.T, newBackend func() keyvaluestore.Backend) {
+	for name, newContext := range map[string]func() (context.Context, context.CancelFunc){
+		"Cancelled": func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx, cancel
+		},
+		"DeadlineExceeded": func() (context.Context, context.CancelFunc) {
+			return context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			b := newBackend()
+			require.NoError(t, b.Set("foo", "bar"))
 
-		assert.NoError(t, b.SAdd("foo", "a", "b", "c", "d"))
+			ctx, cancel := newContext()
+			defer cancel()
+			withContext := b.WithContext(ctx)
 
-		members, err := b.SMembers("foo")
-		assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, members)
-		assert.NoError(t, err)
+			start := time.Now()
 
-		assert.NoError(t, b.SRem("foo", "a", "b"))
+			_, err := withContext.Get("foo")
+			assert.Error(t, err)
 
-		members, err = b.SMembers("foo")
-		assert.ElementsMatch(t, []string{"c", "d"}, members)
-		assert.NoError(t, err)
+			assert.Error(t, withContext.Set("foo", "baz"))
 
-		t.Run("Empty", func(t *testing.T) {
-			b := newBackend()
+			_, err = withContext.SMembers("set")
+			assert.Error(t, err)
 
-			assert.NoError(t, b.SRem("foo", "x"))
-		})
-	})
+			_, err = withContext.ZRangeByScore("zset", math.Inf(-1), math.Inf(1), 0)
+			assert.Error(t, err)
 
-	t.Run("AtomicWrite", func(t *testing.T) {
-		TestBackendAtomicWrite(t, newBackend)
-	})
+			_, err = withContext.CAS("foo", func(prev *string) (interface{}, error) {
+				return "baz", nil
+			})
+			assert.Error(t, err)
 
-	t.Run("Batch", func(t *testing.T) {
-		t.Run("Get", func(t *testing.T) {
-			b := newBackend()
+			batch := withContext.Batch()
+			get := batch.Get("foo")
+			assert.Error(t, batch.Exec())
+			_, err = get.Result()
+			assert.Error(t, err)
 
-			assert.NoError(t, b.Set("foo", "bar"))
-			assert.NoError(t, b.Set("foo2", "bar2"))
+			tx := withContext.AtomicWrite()
+			tx.SetNX("notset", "bar")
+			_, err = tx.Exec()
+			assert.Error(t, err)
 
-			batch := b.Batch()
-			get := batch.Get("foo")
-			get2 := batch.Get("foo2")
-			get3 := batch.Get("foo3")
-			assert.NoError(t, batch.Exec())
+			assert.True(t, time.Since(start) < time.Second, "operations should return promptly")
 
-			v, err := get.Result()
+			// The original backend should be unaffected.
+			v, err := b.Get("foo")
+			require.NoError(t, err)
+			require.NotNil(t, v)
 			assert.Equal(t, "bar", *v)
-			assert.NoError(t, err)
 
-			v, err = get2.Result()
-			assert.Equal(t, "bar2", *v)
+			v, err = b.Get("notset")
 			assert.NoError(t, err)
-
-			v, err = get3.Result()
 			assert.Nil(t, v)
-			assert.NoError(t, err)
 		})
+	}
+}
 
-		t.Run("SMembers", func(t *testing.T) {
-			b := newBackend()
+func TestBackendTTL(t *testing.T, newBackend func() keyvaluestore.Backend) {
+	const ttl = time.Hour
 
-			assert.NoError(t, b.SAdd("set", "a"))
-			assert.NoError(t, b.SAdd("set", "b"))
+	assertTTL := func(t *testing.T, b keyvaluestore.Backend, key string) {
+		remaining, err := b.TTL(key)
+		assert.NoError(t, err)
+		require.NotNil(t, remaining)
+		assert.True(t, *remaining > ttl-time.Minute && *remaining <= ttl+time.Second, "unexpected ttl: %v", *remaining)
+	}
 
-			batch := b.Batch()
-			smembers := batch.SMembers("set")
-			require.NoError(t, batch.Exec())
-			members, _ := smembers.Result()
-			assert.ElementsMatch(t, []string{"a", "b"}, members)
+	t.Run("SetEX", func(t *testing.T) {
+		b := newBackend()
 
-			// DynamoDB has a 400KB size limit for items. Make sure sets work fine when they grow larger
-			// than that.
-			t.Run("LargeSet", func(t *testing.T) {
-				b := newBackend()
+		remaining, err := b.TTL("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, remaining)
 
-				bigPrefix := strings.Repeat("x", 10000)
-				expected := make([]string, 90)
-				for i := 0; i < len(expected); i++ {
-					s := bigPrefix + strconv.FormatInt(int64(i), 10)
-					expected[i] = s
-					require.NoError(t, b.SAdd("foo", s))
-				}
+		assert.NoError(t, b.SetEX("foo", "bar", ttl))
 
-				batch := b.Batch()
-				smembers := batch.SMembers("foo")
-				require.NoError(t, batch.Exec())
-				members, _ := smembers.Result()
-				assert.ElementsMatch(t, expected, members)
-			})
-		})
+		v, err := b.Get("foo")
+		assert.NoError(t, err)
+		require.NotNil(t, v)
+		assert.Equal(t, "bar", *v)
 
-		t.Run("Set", func(t *testing.T) {
-			b := newBackend()
+		assertTTL(t, b, "foo")
 
-			batch := b.Batch()
-			batch.Set("foo", "a")
-			batch.Set("foo", "b")
-			require.NoError(t, batch.Exec())
+		assert.Error(t, b.SetEX("foo", "bar", 0))
+	})
 
-			foo, err := b.Get("foo")
-			require.NotNil(t, foo)
-			assert.Equal(t, "b", *foo)
-			assert.NoError(t, err)
-		})
+	t.Run("Set", func(t *testing.T) {
+		b := newBackend()
 
-		t.Run("ZAdd", func(t *testing.T) {
-			b := newBackend()
+		assert.NoError(t, b.SetEX("foo", "bar", ttl))
+		assert.NoError(t, b.Set("foo", "baz"))
 
-			batch := b.Batch()
-			batch.ZAdd("foo", "a", 0.0)
-			batch.ZAdd("foo", "b", 10.0)
-			require.NoError(t, batch.Exec())
+		remaining, err := b.TTL("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, remaining)
+	})
 
-			members, err := b.ZRangeByScore("foo", 0.0, 100.0, 0)
-			assert.Equal(t, []string{"a", "b"}, members)
-			assert.NoError(t, err)
+	t.Run("AddInt", func(t *testing.T) {
+		b := newBackend()
 
-			batch = b.Batch()
-			batch.ZAdd("foo", "a", 5.0)
-			batch.ZAdd("foo", "a", 20.0)
-			require.NoError(t, batch.Exec())
+		assert.NoError(t, b.SetEX("foo", 1, ttl))
+		n, err := b.AddInt("foo", 2)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 3, n)
 
-			members, err = b.ZRangeByScore("foo", 0.0, 100.0, 0)
-			assert.Equal(t, []string{"b", "a"}, members)
-			assert.NoError(t, err)
-		})
+		assertTTL(t, b, "foo")
 	})
 
-	t.Run("CAS", func(t *testing.T) {
-		t.Run("Set", func(t *testing.T) {
-			b := newBackend()
+	t.Run("Expire", func(t *testing.T) {
+		b := newBackend()
 
-			assert.NoError(t, b.Set("foo", "bar"))
+		ok, err := b.Expire("foo", ttl)
+		assert.NoError(t, err)
+		assert.False(t, ok)
 
-			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
-				assert.Equal(t, "bar", *prev)
-				return "baz", nil
-			})
-			assert.True(t, success)
-			assert.NoError(t, err)
+		assert.NoError(t, b.Set("foo", "bar"))
 
-			v, err := b.Get("foo")
-			require.NoError(t, err)
-			assert.Equal(t, "baz", *v)
-		})
+		remaining, err := b.TTL("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, remaining)
 
-		t.Run("Contention", func(t *testing.T) {
-			b := newBackend()
+		ok, err = b.Expire("foo", ttl)
+		assert.NoError(t, err)
+		assert.True(t, ok)
 
-			assert.NoError(t, b.Set("foo", "bar"))
+		assertTTL(t, b, "foo")
 
-			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
-				assert.Equal(t, "bar", *prev)
-				assert.NoError(t, b.Set("foo", "qux"))
-				return "baz", nil
-			})
-			assert.False(t, success)
-			assert.NoError(t, err)
+		_, err = b.Expire("foo", -time.Second)
+		assert.Error(t, err)
+	})
 
-			v, err := b.Get("foo")
-			require.NoError(t, err)
-			assert.Equal(t, "qux", *v)
-		})
+	t.Run("Batch", func(t *testing.T) {
+		b := newBackend()
 
-		t.Run("NOP", func(t *testing.T) {
-			b := newBackend()
+		assert.NoError(t, b.Set("bar", "baz"))
 
-			assert.NoError(t, b.Set("foo", "bar"))
+		batch := b.Batch()
+		setEX := batch.SetEX("foo", "bar", ttl)
+		expire := batch.Expire("bar", ttl)
+		expireMissing := batch.Expire("notset", ttl)
+		assert.NoError(t, batch.Exec())
 
-			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
-				return nil, nil
-			})
-			assert.True(t, success)
-			assert.NoError(t, err)
+		assert.NoError(t, setEX.Result())
+		ok, err := expire.Result()
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = expireMissing.Result()
+		assert.NoError(t, err)
+		assert.False(t, ok)
 
-			v, err := b.Get("foo")
-			require.NoError(t, err)
-			assert.Equal(t, "bar", *v)
-		})
+		assertTTL(t, b, "foo")
+		assertTTL(t, b, "bar")
+	})
 
-		t.Run("Error", func(t *testing.T) {
-			b := newBackend()
+	t.Run("AtomicWrite", func(t *testing.T) {
+		b := newBackend()
 
-			assert.NoError(t, b.Set("foo", "bar"))
+		tx := b.AtomicWrite()
+		r := tx.SetEX("foo", "bar", ttl)
+		ok, err := tx.Exec()
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assertConditionPass(t, r)
 
-			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
-				return nil, fmt.Errorf("err")
-			})
-			assert.False(t, success)
-			assert.Error(t, err)
+		v, err := b.Get("foo")
+		assert.NoError(t, err)
+		require.NotNil(t, v)
+		assert.Equal(t, "bar", *v)
 
-			v, err := b.Get("foo")
-			require.NoError(t, err)
-			assert.Equal(t, "bar", *v)
-		})
+		assertTTL(t, b, "foo")
+
+		tx = b.AtomicWrite()
+		tx.SetEX("foo", "bar", 0)
+		_, err = tx.Exec()
+		assert.Error(t, err)
 	})
 
-	t.Run("ZRem", func(t *testing.T) {
+	t.Run("SubMillisecond", func(t *testing.T) {
 		b := newBackend()
 
-		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "b", 0.0))
+		// Backends whose expirations are coarser than the TTL should round it up.
+		assert.NoError(t, b.SetEX("foo", "bar", time.Microsecond))
 
-		members, err := b.ZRangeByLex("foo", "-", "+", 0)
+		assert.NoError(t, b.Set("bar", "baz"))
+		_, err := b.Expire("bar", time.Microsecond)
 		assert.NoError(t, err)
-		assert.Equal(t, []string{"a", "b"}, members)
 
-		assert.NoError(t, b.ZRem("foo", "a"))
+		batch := b.Batch()
+		setEX := batch.SetEX("baz", "qux", time.Microsecond)
+		assert.NoError(t, batch.Exec())
+		assert.NoError(t, setEX.Result())
 
-		members, err = b.ZRangeByLex("foo", "-", "+", 0)
+		tx := b.AtomicWrite()
+		tx.SetEX("qux", "quux", time.Microsecond)
+		_, err = tx.Exec()
 		assert.NoError(t, err)
-		assert.Equal(t, []string{"b"}, members)
 	})
+}
 
-	t.Run("ZRangeByScore", func(t *testing.T) {
-		b := newBackend()
+// Tests that keys actually expire. The given clock must be used by the backend to determine
+// expirations.
+func TestBackendExpiration(t *testing.T, newBackend func(clock keyvaluestore.Clock) keyvaluestore.Backend) {
+	clock := NewFakeClock(time.Unix(1500000000, 0))
+	b := newBackend(clock)
 
-		assert.NoError(t, b.ZAdd("foo", "-2", -2.0))
-		assert.NoError(t, b.ZAdd("foo", "-1", -1.0))
-		assert.NoError(t, b.ZAdd("foo", "-0.5", -0.5))
-		assert.NoError(t, b.ZAdd("foo", "0", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "0.5", 0.5))
-		assert.NoError(t, b.ZAdd("foo", "0.5b", 0.5))
-		assert.NoError(t, b.ZAdd("foo", "1", 1.0))
-		assert.NoError(t, b.ZAdd("foo", "2", 2.0))
+	assert.NoError(t, b.SetEX("foo", "bar", time.Minute))
+	assert.NoError(t, b.SetEX("counter", 10, time.Minute))
 
-		t.Run("MinMax", func(t *testing.T) {
-			members, err := b.ZRangeByScore("foo", -0.5, 1.0, 0)
-			assert.NoError(t, err)
-			assert.Equal(t, []string{"-0.5", "0", "0.5", "0.5b", "1"}, members)
-		})
+	clock.Advance(30 * time.Second)
 
-		t.Run("-Inf", func(t *testing.T) {
-			members, err := b.ZRangeByScore("foo", math.Inf(-1), 1, 0)
-			assert.NoError(t, err)
-			assert.Equal(t, []string{"-2", "-1", "-0.5", "0", "0.5", "0.5b", "1"}, members)
-		})
+	remaining, err := b.TTL("foo")
+	assert.NoError(t, err)
+	require.NotNil(t, remaining)
+	assert.Equal(t, 30*time.Second, *remaining)
 
-		t.Run("+Inf", func(t *testing.T) {
-			members, err := b.ZRangeByScore("foo", -0.5, math.Inf(1), 0)
-			assert.NoError(t, err)
-			assert.Equal(t, []string{"-0.5", "0", "0.5", "0.5b", "1", "2"}, members)
-		})
+	v, err := b.Get("foo")
+	assert.NoError(t, err)
+	assert.NotNil(t, v)
 
-		t.Run("Rev", func(t *testing.T) {
-			t.Run("MinMax", func(t *testing.T) {
-				members, err := b.ZRevRangeByScore("foo", -0.5, 1.0, 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"1", "0.5b", "0.5", "0", "-0.5"}, members)
-			})
+	clock.Advance(time.Minute)
 
-			t.Run("-Inf", func(t *testing.T) {
-				members, err := b.ZRevRangeByScore("foo", math.Inf(-1), 1, 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"1", "0.5b", "0.5", "0", "-0.5", "-1", "-2"}, members)
-			})
+	v, err = b.Get("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, v)
 
-			t.Run("+Inf", func(t *testing.T) {
-				members, err := b.ZRevRangeByScore("foo", -0.5, math.Inf(1), 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"2", "1", "0.5b", "0.5", "0", "-0.5"}, members)
-			})
-		})
+	remaining, err = b.TTL("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, remaining)
 
-		t.Run("Update", func(t *testing.T) {
-			assert.NoError(t, b.ZAdd("update-test", "foo", 2.0))
+	ok, err := b.Expire("foo", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, ok)
 
-			members, err := b.ZRangeByScore("update-test", 1.5, 2.5, 0)
-			assert.NoError(t, err)
-			assert.Equal(t, []string{"foo"}, members)
+	ok, err = b.SetXX("foo", "baz")
+	assert.NoError(t, err)
+	assert.False(t, ok)
 
-			assert.NoError(t, b.ZAdd("update-test", "foo", 3.0))
+	ok, err = b.SetNX("foo", "baz")
+	assert.NoError(t, err)
+	assert.True(t, ok)
 
-			members, err = b.ZRangeByScore("update-test", 1.5, 2.5, 0)
-			assert.NoError(t, err)
-			assert.Empty(t, members)
+	n, err := b.AddInt("counter", 1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, n)
 
-			members, err = b.ZRangeByScore("update-test", 2.5, 3.5, 0)
-			assert.NoError(t, err)
-			assert.Equal(t, []string{"foo"}, members)
-		})
+	assert.NoError(t, b.SetEX("foo", "bar", time.Minute))
+	clock.Advance(2 * time.Minute)
+
+	ok, err = b.Delete("foo")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func scanAll(t *testing.T, b keyvaluestore.Backend, prefix string, limit int) map[string]keyvaluestore.KeyType {
+	keys := map[string]keyvaluestore.KeyType{}
+	cursor := ""
+	for i := 0; ; i++ {
+		require.True(t, i < 100, "scan should complete")
+		page, err := b.Scan(prefix, cursor, limit)
+		require.NoError(t, err)
+		for _, key := range page.Keys {
+			keys[key.Key] = key.Type
+		}
+		if page.Cursor == "" {
+			return keys
+		}
+		cursor = page.Cursor
+	}
+}
+
+func TestBackend(t *testing.T, newBackend func() keyvaluestore.Backend) {
+	t.Run("Context", func(t *testing.T) {
+		TestBackendContext(t, newBackend)
 	})
 
-	t.Run("ZRangeByLex", func(t *testing.T) {
-		b := newBackend()
+	t.Run("TTL", func(t *testing.T) {
+		TestBackendTTL(t, newBackend)
+	})
 
-		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "b", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "c", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "d", 0.0))
+	t.Run("Set", func(t *testing.T) {
+		t.Run("BinaryMarshaler", func(t *testing.T) {
+			b := newBackend()
 
-		t.Run("Inf", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "-", "+", 0)
+			assert.NoError(t, b.Set("foo", &testBinaryMarshaler{}))
+
+			v, err := b.Get("foo")
+			require.NotNil(t, v)
 			assert.NoError(t, err)
-			assert.Equal(t, []string{"a", "b", "c", "d"}, members)
+			assert.Equal(t, "bar", *v)
 		})
+	})
 
-		t.Run("MinGreaterThanMax", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "(d", "(a", 0)
+	t.Run("AddInt", func(t *testing.T) {
+		b := newBackend()
+
+		t.Run("New", func(t *testing.T) {
+			n, err := b.AddInt("foo", 2)
+			assert.EqualValues(t, 2, n)
 			assert.NoError(t, err)
-			assert.Empty(t, members)
-		})
 
-		t.Run("MinMaxExclusive", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "(a", "(d", 0)
+			v, err := b.Get("foo")
+			require.NotNil(t, v)
 			assert.NoError(t, err)
-			assert.Equal(t, []string{"b", "c"}, members)
+			assert.Equal(t, "2", *v)
 		})
 
-		t.Run("MinMaxInclusive", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "[a", "[d", 0)
-			assert.NoError(t, err)
+		t.Run("Existing", func(t *testing.T) {
+			assert.NoError(t, b.Set("foo", 1))
+
+			v, err := b.Get("foo")
+			require.NotNil(t, v)
+			assert.NoError(t, err)
+			assert.Equal(t, "1", *v)
+
+			n, err := b.AddInt("foo", 2)
+			assert.EqualValues(t, 3, n)
+			assert.NoError(t, err)
+
+			v, err = b.Get("foo")
+			require.NotNil(t, v)
+			assert.NoError(t, err)
+			assert.Equal(t, "3", *v)
+		})
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		b := newBackend()
+
+		success, err := b.Delete("foo")
+		assert.False(t, success)
+		assert.NoError(t, err)
+
+		assert.NoError(t, b.Set("foo", "bar"))
+		v, err := b.Get("foo")
+		assert.NotNil(t, v)
+		assert.NoError(t, err)
+
+		success, err = b.Delete("foo")
+		assert.NoError(t, err)
+		assert.True(t, success)
+		v, err = b.Get("foo")
+		assert.Nil(t, v)
+		assert.NoError(t, err)
+	})
+
+	t.Run("SetNX", func(t *testing.T) {
+		b := newBackend()
+
+		didSet, err := b.SetNX("foo", "bar")
+		assert.True(t, didSet)
+		assert.NoError(t, err)
+
+		v, err := b.Get("foo")
+		assert.NotNil(t, v)
+		assert.NoError(t, err)
+
+		didSet, err = b.SetNX("foo", "bar")
+		assert.False(t, didSet)
+		assert.NoError(t, err)
+	})
+
+	t.Run("SetXX", func(t *testing.T) {
+		b := newBackend()
+
+		didSet, err := b.SetXX("foo", "bar")
+		assert.False(t, didSet)
+		assert.NoError(t, err)
+
+		v, err := b.Get("foo")
+		assert.Nil(t, v)
+		assert.NoError(t, err)
+
+		assert.NoError(t, b.Set("foo", "x"))
+
+		didSet, err = b.SetXX("foo", "bar")
+		assert.True(t, didSet)
+		assert.NoError(t, err)
+
+		v, err = b.Get("foo")
+		assert.NotNil(t, v)
+		assert.Equal(t, "bar", *v)
+		assert.NoError(t, err)
+	})
+
+	t.Run("SAdd", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.SAdd("foo", "bar"))
+
+		members, err := b.SMembers("foo")
+		assert.Equal(t, []string{"bar"}, members)
+		assert.NoError(t, err)
+
+		assert.NoError(t, b.SAdd("foo", "baz"))
+		assert.NoError(t, b.SAdd("foo", "baz"))
+
+		members, err = b.SMembers("foo")
+		assert.ElementsMatch(t, []string{"bar", "baz"}, members)
+		assert.NoError(t, err)
+
+		// DynamoDB has a 400KB size limit for items. Make sure sets work fine when they grow larger
+		// than that.
+		t.Run("LargeSet", func(t *testing.T) {
+			b := newBackend()
+
+			bigPrefix := strings.Repeat("x", 10000)
+			expected := make([]string, 90)
+			for i := 0; i < len(expected); i++ {
+				s := bigPrefix + strconv.FormatInt(int64(i), 10)
+				expected[i] = s
+				require.NoError(t, b.SAdd("foo", s))
+			}
+
+			members, err := b.SMembers("foo")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, expected, members)
+
+			require.NoError(t, b.SRem("foo", expected[len(expected)-1]))
+			expected = expected[:len(expected)-1]
+
+			members, err = b.SMembers("foo")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, expected, members)
+
+			require.NoError(t, b.SRem("foo", expected[0]))
+			require.NoError(t, b.SAdd("foo", expected[len(expected)-1]))
+			expected = expected[1:]
+
+			members, err = b.SMembers("foo")
+			require.NoError(t, err)
+			assert.ElementsMatch(t, expected, members)
+		})
+	})
+
+	t.Run("SRem", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.SAdd("foo", "a", "b", "c", "d"))
+
+		members, err := b.SMembers("foo")
+		assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, members)
+		assert.NoError(t, err)
+
+		assert.NoError(t, b.SRem("foo", "a", "b"))
+
+		members, err = b.SMembers("foo")
+		assert.ElementsMatch(t, []string{"c", "d"}, members)
+		assert.NoError(t, err)
+
+		t.Run("Empty", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.SRem("foo", "x"))
+		})
+	})
+
+	t.Run("SIsMember", func(t *testing.T) {
+		b := newBackend()
+
+		ok, err := b.SIsMember("foo", "a")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		assert.NoError(t, b.SAdd("foo", "a", "b"))
+
+		ok, err = b.SIsMember("foo", "a")
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = b.SIsMember("foo", "c")
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("SCard", func(t *testing.T) {
+		b := newBackend()
+
+		n, err := b.SCard("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		assert.NoError(t, b.SAdd("foo", "a", "b"))
+		assert.NoError(t, b.SAdd("foo", "b", "c"))
+
+		n, err = b.SCard("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("SRandMember", func(t *testing.T) {
+		b := newBackend()
+
+		member, err := b.SRandMember("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, member)
+
+		assert.NoError(t, b.SAdd("foo", "a", "b", "c"))
+
+		member, err = b.SRandMember("foo")
+		assert.NoError(t, err)
+		if assert.NotNil(t, member) {
+			assert.Contains(t, []string{"a", "b", "c"}, *member)
+		}
+
+		n, err := b.SCard("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+	})
+
+	t.Run("SPop", func(t *testing.T) {
+		b := newBackend()
+
+		member, err := b.SPop("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, member)
+
+		assert.NoError(t, b.SAdd("foo", "a", "b", "c"))
+
+		var popped []string
+		for i := 0; i < 3; i++ {
+			member, err := b.SPop("foo")
+			assert.NoError(t, err)
+			if assert.NotNil(t, member) {
+				popped = append(popped, *member)
+			}
+		}
+		assert.ElementsMatch(t, []string{"a", "b", "c"}, popped)
+
+		member, err = b.SPop("foo")
+		assert.NoError(t, err)
+		assert.Nil(t, member)
+
+		members, err := b.SMembers("foo")
+		assert.NoError(t, err)
+		assert.Empty(t, members)
+	})
+
+	t.Run("AtomicWrite", func(t *testing.T) {
+		TestBackendAtomicWrite(t, newBackend)
+	})
+
+	t.Run("AtomicRead", func(t *testing.T) {
+		TestBackendAtomicRead(t, newBackend)
+	})
+
+	t.Run("Lock", func(t *testing.T) {
+		TestBackendLock(t, newBackend)
+	})
+
+	t.Run("Batch", func(t *testing.T) {
+		t.Run("Get", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("foo", "bar"))
+			assert.NoError(t, b.Set("foo2", "bar2"))
+
+			batch := b.Batch()
+			get := batch.Get("foo")
+			get2 := batch.Get("foo2")
+			get3 := batch.Get("foo3")
+			assert.NoError(t, batch.Exec())
+
+			v, err := get.Result()
+			assert.Equal(t, "bar", *v)
+			assert.NoError(t, err)
+
+			v, err = get2.Result()
+			assert.Equal(t, "bar2", *v)
+			assert.NoError(t, err)
+
+			v, err = get3.Result()
+			assert.Nil(t, v)
+			assert.NoError(t, err)
+		})
+
+		t.Run("SMembers", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.SAdd("set", "a"))
+			assert.NoError(t, b.SAdd("set", "b"))
+
+			batch := b.Batch()
+			smembers := batch.SMembers("set")
+			require.NoError(t, batch.Exec())
+			members, _ := smembers.Result()
+			assert.ElementsMatch(t, []string{"a", "b"}, members)
+
+			// DynamoDB has a 400KB size limit for items. Make sure sets work fine when they grow larger
+			// than that.
+			t.Run("LargeSet", func(t *testing.T) {
+				b := newBackend()
+
+				bigPrefix := strings.Repeat("x", 10000)
+				expected := make([]string, 90)
+				for i := 0; i < len(expected); i++ {
+					s := bigPrefix + strconv.FormatInt(int64(i), 10)
+					expected[i] = s
+					require.NoError(t, b.SAdd("foo", s))
+				}
+
+				batch := b.Batch()
+				smembers := batch.SMembers("foo")
+				require.NoError(t, batch.Exec())
+				members, _ := smembers.Result()
+				assert.ElementsMatch(t, expected, members)
+			})
+		})
+
+		t.Run("SortedSets", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.ZAdd("zset", "a", 1.0))
+			assert.NoError(t, b.ZAdd("zset", "b", 2.0))
+			assert.NoError(t, b.ZAdd("zset", "c", 3.0))
+			assert.NoError(t, b.ZAdd("lex", "a", 0.0))
+			assert.NoError(t, b.ZAdd("lex", "b", 0.0))
+
+			// Read some of these beforehand so that caches get a chance to serve them.
+			_, err := b.ZScore("zset", "a")
+			assert.NoError(t, err)
+			_, err = b.ZRangeByScore("zset", 2.0, 3.0, 0)
+			assert.NoError(t, err)
+
+			for i := 0; i < 2; i++ {
+				batch := b.Batch()
+				score := batch.ZScore("zset", "a")
+				missingScore := batch.ZScore("zset", "x")
+				count := batch.ZCount("zset", 2.0, 3.0)
+				byScore := batch.ZRangeByScore("zset", 2.0, 3.0, 0)
+				limited := batch.ZRangeByScore("zset", 1.0, 3.0, 1)
+				withScores := batch.ZRangeByScoreWithScores("zset", math.Inf(-1), 2.0, 0)
+				byLex := batch.ZRangeByLex("lex", "-", "+", 0)
+				require.NoError(t, batch.Exec())
+
+				s, err := score.Result()
+				assert.NoError(t, err)
+				if assert.NotNil(t, s) {
+					assert.Equal(t, 1.0, *s)
+				}
+
+				s, err = missingScore.Result()
+				assert.NoError(t, err)
+				assert.Nil(t, s)
+
+				n, err := count.Result()
+				assert.NoError(t, err)
+				assert.Equal(t, int64(2), n)
+
+				members, err := byScore.Result()
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"b", "c"}, members)
+
+				members, err = limited.Result()
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"a"}, members)
+
+				scoredMembers, err := withScores.Result()
+				assert.NoError(t, err)
+				assert.Equal(t, keyvaluestore.ScoredMembers{
+					{Score: 1.0, Value: "a"},
+					{Score: 2.0, Value: "b"},
+				}, scoredMembers)
+
+				members, err = byLex.Result()
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"a", "b"}, members)
+			}
+
+			batch := b.Batch()
+			incr := batch.ZIncrBy("zset", "a", 5.0)
+			require.NoError(t, batch.Exec())
+
+			f, err := incr.Result()
+			assert.NoError(t, err)
+			assert.Equal(t, 6.0, f)
+
+			members, err := b.ZRangeByScore("zset", math.Inf(-1), math.Inf(1), 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b", "c", "a"}, members)
+		})
+
+		t.Run("Counters", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("existing", "x"))
+
+			batch := b.Batch()
+			addInt := batch.AddInt("counter", 2)
+			setNX := batch.SetNX("new", "a")
+			setNXExisting := batch.SetNX("existing", "a")
+			setXX := batch.SetXX("existing", "b")
+			setXXMissing := batch.SetXX("missing", "b")
+			require.NoError(t, batch.Exec())
+
+			n, err := addInt.Result()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+
+			for _, tc := range []struct {
+				result   keyvaluestore.BoolResult
+				expected bool
+			}{
+				{setNX, true},
+				{setNXExisting, false},
+				{setXX, true},
+				{setXXMissing, false},
+			} {
+				ok, err := tc.result.Result()
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expected, ok)
+			}
+
+			v, err := b.Get("new")
+			assert.NoError(t, err)
+			assert.Equal(t, "a", *v)
+
+			v, err = b.Get("existing")
+			assert.NoError(t, err)
+			assert.Equal(t, "b", *v)
+
+			v, err = b.Get("missing")
+			assert.NoError(t, err)
+			assert.Nil(t, v)
+		})
+
+		t.Run("Sets", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.SAdd("set", "a", "b"))
+
+			batch := b.Batch()
+			isMember := batch.SIsMember("set", "a")
+			isNotMember := batch.SIsMember("set", "c")
+			card := batch.SCard("set")
+			randMember := batch.SRandMember("set")
+			require.NoError(t, batch.Exec())
+
+			ok, err := isMember.Result()
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			ok, err = isNotMember.Result()
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			n, err := card.Result()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(2), n)
+
+			member, err := randMember.Result()
+			assert.NoError(t, err)
+			if assert.NotNil(t, member) {
+				assert.Contains(t, []string{"a", "b"}, *member)
+			}
+
+			batch = b.Batch()
+			pop := batch.SPop("set")
+			require.NoError(t, batch.Exec())
+
+			member, err = pop.Result()
+			assert.NoError(t, err)
+			if assert.NotNil(t, member) {
+				ok, err := b.SIsMember("set", *member)
+				assert.NoError(t, err)
+				assert.False(t, ok)
+			}
+		})
+
+		t.Run("Set", func(t *testing.T) {
+			b := newBackend()
+
+			batch := b.Batch()
+			batch.Set("foo", "a")
+			batch.Set("foo", "b")
+			require.NoError(t, batch.Exec())
+
+			foo, err := b.Get("foo")
+			require.NotNil(t, foo)
+			assert.Equal(t, "b", *foo)
+			assert.NoError(t, err)
+		})
+
+		t.Run("ZAdd", func(t *testing.T) {
+			b := newBackend()
+
+			batch := b.Batch()
+			batch.ZAdd("foo", "a", 0.0)
+			batch.ZAdd("foo", "b", 10.0)
+			require.NoError(t, batch.Exec())
+
+			members, err := b.ZRangeByScore("foo", 0.0, 100.0, 0)
+			assert.Equal(t, []string{"a", "b"}, members)
+			assert.NoError(t, err)
+
+			batch = b.Batch()
+			batch.ZAdd("foo", "a", 5.0)
+			batch.ZAdd("foo", "a", 20.0)
+			require.NoError(t, batch.Exec())
+
+			members, err = b.ZRangeByScore("foo", 0.0, 100.0, 0)
+			assert.Equal(t, []string{"b", "a"}, members)
+			assert.NoError(t, err)
+		})
+	})
+
+	t.Run("CAS", func(t *testing.T) {
+		t.Run("Set", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("foo", "bar"))
+
+			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
+				assert.Equal(t, "bar", *prev)
+				return "baz", nil
+			})
+			assert.True(t, success)
+			assert.NoError(t, err)
+
+			v, err := b.Get("foo")
+			require.NoError(t, err)
+			assert.Equal(t, "baz", *v)
+		})
+
+		t.Run("Contention", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("foo", "bar"))
+
+			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
+				assert.Equal(t, "bar", *prev)
+				assert.NoError(t, b.Set("foo", "qux"))
+				return "baz", nil
+			})
+			assert.False(t, success)
+			assert.NoError(t, err)
+
+			v, err := b.Get("foo")
+			require.NoError(t, err)
+			assert.Equal(t, "qux", *v)
+		})
+
+		t.Run("NOP", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("foo", "bar"))
+
+			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
+				return nil, nil
+			})
+			assert.True(t, success)
+			assert.NoError(t, err)
+
+			v, err := b.Get("foo")
+			require.NoError(t, err)
+			assert.Equal(t, "bar", *v)
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("foo", "bar"))
+
+			success, err := b.CAS("foo", func(prev *string) (interface{}, error) {
+				return nil, fmt.Errorf("err")
+			})
+			assert.False(t, success)
+			assert.Error(t, err)
+
+			v, err := b.Get("foo")
+			require.NoError(t, err)
+			assert.Equal(t, "bar", *v)
+		})
+	})
+
+	t.Run("ZRem", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 0.0))
+
+		members, err := b.ZRangeByLex("foo", "-", "+", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, members)
+
+		assert.NoError(t, b.ZRem("foo", "a"))
+
+		members, err = b.ZRangeByLex("foo", "-", "+", 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b"}, members)
+	})
+
+	t.Run("ZRangeByScore", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "-2", -2.0))
+		assert.NoError(t, b.ZAdd("foo", "-1", -1.0))
+		assert.NoError(t, b.ZAdd("foo", "-0.5", -0.5))
+		assert.NoError(t, b.ZAdd("foo", "0", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "0.5", 0.5))
+		assert.NoError(t, b.ZAdd("foo", "0.5b", 0.5))
+		assert.NoError(t, b.ZAdd("foo", "1", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "2", 2.0))
+
+		t.Run("MinMax", func(t *testing.T) {
+			members, err := b.ZRangeByScore("foo", -0.5, 1.0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"-0.5", "0", "0.5", "0.5b", "1"}, members)
+		})
+
+		t.Run("-Inf", func(t *testing.T) {
+			members, err := b.ZRangeByScore("foo", math.Inf(-1), 1, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"-2", "-1", "-0.5", "0", "0.5", "0.5b", "1"}, members)
+		})
+
+		t.Run("+Inf", func(t *testing.T) {
+			members, err := b.ZRangeByScore("foo", -0.5, math.Inf(1), 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"-0.5", "0", "0.5", "0.5b", "1", "2"}, members)
+		})
+
+		t.Run("Rev", func(t *testing.T) {
+			t.Run("MinMax", func(t *testing.T) {
+				members, err := b.ZRevRangeByScore("foo", -0.5, 1.0, 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"1", "0.5b", "0.5", "0", "-0.5"}, members)
+			})
+
+			t.Run("-Inf", func(t *testing.T) {
+				members, err := b.ZRevRangeByScore("foo", math.Inf(-1), 1, 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"1", "0.5b", "0.5", "0", "-0.5", "-1", "-2"}, members)
+			})
+
+			t.Run("+Inf", func(t *testing.T) {
+				members, err := b.ZRevRangeByScore("foo", -0.5, math.Inf(1), 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"2", "1", "0.5b", "0.5", "0", "-0.5"}, members)
+			})
+		})
+
+		t.Run("Update", func(t *testing.T) {
+			assert.NoError(t, b.ZAdd("update-test", "foo", 2.0))
+
+			members, err := b.ZRangeByScore("update-test", 1.5, 2.5, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"foo"}, members)
+
+			assert.NoError(t, b.ZAdd("update-test", "foo", 3.0))
+
+			members, err = b.ZRangeByScore("update-test", 1.5, 2.5, 0)
+			assert.NoError(t, err)
+			assert.Empty(t, members)
+
+			members, err = b.ZRangeByScore("update-test", 2.5, 3.5, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"foo"}, members)
+		})
+	})
+
+	t.Run("ZRangeByLex", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "c", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "d", 0.0))
+
+		t.Run("Inf", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "-", "+", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"a", "b", "c", "d"}, members)
+		})
+
+		t.Run("MinGreaterThanMax", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "(d", "(a", 0)
+			assert.NoError(t, err)
+			assert.Empty(t, members)
+		})
+
+		t.Run("MinMaxExclusive", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "(a", "(d", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b", "c"}, members)
+		})
+
+		t.Run("MinMaxInclusive", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "[a", "[d", 0)
+			assert.NoError(t, err)
 			assert.Equal(t, []string{"a", "b", "c", "d"}, members)
 		})
 
-		t.Run("RangeInclusive", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "[b", "[c", 0)
+		t.Run("RangeInclusive", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "[b", "[c", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b", "c"}, members)
+		})
+
+		t.Run("SingleElement", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "[b", "[b", 0)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"b"}, members)
+		})
+
+		t.Run("SingleAbsentElement", func(t *testing.T) {
+			members, err := b.ZRangeByLex("foo", "[z", "[z", 1)
+			assert.NoError(t, err)
+			assert.Empty(t, members)
+		})
+
+		t.Run("Rev", func(t *testing.T) {
+			t.Run("Inf", func(t *testing.T) {
+				members, err := b.ZRevRangeByLex("foo", "-", "+", 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"d", "c", "b", "a"}, members)
+			})
+
+			t.Run("MinMaxExclusive", func(t *testing.T) {
+				members, err := b.ZRevRangeByLex("foo", "(a", "(d", 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"c", "b"}, members)
+			})
+
+			t.Run("MinMaxInclusive", func(t *testing.T) {
+				members, err := b.ZRevRangeByLex("foo", "[a", "[d", 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"d", "c", "b", "a"}, members)
+			})
+
+			t.Run("RangeInclusive", func(t *testing.T) {
+				members, err := b.ZRevRangeByLex("foo", "[b", "[c", 0)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"c", "b"}, members)
+			})
+
+			t.Run("SingleAbsentElement", func(t *testing.T) {
+				members, err := b.ZRangeByLex("foo", "[z", "[z", 1)
+				assert.NoError(t, err)
+				assert.Empty(t, members)
+			})
+		})
+	})
+
+	t.Run("ZScore", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
+
+		zeroF := 0.0
+		oneF := 1.0
+		for _, tc := range []struct {
+			member   string
+			expected *float64
+		}{
+			{"a", &zeroF},
+			{"b", &oneF},
+			{"c", nil},
+		} {
+			score, err := b.ZScore("foo", tc.member)
+			assert.NoError(t, err)
+			if tc.expected == nil {
+				assert.Nil(t, score)
+			} else {
+				if assert.NotNil(t, score) {
+					assert.Equal(t, *tc.expected, *score)
+				}
+			}
+		}
+	})
+
+	t.Run("ZCount", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "c", 2.0))
+		assert.NoError(t, b.ZAdd("foo", "d", 3.0))
+		assert.NoError(t, b.ZAdd("foo", "e", 4.0))
+		assert.NoError(t, b.ZAdd("foo", "f", 5.0))
+
+		for _, tc := range []struct {
+			min, max float64
+			expected int
+		}{
+			{1.0, 2.0, 2},
+			{1.0, 1.5, 1},
+			{math.Inf(-1), 2, 3},
+			{math.Inf(-1), math.Inf(1), 6},
+			{2.0, math.Inf(1), 4},
+		} {
+			n, err := b.ZCount("foo", tc.min, tc.max)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, n, fmt.Sprintf("%#v %#v", tc.min, tc.max))
+		}
+	})
+
+	t.Run("ZLexCount", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "c", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "e", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "g", 0.0))
+
+		for _, tc := range []struct {
+			min, max string
+			expected int
+		}{
+			{"[g", "[g", 1},
+			{"[a", "[g", 4},
+			{"(a", "[g", 3},
+			{"[a", "(g", 3},
+			{"[c", "[e", 2},
+			{"[e", "(g", 1},
+			{"(a", "[e", 2},
+			{"[e", "[e", 1},
+			{"[f", "[f", 0},
+			{"[_", "[g", 4},
+			{"[a", "[h", 4},
+			{"-", "[e", 3},
+			{"[c", "+", 3},
+			{"-", "+", 4},
+			{"[a", "(e", 2},
+			{"[a", "(f", 3},
+		} {
+			n, err := b.ZLexCount("foo", tc.min, tc.max)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, n, fmt.Sprintf("%#v %#v", tc.min, tc.max))
+		}
+	})
+
+	t.Run("ZIncrBy", func(t *testing.T) {
+		b := newBackend()
+
+		t.Run("ExistingKey", func(t *testing.T) {
+			assert.NoError(t, b.ZAdd("existing", "abc", 0.5))
+
+			newVal, err := b.ZIncrBy("existing", "abc", 1)
+			require.NoError(t, err)
+
+			assert.EqualValues(t, 1.5, newVal)
+
+			vals, err := b.ZRangeByScore("existing", 1.5, 1.5, 10)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"abc"}, vals)
+
+			vals, err = b.ZRangeByScore("existing", 0, 1, 10)
+			require.NoError(t, err)
+
+			assert.Empty(t, vals)
+		})
+
+		t.Run("NoExistingKey", func(t *testing.T) {
+			newVal, err := b.ZIncrBy("missing", "bcd", 1)
+			require.NoError(t, err)
+
+			assert.EqualValues(t, 1, newVal)
+
+			vals, err := b.ZRangeByScore("missing", 1, 1, 10)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"bcd"}, vals)
+		})
+
+		t.Run("Negative", func(t *testing.T) {
+			assert.NoError(t, b.ZAdd("neg", "cde", 0.5))
+
+			newVal, err := b.ZIncrBy("neg", "cde", -1)
+			require.NoError(t, err)
+
+			assert.EqualValues(t, -0.5, newVal)
+
+			vals, err := b.ZRangeByScore("neg", -0.5, -0.5, 10)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"cde"}, vals)
+
+			vals, err = b.ZRangeByScore("neg", 0, 1, 10)
+			require.NoError(t, err)
+
+			assert.Empty(t, vals)
+		})
+
+		t.Run("MultipleWriters", func(t *testing.T) {
+			outerLoops := 10
+			innerLoops := 10
+			var wg sync.WaitGroup
+
+			for i := 0; i < outerLoops; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					successful := 0
+					for successful < innerLoops {
+						_, err := b.ZIncrBy("MultipleWriters", "foo", 1)
+
+						if err == nil {
+							successful++
+						}
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			vals, err := b.ZRangeByScore("MultipleWriters", float64(outerLoops*innerLoops), float64(outerLoops*innerLoops), 10)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"foo"}, vals)
+		})
+	})
+
+	t.Run("ZRangeByScoreWithScores", func(t *testing.T) {
+		b := newBackend()
+
+		assert.NoError(t, b.ZAdd("foo", "-2", -2.0))
+		assert.NoError(t, b.ZAdd("foo", "-1", -1.0))
+		assert.NoError(t, b.ZAdd("foo", "-0.5", -0.5))
+		assert.NoError(t, b.ZAdd("foo", "0", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "0.5", 0.5))
+		assert.NoError(t, b.ZAdd("foo", "0.5b", 0.5))
+		assert.NoError(t, b.ZAdd("foo", "1", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "2", 2.0))
+
+		t.Run("MinMax", func(t *testing.T) {
+			members, err := b.ZRangeByScoreWithScores("foo", -0.5, 1.0, 0)
 			assert.NoError(t, err)
-			assert.Equal(t, []string{"b", "c"}, members)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: -0.5, Value: "-0.5"},
+				{Score: 0, Value: "0"},
+				{Score: 0.5, Value: "0.5"},
+				{Score: 0.5, Value: "0.5b"},
+				{Score: 1, Value: "1"},
+			}, members)
 		})
 
-		t.Run("SingleElement", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "[b", "[b", 0)
+		t.Run("-Inf", func(t *testing.T) {
+			members, err := b.ZRangeByScoreWithScores("foo", math.Inf(-1), 1, 0)
 			assert.NoError(t, err)
-			assert.Equal(t, []string{"b"}, members)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: -2, Value: "-2"},
+				{Score: -1, Value: "-1"},
+				{Score: -0.5, Value: "-0.5"},
+				{Score: 0, Value: "0"},
+				{Score: 0.5, Value: "0.5"},
+				{Score: 0.5, Value: "0.5b"},
+				{Score: 1, Value: "1"},
+			}, members)
 		})
 
-		t.Run("SingleAbsentElement", func(t *testing.T) {
-			members, err := b.ZRangeByLex("foo", "[z", "[z", 1)
+		t.Run("+Inf", func(t *testing.T) {
+			members, err := b.ZRangeByScoreWithScores("foo", -0.5, math.Inf(1), 0)
 			assert.NoError(t, err)
-			assert.Empty(t, members)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: -0.5, Value: "-0.5"},
+				{Score: 0, Value: "0"},
+				{Score: 0.5, Value: "0.5"},
+				{Score: 0.5, Value: "0.5b"},
+				{Score: 1, Value: "1"},
+				{Score: 2, Value: "2"},
+			}, members)
 		})
+	})
 
-		t.Run("Rev", func(t *testing.T) {
-			t.Run("Inf", func(t *testing.T) {
-				members, err := b.ZRevRangeByLex("foo", "-", "+", 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"d", "c", "b", "a"}, members)
-			})
+	t.Run("ZRevRangeByScoreWithScores", func(t *testing.T) {
+		b := newBackend()
 
-			t.Run("MinMaxExclusive", func(t *testing.T) {
-				members, err := b.ZRevRangeByLex("foo", "(a", "(d", 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"c", "b"}, members)
-			})
+		assert.NoError(t, b.ZAdd("foo", "-2", -2.0))
+		assert.NoError(t, b.ZAdd("foo", "-1", -1.0))
+		assert.NoError(t, b.ZAdd("foo", "-0.5", -0.5))
+		assert.NoError(t, b.ZAdd("foo", "0", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "0.5", 0.5))
+		assert.NoError(t, b.ZAdd("foo", "0.5b", 0.5))
+		assert.NoError(t, b.ZAdd("foo", "1", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "2", 2.0))
 
-			t.Run("MinMaxInclusive", func(t *testing.T) {
-				members, err := b.ZRevRangeByLex("foo", "[a", "[d", 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"d", "c", "b", "a"}, members)
-			})
+		t.Run("MinMax", func(t *testing.T) {
+			members, err := b.ZRevRangeByScoreWithScores("foo", -0.5, 1.0, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: 1, Value: "1"},
+				{Score: 0.5, Value: "0.5b"},
+				{Score: 0.5, Value: "0.5"},
+				{Score: 0, Value: "0"},
+				{Score: -0.5, Value: "-0.5"},
+			}, members)
+		})
 
-			t.Run("RangeInclusive", func(t *testing.T) {
-				members, err := b.ZRevRangeByLex("foo", "[b", "[c", 0)
-				assert.NoError(t, err)
-				assert.Equal(t, []string{"c", "b"}, members)
-			})
+		t.Run("-Inf", func(t *testing.T) {
+			members, err := b.ZRevRangeByScoreWithScores("foo", math.Inf(-1), 1, 0)
+			assert.NoError(t, err)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: 1, Value: "1"},
+				{Score: 0.5, Value: "0.5b"},
+				{Score: 0.5, Value: "0.5"},
+				{Score: 0, Value: "0"},
+				{Score: -0.5, Value: "-0.5"},
+				{Score: -1, Value: "-1"},
+				{Score: -2, Value: "-2"},
+			}, members)
+		})
 
-			t.Run("SingleAbsentElement", func(t *testing.T) {
-				members, err := b.ZRangeByLex("foo", "[z", "[z", 1)
-				assert.NoError(t, err)
-				assert.Empty(t, members)
-			})
+		t.Run("+Inf", func(t *testing.T) {
+			members, err := b.ZRevRangeByScoreWithScores("foo", -0.5, math.Inf(1), 0)
+			assert.NoError(t, err)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: 2, Value: "2"},
+				{Score: 1, Value: "1"},
+				{Score: 0.5, Value: "0.5b"},
+				{Score: 0.5, Value: "0.5"},
+				{Score: 0, Value: "0"},
+				{Score: -0.5, Value: "-0.5"},
+			}, members)
 		})
 	})
 
-	t.Run("ZScore", func(t *testing.T) {
+	t.Run("ZRangeByScoreRange", func(t *testing.T) {
 		b := newBackend()
 
 		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
 		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "c", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "d", 2.0))
+		assert.NoError(t, b.ZAdd("foo", "e", 3.0))
 
-		zeroF := 0.0
-		oneF := 1.0
 		for _, tc := range []struct {
-			member   string
-			expected *float64
+			r        keyvaluestore.ScoreRange
+			expected []string
+			reversed []string
 		}{
-			{"a", &zeroF},
-			{"b", &oneF},
-			{"c", nil},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.MinScore, Max: keyvaluestore.MaxScore},
+				expected: []string{"a", "b", "c", "d", "e"},
+				reversed: []string{"e", "d", "c", "b", "a"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.ExclusiveScore(0.0), Max: keyvaluestore.InclusiveScore(2.0)},
+				expected: []string{"b", "c", "d"},
+				reversed: []string{"d", "c", "b"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.InclusiveScore(0.0), Max: keyvaluestore.ExclusiveScore(2.0)},
+				expected: []string{"a", "b", "c"},
+				reversed: []string{"c", "b", "a"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.ExclusiveScore(1.0), Max: keyvaluestore.ExclusiveScore(3.0)},
+				expected: []string{"d"},
+				reversed: []string{"d"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.ExclusiveScore(1.0), Max: keyvaluestore.ExclusiveScore(1.0)},
+				expected: nil,
+				reversed: nil,
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.ExclusiveScore(math.Inf(-1)), Max: keyvaluestore.ExclusiveScore(math.Inf(1))},
+				expected: []string{"a", "b", "c", "d", "e"},
+				reversed: []string{"e", "d", "c", "b", "a"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.MinScore, Max: keyvaluestore.MaxScore, Offset: 1, Limit: 2},
+				expected: []string{"b", "c"},
+				reversed: []string{"d", "c"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.MinScore, Max: keyvaluestore.MaxScore, Offset: 3},
+				expected: []string{"d", "e"},
+				reversed: []string{"b", "a"},
+			},
+			{
+				r:        keyvaluestore.ScoreRange{Min: keyvaluestore.MinScore, Max: keyvaluestore.MaxScore, Offset: 10},
+				expected: nil,
+				reversed: nil,
+			},
 		} {
-			score, err := b.ZScore("foo", tc.member)
+			members, err := b.ZRangeByScoreRange("foo", tc.r)
 			assert.NoError(t, err)
-			if tc.expected == nil {
-				assert.Nil(t, score)
-			} else {
-				if assert.NotNil(t, score) {
-					assert.Equal(t, *tc.expected, *score)
-				}
+			assert.Equal(t, len(tc.expected), len(members), fmt.Sprintf("%+v", tc.r))
+			for i := range members {
+				assert.Equal(t, tc.expected[i], members[i].Value)
+			}
+
+			members, err = b.ZRevRangeByScoreRange("foo", tc.r)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.reversed), len(members), fmt.Sprintf("rev %+v", tc.r))
+			for i := range members {
+				assert.Equal(t, tc.reversed[i], members[i].Value)
+			}
+
+			if tc.r.Offset == 0 && tc.r.Limit == 0 {
+				n, err := b.ZCountRange("foo", tc.r.Min, tc.r.Max)
+				assert.NoError(t, err)
+				assert.Equal(t, len(tc.expected), n, fmt.Sprintf("count %+v", tc.r))
 			}
 		}
+
+		members, err := b.ZRangeByScoreRange("foo", keyvaluestore.ScoreRange{Min: keyvaluestore.InclusiveScore(1.0), Max: keyvaluestore.InclusiveScore(1.0)})
+		assert.NoError(t, err)
+		assert.Equal(t, keyvaluestore.ScoredMembers{
+			{Score: 1.0, Value: "b"},
+			{Score: 1.0, Value: "c"},
+		}, members)
 	})
 
-	t.Run("ZCount", func(t *testing.T) {
+	t.Run("ZRangeByScorePage", func(t *testing.T) {
 		b := newBackend()
 
-		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
-		assert.NoError(t, b.ZAdd("foo", "c", 2.0))
-		assert.NoError(t, b.ZAdd("foo", "d", 3.0))
-		assert.NoError(t, b.ZAdd("foo", "e", 4.0))
-		assert.NoError(t, b.ZAdd("foo", "f", 5.0))
+		for i, member := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			// Give most of the members the same score so that pages have to split ties.
+			score := 1.0
+			if i == 0 {
+				score = 0.0
+			} else if i == 7 {
+				score = 2.0
+			}
+			assert.NoError(t, b.ZAdd("foo", member, score))
+		}
 
 		for _, tc := range []struct {
 			min, max float64
-			expected int
 		}{
-			{1.0, 2.0, 2},
-			{1.0, 1.5, 1},
-			{math.Inf(-1), 2, 3},
-			{math.Inf(-1), math.Inf(1), 6},
-			{2.0, math.Inf(1), 4},
+			{math.Inf(-1), math.Inf(1)},
+			{1.0, 1.0},
+			{0.5, 2.0},
 		} {
-			n, err := b.ZCount("foo", tc.min, tc.max)
-			assert.NoError(t, err)
-			assert.Equal(t, tc.expected, n, fmt.Sprintf("%#v %#v", tc.min, tc.max))
+			expected, err := b.ZRangeByScoreWithScores("foo", tc.min, tc.max, 0)
+			require.NoError(t, err)
+
+			reversed, err := b.ZRevRangeByScoreWithScores("foo", tc.min, tc.max, 0)
+			require.NoError(t, err)
+
+			for _, limit := range []int{0, 1, 2, 3, 100} {
+				var members keyvaluestore.ScoredMembers
+				cursor := ""
+				for i := 0; i < 100; i++ {
+					page, err := b.ZRangeByScorePage("foo", tc.min, tc.max, cursor, limit)
+					require.NoError(t, err)
+					if limit > 0 {
+						assert.True(t, len(page.Members) <= limit)
+					}
+					members = append(members, page.Members...)
+					if cursor = page.Cursor; cursor == "" {
+						break
+					}
+				}
+				assert.Equal(t, expected, members, fmt.Sprintf("%v %v %v", tc.min, tc.max, limit))
+
+				members = nil
+				cursor = ""
+				for i := 0; i < 100; i++ {
+					page, err := b.ZRevRangeByScorePage("foo", tc.min, tc.max, cursor, limit)
+					require.NoError(t, err)
+					members = append(members, page.Members...)
+					if cursor = page.Cursor; cursor == "" {
+						break
+					}
+				}
+				assert.Equal(t, reversed, members, fmt.Sprintf("rev %v %v %v", tc.min, tc.max, limit))
+			}
 		}
+
+		t.Run("InvalidCursor", func(t *testing.T) {
+			_, err := b.ZRangeByScorePage("foo", math.Inf(-1), math.Inf(1), "!", 1)
+			assert.Error(t, err)
+		})
 	})
 
-	t.Run("ZLexCount", func(t *testing.T) {
+	t.Run("ZRangeByLexPage", func(t *testing.T) {
 		b := newBackend()
 
-		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "c", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "e", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "g", 0.0))
+		for _, member := range []string{"a", "b", "c", "d", "e"} {
+			assert.NoError(t, b.ZAdd("foo", member, 0.0))
+		}
 
 		for _, tc := range []struct {
 			min, max string
-			expected int
+			expected []string
 		}{
-			{"[g", "[g", 1},
-			{"[a", "[g", 4},
-			{"(a", "[g", 3},
-			{"[a", "(g", 3},
-			{"[c", "[e", 2},
-			{"[e", "(g", 1},
-			{"(a", "[e", 2},
-			{"[e", "[e", 1},
-			{"[f", "[f", 0},
-			{"[_", "[g", 4},
-			{"[a", "[h", 4},
-			{"-", "[e", 3},
-			{"[c", "+", 3},
-			{"-", "+", 4},
-			{"[a", "(e", 2},
-			{"[a", "(f", 3},
+			{"-", "+", []string{"a", "b", "c", "d", "e"}},
+			{"(a", "[d", []string{"b", "c", "d"}},
+		} {
+			for _, limit := range []int{0, 1, 2, 100} {
+				var members []string
+				cursor := ""
+				for i := 0; i < 100; i++ {
+					page, err := b.ZRangeByLexPage("foo", tc.min, tc.max, cursor, limit)
+					require.NoError(t, err)
+					members = append(members, page.Members...)
+					if cursor = page.Cursor; cursor == "" {
+						break
+					}
+				}
+				assert.Equal(t, tc.expected, members, fmt.Sprintf("%v %v %v", tc.min, tc.max, limit))
+
+				members = nil
+				cursor = ""
+				for i := 0; i < 100; i++ {
+					page, err := b.ZRevRangeByLexPage("foo", tc.min, tc.max, cursor, limit)
+					require.NoError(t, err)
+					members = append(members, page.Members...)
+					if cursor = page.Cursor; cursor == "" {
+						break
+					}
+				}
+				require.Len(t, members, len(tc.expected))
+				for i := range members {
+					assert.Equal(t, tc.expected[len(tc.expected)-1-i], members[i])
+				}
+			}
+		}
+	})
+
+	t.Run("ZCard", func(t *testing.T) {
+		b := newBackend()
+
+		n, err := b.ZCard("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, n)
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 2.0))
+
+		n, err = b.ZCard("foo")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+
+	t.Run("ZRank", func(t *testing.T) {
+		b := newBackend()
+
+		rank, err := b.ZRank("foo", "a")
+		assert.NoError(t, err)
+		assert.Nil(t, rank)
+
+		assert.NoError(t, b.ZAdd("foo", "c", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "d", -1.0))
+
+		for member, expected := range map[string]int{"d": 0, "a": 1, "b": 2, "c": 3} {
+			rank, err := b.ZRank("foo", member)
+			assert.NoError(t, err)
+			if assert.NotNil(t, rank, member) {
+				assert.Equal(t, expected, *rank, member)
+			}
+
+			rank, err = b.ZRevRank("foo", member)
+			assert.NoError(t, err)
+			if assert.NotNil(t, rank, member) {
+				assert.Equal(t, 3-expected, *rank, member)
+			}
+		}
+
+		rank, err = b.ZRevRank("foo", "e")
+		assert.NoError(t, err)
+		assert.Nil(t, rank)
+	})
+
+	t.Run("ZRange", func(t *testing.T) {
+		b := newBackend()
+
+		members, err := b.ZRange("foo", 0, -1)
+		assert.NoError(t, err)
+		assert.Empty(t, members)
+
+		assert.NoError(t, b.ZAdd("foo", "a", 0.0))
+		assert.NoError(t, b.ZAdd("foo", "b", 1.0))
+		assert.NoError(t, b.ZAdd("foo", "c", 2.0))
+		assert.NoError(t, b.ZAdd("foo", "d", 3.0))
+
+		for _, tc := range []struct {
+			start, stop int
+			expected    []string
+			reversed    []string
+		}{
+			{0, -1, []string{"a", "b", "c", "d"}, []string{"d", "c", "b", "a"}},
+			{0, 0, []string{"a"}, []string{"d"}},
+			{1, 2, []string{"b", "c"}, []string{"c", "b"}},
+			{2, 10, []string{"c", "d"}, []string{"b", "a"}},
+			{-2, -1, []string{"c", "d"}, []string{"b", "a"}},
+			{-10, 1, []string{"a", "b"}, []string{"d", "c"}},
+			{2, 1, nil, nil},
+			{4, 5, nil, nil},
+			{0, -5, nil, nil},
 		} {
-			n, err := b.ZLexCount("foo", tc.min, tc.max)
+			members, err := b.ZRange("foo", tc.start, tc.stop)
 			assert.NoError(t, err)
-			assert.Equal(t, tc.expected, n, fmt.Sprintf("%#v %#v", tc.min, tc.max))
+			assert.Equal(t, len(tc.expected), len(members), fmt.Sprintf("%v %v", tc.start, tc.stop))
+			for i := range members {
+				assert.Equal(t, tc.expected[i], members[i])
+			}
+
+			members, err = b.ZRevRange("foo", tc.start, tc.stop)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.reversed), len(members), fmt.Sprintf("%v %v", tc.start, tc.stop))
+			for i := range members {
+				assert.Equal(t, tc.reversed[i], members[i])
+			}
 		}
+
+		t.Run("WithScores", func(t *testing.T) {
+			members, err := b.ZRangeWithScores("foo", 1, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: 1.0, Value: "b"},
+				{Score: 2.0, Value: "c"},
+			}, members)
+
+			members, err = b.ZRevRangeWithScores("foo", -2, -1)
+			assert.NoError(t, err)
+			assert.Equal(t, keyvaluestore.ScoredMembers{
+				{Score: 1.0, Value: "b"},
+				{Score: 0.0, Value: "a"},
+			}, members)
+		})
 	})
 
-	t.Run("ZIncrBy", func(t *testing.T) {
-		b := newBackend()
+	t.Run("Hash", func(t *testing.T) {
+		t.Run("HSet", func(t *testing.T) {
+			b := newBackend()
 
-		t.Run("ExistingKey", func(t *testing.T) {
-			assert.NoError(t, b.ZAdd("existing", "abc", 0.5))
+			v, err := b.HGet("foo", "a")
+			assert.NoError(t, err)
+			assert.Nil(t, v)
 
-			newVal, err := b.ZIncrBy("existing", "abc", 1)
-			require.NoError(t, err)
+			assert.NoError(t, b.HSet("foo", "a", "x"))
+			assert.NoError(t, b.HSet("foo", "b", 1))
+			assert.NoError(t, b.HSet("foo", "a", "y"))
 
-			assert.EqualValues(t, 1.5, newVal)
+			v, err = b.HGet("foo", "a")
+			assert.NoError(t, err)
+			require.NotNil(t, v)
+			assert.Equal(t, "y", *v)
 
-			vals, err := b.ZRangeByScore("existing", 1.5, 1.5, 10)
-			require.NoError(t, err)
+			v, err = b.HGet("foo", "b")
+			assert.NoError(t, err)
+			require.NotNil(t, v)
+			assert.Equal(t, "1", *v)
+		})
 
-			assert.Equal(t, []string{"abc"}, vals)
+		t.Run("HMGet", func(t *testing.T) {
+			b := newBackend()
 
-			vals, err = b.ZRangeByScore("existing", 0, 1, 10)
-			require.NoError(t, err)
+			assert.NoError(t, b.HSet("foo", "a", "x"))
+			assert.NoError(t, b.HSet("foo", "b", "y"))
 
-			assert.Empty(t, vals)
+			values, err := b.HMGet("foo", "b", "c", "a")
+			assert.NoError(t, err)
+			require.Len(t, values, 3)
+			require.NotNil(t, values[0])
+			assert.Equal(t, "y", *values[0])
+			assert.Nil(t, values[1])
+			require.NotNil(t, values[2])
+			assert.Equal(t, "x", *values[2])
 		})
 
-		t.Run("NoExistingKey", func(t *testing.T) {
-			newVal, err := b.ZIncrBy("missing", "bcd", 1)
-			require.NoError(t, err)
+		t.Run("HDel", func(t *testing.T) {
+			b := newBackend()
 
-			assert.EqualValues(t, 1, newVal)
+			assert.NoError(t, b.HSet("foo", "a", "x"))
+			assert.NoError(t, b.HSet("foo", "b", "y"))
+			assert.NoError(t, b.HSet("foo", "c", "z"))
 
-			vals, err := b.ZRangeByScore("missing", 1, 1, 10)
-			require.NoError(t, err)
+			assert.NoError(t, b.HDel("foo", "a", "c", "d"))
 
-			assert.Equal(t, []string{"bcd"}, vals)
+			fields, err := b.HGetAll("foo")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"b": "y"}, fields)
+
+			assert.NoError(t, b.HDel("foo", "b"))
+
+			fields, err = b.HGetAll("foo")
+			assert.NoError(t, err)
+			assert.Empty(t, fields)
 		})
 
-		t.Run("Negative", func(t *testing.T) {
-			assert.NoError(t, b.ZAdd("neg", "cde", 0.5))
+		t.Run("HGetAll", func(t *testing.T) {
+			b := newBackend()
 
-			newVal, err := b.ZIncrBy("neg", "cde", -1)
-			require.NoError(t, err)
+			fields, err := b.HGetAll("foo")
+			assert.NoError(t, err)
+			assert.Empty(t, fields)
 
-			assert.EqualValues(t, -0.5, newVal)
+			assert.NoError(t, b.HSet("foo", "a", "x"))
+			assert.NoError(t, b.HSet("foo", "b", "y"))
 
-			vals, err := b.ZRangeByScore("neg", -0.5, -0.5, 10)
-			require.NoError(t, err)
+			fields, err = b.HGetAll("foo")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"a": "x", "b": "y"}, fields)
 
-			assert.Equal(t, []string{"cde"}, vals)
+			// Modifying the result must not modify the hash.
+			fields["c"] = "z"
 
-			vals, err = b.ZRangeByScore("neg", 0, 1, 10)
-			require.NoError(t, err)
+			fields, err = b.HGetAll("foo")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"a": "x", "b": "y"}, fields)
+		})
 
-			assert.Empty(t, vals)
+		t.Run("HIncrBy", func(t *testing.T) {
+			b := newBackend()
+
+			n, err := b.HIncrBy("foo", "a", 2)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 2, n)
+
+			assert.NoError(t, b.HSet("foo", "b", 10))
+			n, err = b.HIncrBy("foo", "b", -3)
+			assert.NoError(t, err)
+			assert.EqualValues(t, 7, n)
+
+			v, err := b.HGet("foo", "a")
+			assert.NoError(t, err)
+			require.NotNil(t, v)
+			assert.Equal(t, "2", *v)
 		})
 
-		t.Run("MultipleWriters", func(t *testing.T) {
-			outerLoops := 10
-			innerLoops := 10
-			var wg sync.WaitGroup
+		t.Run("Batch", func(t *testing.T) {
+			b := newBackend()
 
-			for i := 0; i < outerLoops; i++ {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					successful := 0
-					for successful < innerLoops {
-						_, err := b.ZIncrBy("MultipleWriters", "foo", 1)
+			assert.NoError(t, b.Set("foo", "bar"))
+			assert.NoError(t, b.HSet("hash", "a", "x"))
+			assert.NoError(t, b.HSet("hash", "b", "y"))
 
-						if err == nil {
-							successful++
-						}
-					}
-				}()
-			}
+			batch := b.Batch()
+			get := batch.Get("foo")
+			hget := batch.HGet("hash", "a")
+			hgetMissing := batch.HGet("hash", "z")
+			hmget := batch.HMGet("hash", "b", "z")
+			hgetAll := batch.HGetAll("hash")
+			require.NoError(t, batch.Exec())
 
-			wg.Wait()
+			v, err := get.Result()
+			assert.NoError(t, err)
+			require.NotNil(t, v)
+			assert.Equal(t, "bar", *v)
 
-			vals, err := b.ZRangeByScore("MultipleWriters", float64(outerLoops*innerLoops), float64(outerLoops*innerLoops), 10)
-			require.NoError(t, err)
-			assert.Equal(t, []string{"foo"}, vals)
+			v, err = hget.Result()
+			assert.NoError(t, err)
+			require.NotNil(t, v)
+			assert.Equal(t, "x", *v)
+
+			v, err = hgetMissing.Result()
+			assert.NoError(t, err)
+			assert.Nil(t, v)
+
+			values, err := hmget.Result()
+			assert.NoError(t, err)
+			require.Len(t, values, 2)
+			require.NotNil(t, values[0])
+			assert.Equal(t, "y", *values[0])
+			assert.Nil(t, values[1])
+
+			fields, err := hgetAll.Result()
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"a": "x", "b": "y"}, fields)
+
+			batch = b.Batch()
+			hset := batch.HSet("hash", "c", "z")
+			hdel := batch.HDel("hash", "a", "b")
+			hincrby := batch.HIncrBy("hash", "n", 5)
+			require.NoError(t, batch.Exec())
+
+			assert.NoError(t, hset.Result())
+			assert.NoError(t, hdel.Result())
+			n, err := hincrby.Result()
+			assert.NoError(t, err)
+			assert.EqualValues(t, 5, n)
+
+			fields, err = b.HGetAll("hash")
+			assert.NoError(t, err)
+			assert.Equal(t, map[string]string{"c": "z", "n": "5"}, fields)
 		})
 	})
 
-	t.Run("ZRangeByScoreWithScores", func(t *testing.T) {
+	t.Run("Scan", func(t *testing.T) {
 		b := newBackend()
 
-		assert.NoError(t, b.ZAdd("foo", "-2", -2.0))
-		assert.NoError(t, b.ZAdd("foo", "-1", -1.0))
-		assert.NoError(t, b.ZAdd("foo", "-0.5", -0.5))
-		assert.NoError(t, b.ZAdd("foo", "0", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "0.5", 0.5))
-		assert.NoError(t, b.ZAdd("foo", "0.5b", 0.5))
-		assert.NoError(t, b.ZAdd("foo", "1", 1.0))
-		assert.NoError(t, b.ZAdd("foo", "2", 2.0))
+		assert.NoError(t, b.Set("scan:string", "x"))
+		assert.NoError(t, b.SAdd("scan:set", "a", "b"))
+		assert.NoError(t, b.ZAdd("scan:zset", "a", 1))
+		assert.NoError(t, b.ZAdd("scan:zset", "b", 2))
+		assert.NoError(t, b.HSet("scan:hash", "a", "x"))
+		assert.NoError(t, b.HSet("scan:hash", "b", "y"))
+		assert.NoError(t, b.Set("scan*", "x"))
+		assert.NoError(t, b.Set("other", "x"))
+
+		for _, limit := range []int{0, 1, 2, 100} {
+			t.Run(fmt.Sprintf("Limit%d", limit), func(t *testing.T) {
+				assert.Equal(t, map[string]keyvaluestore.KeyType{
+					"scan:string": keyvaluestore.KeyTypeString,
+					"scan:set":    keyvaluestore.KeyTypeSet,
+					"scan:zset":   keyvaluestore.KeyTypeSortedSet,
+					"scan:hash":   keyvaluestore.KeyTypeHash,
+				}, scanAll(t, b, "scan:", limit))
+			})
+		}
 
-		t.Run("MinMax", func(t *testing.T) {
-			members, err := b.ZRangeByScoreWithScores("foo", -0.5, 1.0, 0)
-			assert.NoError(t, err)
-			assert.Equal(t, keyvaluestore.ScoredMembers{
-				{Score: -0.5, Value: "-0.5"},
-				{Score: 0, Value: "0"},
-				{Score: 0.5, Value: "0.5"},
-				{Score: 0.5, Value: "0.5b"},
-				{Score: 1, Value: "1"},
-			}, members)
+		t.Run("Glob", func(t *testing.T) {
+			assert.Equal(t, map[string]keyvaluestore.KeyType{
+				"scan*": keyvaluestore.KeyTypeString,
+			}, scanAll(t, b, "scan*", 0))
 		})
+	})
 
-		t.Run("-Inf", func(t *testing.T) {
-			members, err := b.ZRangeByScoreWithScores("foo", math.Inf(-1), 1, 0)
+	t.Run("Errors", func(t *testing.T) {
+		t.Run("WrongType", func(t *testing.T) {
+			b := newBackend()
+
+			assert.NoError(t, b.Set("foo", "bar"))
+			_, err := b.AddInt("foo", 1)
+			assert.Equal(t, keyvaluestore.ErrWrongType, err)
+
+			assert.NoError(t, b.HSet("hash", "foo", "bar"))
+			_, err = b.HIncrBy("hash", "foo", 1)
+			assert.Equal(t, keyvaluestore.ErrWrongType, err)
+
+			// Backends that don't detect wrong types must still keep hash fields apart from strings.
+			fields, err := b.HGetAll("foo")
+			if err != keyvaluestore.ErrWrongType {
+				assert.NoError(t, err)
+				assert.Empty(t, fields)
+			}
+			if err := b.HSet("foo", "_", "baz"); err != keyvaluestore.ErrWrongType {
+				assert.NoError(t, err)
+			}
+			v, err := b.Get("foo")
 			assert.NoError(t, err)
-			assert.Equal(t, keyvaluestore.ScoredMembers{
-				{Score: -2, Value: "-2"},
-				{Score: -1, Value: "-1"},
-				{Score: -0.5, Value: "-0.5"},
-				{Score: 0, Value: "0"},
-				{Score: 0.5, Value: "0.5"},
-				{Score: 0.5, Value: "0.5b"},
-				{Score: 1, Value: "1"},
-			}, members)
-		})
+			if assert.NotNil(t, v) {
+				assert.Equal(t, "bar", *v)
+			}
 
-		t.Run("+Inf", func(t *testing.T) {
-			members, err := b.ZRangeByScoreWithScores("foo", -0.5, math.Inf(1), 0)
+			if !b.Capabilities().WrongTypeErrors {
+				return
+			}
+
+			t.Run("Set", func(t *testing.T) {
+				assert.Equal(t, keyvaluestore.ErrWrongType, b.SAdd("foo", "a"))
+				assert.Equal(t, keyvaluestore.ErrWrongType, b.SRem("foo", "a"))
+				_, err := b.SMembers("foo")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.SIsMember("foo", "a")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.SCard("foo")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.SPop("foo")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+			})
+
+			t.Run("SortedSet", func(t *testing.T) {
+				assert.Equal(t, keyvaluestore.ErrWrongType, b.ZAdd("foo", "a", 1))
+				assert.Equal(t, keyvaluestore.ErrWrongType, b.ZRem("foo", "a"))
+				_, err := b.ZScore("foo", "a")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.ZIncrBy("foo", "a", 1)
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.ZCount("foo", 0, 1)
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.ZRangeByScore("foo", 0, 1, 0)
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.ZCard("foo")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+			})
+
+			t.Run("Hash", func(t *testing.T) {
+				assert.Equal(t, keyvaluestore.ErrWrongType, b.HSet("foo", "a", "b"))
+				assert.Equal(t, keyvaluestore.ErrWrongType, b.HDel("foo", "a"))
+				_, err := b.HGet("foo", "a")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.HGetAll("foo")
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+				_, err = b.HIncrBy("foo", "a", 1)
+				assert.Equal(t, keyvaluestore.ErrWrongType, err)
+			})
+
+			// The string is left intact.
+			v, err = b.Get("foo")
 			assert.NoError(t, err)
-			assert.Equal(t, keyvaluestore.ScoredMembers{
-				{Score: -0.5, Value: "-0.5"},
-				{Score: 0, Value: "0"},
-				{Score: 0.5, Value: "0.5"},
-				{Score: 0.5, Value: "0.5b"},
-				{Score: 1, Value: "1"},
-				{Score: 2, Value: "2"},
-			}, members)
+			if assert.NotNil(t, v) {
+				assert.Equal(t, "bar", *v)
+			}
 		})
-	})
 
-	t.Run("ZRevRangeByScoreWithScores", func(t *testing.T) {
-		b := newBackend()
+		t.Run("AtomicWriteWrongType", func(t *testing.T) {
+			b := newBackend()
+			if !b.Capabilities().WrongTypeErrors {
+				t.Skip("backend doesn't detect wrong types")
+			}
 
-		assert.NoError(t, b.ZAdd("foo", "-2", -2.0))
-		assert.NoError(t, b.ZAdd("foo", "-1", -1.0))
-		assert.NoError(t, b.ZAdd("foo", "-0.5", -0.5))
-		assert.NoError(t, b.ZAdd("foo", "0", 0.0))
-		assert.NoError(t, b.ZAdd("foo", "0.5", 0.5))
-		assert.NoError(t, b.ZAdd("foo", "0.5b", 0.5))
-		assert.NoError(t, b.ZAdd("foo", "1", 1.0))
-		assert.NoError(t, b.ZAdd("foo", "2", 2.0))
+			assert.NoError(t, b.Set("foo", "bar"))
 
-		t.Run("MinMax", func(t *testing.T) {
-			members, err := b.ZRevRangeByScoreWithScores("foo", -0.5, 1.0, 0)
+			// None of the writes are applied when any of them is against the wrong type.
+			for name, write := range map[string]func(tx keyvaluestore.AtomicWriteOperation){
+				"SAdd": func(tx keyvaluestore.AtomicWriteOperation) { tx.SAdd("foo", "a") },
+				"SRem": func(tx keyvaluestore.AtomicWriteOperation) { tx.SRem("foo", "a") },
+				"ZAdd": func(tx keyvaluestore.AtomicWriteOperation) { tx.ZAdd("foo", "a", 1) },
+				"ZRem": func(tx keyvaluestore.AtomicWriteOperation) { tx.ZRem("foo", "a") },
+			} {
+				t.Run(name, func(t *testing.T) {
+					tx := b.AtomicWrite()
+					tx.Set("before", "x")
+					write(tx)
+					tx.Set("after", "x")
+					_, err := tx.Exec()
+					assert.Equal(t, keyvaluestore.ErrWrongType, err)
+
+					for _, key := range []string{"before", "after"} {
+						v, err := b.Get(key)
+						assert.NoError(t, err)
+						assert.Nil(t, v)
+					}
+				})
+			}
+
+			v, err := b.Get("foo")
 			assert.NoError(t, err)
-			assert.Equal(t, keyvaluestore.ScoredMembers{
-				{Score: 1, Value: "1"},
-				{Score: 0.5, Value: "0.5b"},
-				{Score: 0.5, Value: "0.5"},
-				{Score: 0, Value: "0"},
-				{Score: -0.5, Value: "-0.5"},
-			}, members)
+			if assert.NotNil(t, v) {
+				assert.Equal(t, "bar", *v)
+			}
 		})
 
-		t.Run("-Inf", func(t *testing.T) {
-			members, err := b.ZRevRangeByScoreWithScores("foo", math.Inf(-1), 1, 0)
+		t.Run("TooManyOperations", func(t *testing.T) {
+			b := newBackend()
+
+			max := b.Capabilities().MaxAtomicWriteOperations
+			if max == 0 {
+				t.Skip("backend has no atomic write limit")
+			}
+
+			tx := b.AtomicWrite()
+			for i := 0; i <= max; i++ {
+				tx.SetNX(fmt.Sprintf("foo%d", i), "bar")
+			}
+			_, err := tx.Exec()
+			assert.Equal(t, keyvaluestore.ErrTooManyOperations, err)
+
+			v, err := b.Get("foo0")
 			assert.NoError(t, err)
-			assert.Equal(t, keyvaluestore.ScoredMembers{
-				{Score: 1, Value: "1"},
-				{Score: 0.5, Value: "0.5b"},
-				{Score: 0.5, Value: "0.5"},
-				{Score: 0, Value: "0"},
-				{Score: -0.5, Value: "-0.5"},
-				{Score: -1, Value: "-1"},
-				{Score: -2, Value: "-2"},
-			}, members)
+			assert.Nil(t, v)
 		})
 
-		t.Run("+Inf", func(t *testing.T) {
-			members, err := b.ZRevRangeByScoreWithScores("foo", -0.5, math.Inf(1), 0)
+		t.Run("MaxOperations", func(t *testing.T) {
+			b := newBackend()
+
+			// Backends without a limit should at least handle more than the old portable limit of 10.
+			n := b.Capabilities().MaxAtomicWriteOperations
+			if n == 0 {
+				n = 20
+			}
+
+			tx := b.AtomicWrite()
+			for i := 0; i < n; i++ {
+				tx.Set(fmt.Sprintf("max%d", i), i)
+			}
+			ok, err := tx.Exec()
 			assert.NoError(t, err)
-			assert.Equal(t, keyvaluestore.ScoredMembers{
-				{Score: 2, Value: "2"},
-				{Score: 1, Value: "1"},
-				{Score: 0.5, Value: "0.5b"},
-				{Score: 0.5, Value: "0.5"},
-				{Score: 0, Value: "0"},
-				{Score: -0.5, Value: "-0.5"},
-			}, members)
+			assert.True(t, ok)
+
+			v, err := b.Get(fmt.Sprintf("max%d", n-1))
+			assert.NoError(t, err)
+			if assert.NotNil(t, v) {
+				assert.Equal(t, fmt.Sprint(n-1), *v)
+			}
 		})
 	})
 }
@@ -0,0 +1,96 @@
+package keyvaluestoretest
+
+import (
+	"sync"
+	"time"
+)
+
+// FakeClock is a keyvaluestore.Clock that only moves when advanced.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+
+type fakeClockWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeClockWaiter{
+		deadline: c.now.Add(d),
+		ch:       ch,
+	})
+	return ch
+}
+
+// Moves the clock forward, firing any channels returned by After whose durations have elapsed.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+
+	waiters := c.waiters[:0]
+	for _, w := range c.waiters {
+		if c.now.Before(w.deadline) {
+			waiters = append(waiters, w)
+		} else {
+			w.ch <- c.now
+		}
+	}
+	c.waiters = waiters
+}
+
+// RecordingClock is a FakeClock that advances itself whenever something waits on it, so that code
+// that sleeps can be tested without blocking. It records how long each wait was for.
+type RecordingClock struct {
+	*FakeClock
+
+	mutex sync.Mutex
+	waits []time.Duration
+}
+
+func NewRecordingClock(now time.Time) *RecordingClock {
+	return &RecordingClock{
+		FakeClock: NewFakeClock(now),
+	}
+}
+
+func (c *RecordingClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	c.waits = append(c.waits, d)
+	c.mutex.Unlock()
+
+	ch := c.FakeClock.After(d)
+	c.Advance(d)
+	return ch
+}
+
+// Returns the durations passed to After, in the order they were waited for.
+func (c *RecordingClock) Waits() []time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
@@ -0,0 +1,140 @@
+package keyvaluestoretest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestorelock"
+)
+
+func TestBackendLock(t *testing.T, newBackend func() keyvaluestore.Backend) {
+	t.Run("Exclusive", func(t *testing.T) {
+		locker := keyvaluestorelock.NewLocker(newBackend(), nil)
+
+		a, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+
+		_, err = locker.TryAcquire("lock")
+		assert.Equal(t, keyvaluestorelock.ErrNotAcquired, err)
+
+		require.NoError(t, a.Refresh())
+		require.NoError(t, a.Release())
+		assert.Equal(t, keyvaluestorelock.ErrNotHeld, a.Release())
+
+		b, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+		assert.NotEqual(t, a.Owner, b.Owner)
+		assert.True(t, b.FencingToken > a.FencingToken)
+	})
+
+	t.Run("Expiration", func(t *testing.T) {
+		backend := newBackend()
+		clock := NewFakeClock(time.Unix(1500000000, 0))
+		locker := keyvaluestorelock.NewLocker(backend, &keyvaluestorelock.Options{
+			LeaseDuration: time.Minute,
+			Clock:         clock,
+		})
+
+		a, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+		assert.Equal(t, clock.Now().Add(time.Minute), a.Expiration)
+
+		clock.Advance(30 * time.Second)
+		require.NoError(t, a.Refresh())
+		assert.Equal(t, clock.Now().Add(time.Minute), a.Expiration)
+
+		clock.Advance(59 * time.Second)
+		_, err = locker.TryAcquire("lock")
+		assert.Equal(t, keyvaluestorelock.ErrNotAcquired, err)
+
+		clock.Advance(time.Second)
+		b, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+		assert.True(t, b.FencingToken > a.FencingToken)
+
+		assert.Equal(t, keyvaluestorelock.ErrNotHeld, a.Refresh())
+		assert.Equal(t, keyvaluestorelock.ErrNotHeld, a.Release())
+		assert.NoError(t, b.Release())
+	})
+
+	t.Run("Owner", func(t *testing.T) {
+		backend := newBackend()
+		locker := keyvaluestorelock.NewLocker(backend, &keyvaluestorelock.Options{
+			Owner: "owner",
+		})
+
+		a, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+		assert.Equal(t, "owner", a.Owner)
+
+		// The same owner can reclaim the lock, keeping its fencing token.
+		b, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+		assert.Equal(t, a.FencingToken, b.FencingToken)
+
+		_, err = keyvaluestorelock.NewLocker(backend, nil).TryAcquire("lock")
+		assert.Equal(t, keyvaluestorelock.ErrNotAcquired, err)
+	})
+
+	t.Run("Acquire", func(t *testing.T) {
+		locker := keyvaluestorelock.NewLocker(newBackend(), &keyvaluestorelock.Options{
+			RetryInterval: time.Millisecond,
+		})
+
+		a, err := locker.TryAcquire("lock")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err = locker.Acquire(ctx, "lock")
+		assert.Equal(t, context.DeadlineExceeded, err)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			assert.NoError(t, a.Release())
+		}()
+		b, err := locker.Acquire(context.Background(), "lock")
+		require.NoError(t, err)
+		assert.NoError(t, b.Release())
+	})
+
+	t.Run("Concurrency", func(t *testing.T) {
+		locker := keyvaluestorelock.NewLocker(newBackend(), &keyvaluestorelock.Options{
+			RetryInterval: time.Millisecond,
+		})
+
+		var holders int32
+		var fencingTokens []int64
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 5; j++ {
+					lock, err := locker.Acquire(context.Background(), "lock")
+					if !assert.NoError(t, err) {
+						return
+					}
+					assert.EqualValues(t, 1, atomic.AddInt32(&holders, 1))
+					fencingTokens = append(fencingTokens, lock.FencingToken)
+					time.Sleep(time.Millisecond)
+					atomic.AddInt32(&holders, -1)
+					assert.NoError(t, lock.Release())
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, fencingTokens, 40)
+		for i := 1; i < len(fencingTokens); i++ {
+			assert.True(t, fencingTokens[i] > fencingTokens[i-1])
+		}
+	})
+}
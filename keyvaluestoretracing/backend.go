@@ -0,0 +1,188 @@
+package keyvaluestoretracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/dynamodbstore"
+)
+
+// Backend opens a span for each Backend method call and for each execution of a batch or atomic
+// operation. Spans are children of the span in the context given to WithContext, if any.
+type Backend struct {
+	*keyvaluestore.InstrumentedBackend
+
+	backend     keyvaluestore.Backend
+	interceptor *interceptor
+	ctx         context.Context
+}
+
+var _ keyvaluestore.Backend = &Backend{}
+
+// Returns a backend that traces the operations of b. If b is a *dynamodbstore.Backend, its table
+// name is added to spans. Otherwise, it can be given via WithTableName.
+func NewBackend(b keyvaluestore.Backend, tracer Tracer) *Backend {
+	i := &interceptor{
+		tracer: tracer,
+	}
+	if dynamodb, ok := b.(*dynamodbstore.Backend); ok {
+		i.tableName = dynamodb.TableName
+	}
+	return newBackend(b, i, nil)
+}
+
+func newBackend(b keyvaluestore.Backend, i *interceptor, ctx context.Context) *Backend {
+	instrumented := keyvaluestore.NewInstrumentedBackend(b, i)
+	if ctx != nil {
+		instrumented = instrumented.WithContext(ctx).(*keyvaluestore.InstrumentedBackend)
+	}
+	return &Backend{
+		InstrumentedBackend: instrumented,
+		backend:             b,
+		interceptor:         i,
+		ctx:                 ctx,
+	}
+}
+
+// Returns a backend that adds the given table name to its spans.
+func (b *Backend) WithTableName(tableName string) *Backend {
+	i := *b.interceptor
+	i.tableName = tableName
+	return newBackend(b.backend, &i, b.ctx)
+}
+
+func (b *Backend) WithContext(ctx context.Context) keyvaluestore.Backend {
+	return newBackend(b.backend, b.interceptor, ctx)
+}
+
+func (b *Backend) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
+// Returns an instrumented backend whose DynamoDB requests are attributed to a capacity profiler
+// that's shared by everything created from it. This is used for batches and atomic operations,
+// which are performed with the context they were created with.
+func (b *Backend) withCapacityProfiler() *keyvaluestore.InstrumentedBackend {
+	ctx := withCapacityProfiler(b.context(), &capacityProfiler{})
+	return keyvaluestore.NewInstrumentedBackend(b.backend.WithContext(ctx), b.interceptor).WithContext(ctx).(*keyvaluestore.InstrumentedBackend)
+}
+
+func (b *Backend) Batch() keyvaluestore.BatchOperation {
+	return b.withCapacityProfiler().Batch()
+}
+
+func (b *Backend) AtomicWrite() keyvaluestore.AtomicWriteOperation {
+	return b.withCapacityProfiler().AtomicWrite()
+}
+
+func (b *Backend) AtomicRead() keyvaluestore.AtomicReadOperation {
+	return b.withCapacityProfiler().AtomicRead()
+}
+
+// Accumulates the DynamoDB requests made for a single span.
+type capacityProfiler struct {
+	mutex         sync.Mutex
+	requests      int
+	readCapacity  float64
+	writeCapacity float64
+}
+
+var _ dynamodbstore.Profiler = (*capacityProfiler)(nil)
+
+func (p *capacityProfiler) ConsumeDynamoDBReadCapacity(capacity float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.readCapacity += capacity
+}
+
+func (p *capacityProfiler) ConsumeDynamoDBWriteCapacity(capacity float64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.writeCapacity += capacity
+}
+
+func (p *capacityProfiler) AddDynamoDBRequestProfile(operationName string, duration time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.requests++
+}
+
+func (p *capacityProfiler) reset() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.requests = 0
+	p.readCapacity = 0
+	p.writeCapacity = 0
+}
+
+type capacityProfilerContextKey struct{}
+
+func withCapacityProfiler(ctx context.Context, p *capacityProfiler) context.Context {
+	ctx = context.WithValue(ctx, capacityProfilerContextKey{}, p)
+	return dynamodbstore.ContextWithProfiler(ctx, p)
+}
+
+type activeSpanContextKey struct{}
+
+type activeSpan struct {
+	span     Span
+	capacity *capacityProfiler
+}
+
+type interceptor struct {
+	tracer    Tracer
+	tableName string
+}
+
+func (i *interceptor) Before(op *keyvaluestore.Operation) {
+	ctx := op.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	capacity, ok := ctx.Value(capacityProfilerContextKey{}).(*capacityProfiler)
+	if ok {
+		capacity.reset()
+	} else {
+		capacity = &capacityProfiler{}
+		ctx = withCapacityProfiler(ctx, capacity)
+	}
+
+	ctx, span := i.tracer.StartSpan(ctx, "keyvaluestore."+op.Name)
+	span.SetAttribute(AttributeKeyCount, len(op.Keys))
+	if i.tableName != "" {
+		span.SetAttribute(AttributeTableName, i.tableName)
+	}
+
+	op.Context = context.WithValue(ctx, activeSpanContextKey{}, &activeSpan{
+		span:     span,
+		capacity: capacity,
+	})
+}
+
+func (i *interceptor) After(op *keyvaluestore.Operation, duration time.Duration, err error) {
+	active, ok := op.Context.Value(activeSpanContextKey{}).(*activeSpan)
+	if !ok {
+		return
+	}
+
+	if op.Name == "AtomicWrite" {
+		active.span.SetAttribute(AttributeConditionalFailed, op.ConditionalFailed)
+	}
+
+	active.capacity.mutex.Lock()
+	requests, readCapacity, writeCapacity := active.capacity.requests, active.capacity.readCapacity, active.capacity.writeCapacity
+	active.capacity.mutex.Unlock()
+	if requests > 0 {
+		active.span.SetAttribute(AttributeDynamoDBRequests, requests)
+		active.span.SetAttribute(AttributeDynamoDBConsumedReadCapacity, readCapacity)
+		active.span.SetAttribute(AttributeDynamoDBConsumedWriteCapacity, writeCapacity)
+	}
+
+	active.span.End(err)
+}
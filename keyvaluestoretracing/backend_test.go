@@ -0,0 +1,207 @@
+package keyvaluestoretracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/dynamodbstore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretracing"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+func TestBackend(t *testing.T) {
+	keyvaluestoretest.TestBackend(t, func() keyvaluestore.Backend {
+		return keyvaluestoretracing.NewBackend(memorystore.NewBackend(), &keyvaluestoretracing.Recorder{})
+	})
+}
+
+func TestSpans(t *testing.T) {
+	recorder := &keyvaluestoretracing.Recorder{}
+	ctx, span := recorder.StartSpan(context.Background(), "request")
+	parent := span.(*keyvaluestoretracing.RecordedSpan)
+	b := keyvaluestoretracing.NewBackend(memorystore.NewBackend(), recorder).WithTableName("table").WithContext(ctx)
+
+	require.NoError(t, b.Set("foo", "bar"))
+	_, err := b.AddInt("foo", 1)
+	assert.Equal(t, keyvaluestore.ErrWrongType, err)
+
+	batch := b.Batch()
+	batch.Get("a")
+	batch.Get("b")
+	require.NoError(t, batch.Exec())
+
+	tx := b.AtomicWrite()
+	tx.SetNX("foo", "baz")
+	ok, err := tx.Exec()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	spans := recorder.Spans()
+	require.Len(t, spans, 5)
+	assert.Equal(t, parent, spans[0])
+
+	for i, name := range []string{"keyvaluestore.Set", "keyvaluestore.AddInt", "keyvaluestore.Batch", "keyvaluestore.AtomicWrite"} {
+		span := spans[i+1]
+		assert.Equal(t, name, span.Name)
+		assert.Equal(t, parent, span.Parent)
+		assert.True(t, span.Ended())
+		assert.Equal(t, "table", span.Attributes()[keyvaluestoretracing.AttributeTableName])
+		assert.NotContains(t, span.Attributes(), keyvaluestoretracing.AttributeDynamoDBRequests)
+	}
+
+	assert.Equal(t, 1, spans[1].Attributes()[keyvaluestoretracing.AttributeKeyCount])
+	assert.NoError(t, spans[1].Err())
+	assert.Equal(t, keyvaluestore.ErrWrongType, spans[2].Err())
+	assert.Equal(t, 2, spans[3].Attributes()[keyvaluestoretracing.AttributeKeyCount])
+	assert.Equal(t, true, spans[4].Attributes()[keyvaluestoretracing.AttributeConditionalFailed])
+	assert.False(t, parent.Ended())
+}
+
+// Responds to DynamoDB requests with fixed amounts of consumed capacity.
+type capacityClient struct {
+	dynamodbstore.BackendClient
+}
+
+func (c *capacityClient) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{
+		ConsumedCapacity: &dynamodb.ConsumedCapacity{
+			CapacityUnits: aws.Float64(0.5),
+		},
+	}, nil
+}
+
+func (c *capacityClient) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	return &dynamodb.PutItemOutput{
+		ConsumedCapacity: &dynamodb.ConsumedCapacity{
+			CapacityUnits: aws.Float64(1),
+		},
+	}, nil
+}
+
+func (c *capacityClient) BatchGetItemWithContext(ctx aws.Context, input *dynamodb.BatchGetItemInput, opts ...request.Option) (*dynamodb.BatchGetItemOutput, error) {
+	return &dynamodb.BatchGetItemOutput{
+		ConsumedCapacity: []*dynamodb.ConsumedCapacity{
+			{
+				CapacityUnits: aws.Float64(2),
+			},
+		},
+	}, nil
+}
+
+func (c *capacityClient) TransactWriteItemsWithContext(ctx aws.Context, input *dynamodb.TransactWriteItemsInput, opts ...request.Option) (*dynamodb.TransactWriteItemsOutput, dynamodbstore.TransactWriteErr) {
+	return &dynamodb.TransactWriteItemsOutput{
+		ConsumedCapacity: []*dynamodb.ConsumedCapacity{
+			{
+				CapacityUnits: aws.Float64(4),
+			},
+		},
+	}, nil
+}
+
+func TestDynamoDBCapacity(t *testing.T) {
+	recorder := &keyvaluestoretracing.Recorder{}
+	b := keyvaluestoretracing.NewBackend(&dynamodbstore.Backend{
+		Client:    &capacityClient{},
+		TableName: "table",
+	}, recorder)
+
+	_, err := b.Get("foo")
+	require.NoError(t, err)
+	require.NoError(t, b.Set("foo", "bar"))
+
+	batch := b.Batch()
+	batch.Get("foo")
+	batch.Get("bar")
+	require.NoError(t, batch.Exec())
+
+	tx := b.AtomicWrite()
+	tx.Set("foo", "bar")
+	ok, err := tx.Exec()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	spans := recorder.Spans()
+	require.Len(t, spans, 4)
+
+	for i, expected := range []struct {
+		name          string
+		readCapacity  float64
+		writeCapacity float64
+	}{
+		{"keyvaluestore.Get", 0.5, 0},
+		{"keyvaluestore.Set", 0, 1},
+		{"keyvaluestore.Batch", 2, 0},
+		{"keyvaluestore.AtomicWrite", 0, 4},
+	} {
+		attributes := spans[i].Attributes()
+		assert.Equal(t, expected.name, spans[i].Name)
+		assert.Equal(t, "table", attributes[keyvaluestoretracing.AttributeTableName])
+		assert.Equal(t, 1, attributes[keyvaluestoretracing.AttributeDynamoDBRequests], expected.name)
+		assert.Equal(t, expected.readCapacity, attributes[keyvaluestoretracing.AttributeDynamoDBConsumedReadCapacity], expected.name)
+		assert.Equal(t, expected.writeCapacity, attributes[keyvaluestoretracing.AttributeDynamoDBConsumedWriteCapacity], expected.name)
+	}
+}
+
+type fakeOpenTelemetrySpan struct {
+	attributes  map[string]interface{}
+	errors      []error
+	errorStatus string
+	ended       bool
+}
+
+func (s *fakeOpenTelemetrySpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *fakeOpenTelemetrySpan) RecordError(err error) {
+	s.errors = append(s.errors, err)
+}
+
+func (s *fakeOpenTelemetrySpan) SetErrorStatus(description string) {
+	s.errorStatus = description
+}
+
+func (s *fakeOpenTelemetrySpan) End() {
+	s.ended = true
+}
+
+func TestOpenTelemetryTracer(t *testing.T) {
+	var spans []*fakeOpenTelemetrySpan
+	tracer := &keyvaluestoretracing.OpenTelemetryTracer{
+		Start: func(ctx context.Context, name string) (context.Context, keyvaluestoretracing.OpenTelemetrySpan) {
+			span := &fakeOpenTelemetrySpan{
+				attributes: map[string]interface{}{},
+			}
+			spans = append(spans, span)
+			return ctx, span
+		},
+		DBSystem: "dynamodb",
+	}
+
+	b := keyvaluestoretracing.NewBackend(memorystore.NewBackend(), tracer).WithTableName("table")
+	require.NoError(t, b.Set("foo", "bar"))
+	_, err := b.AddInt("foo", 1)
+	require.Error(t, err)
+
+	require.Len(t, spans, 2)
+	assert.Equal(t, map[string]interface{}{
+		"db.system":                            "dynamodb",
+		"db.operation":                         "Set",
+		"aws.dynamodb.table_names":             []string{"table"},
+		keyvaluestoretracing.AttributeKeyCount: 1,
+	}, spans[0].attributes)
+	assert.True(t, spans[0].ended)
+	assert.Empty(t, spans[0].errors)
+
+	assert.Equal(t, []error{keyvaluestore.ErrWrongType}, spans[1].errors)
+	assert.Equal(t, keyvaluestore.ErrWrongType.Error(), spans[1].errorStatus)
+	assert.True(t, spans[1].ended)
+}
@@ -0,0 +1,70 @@
+package keyvaluestoretracing
+
+import (
+	"context"
+	"strings"
+)
+
+// OpenTelemetrySpan is the part of OpenTelemetry's trace.Span that OpenTelemetryTracer uses.
+// Applications implement it with a small wrapper that converts attribute values to OpenTelemetry's
+// attribute types. Values are always bools, ints, float64s, strings, or []strings.
+type OpenTelemetrySpan interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+
+	// Sets the span's status code to Error.
+	SetErrorStatus(description string)
+
+	End()
+}
+
+// OpenTelemetryTracer adapts OpenTelemetry's tracing API. So that this package doesn't depend on
+// OpenTelemetry, spans are started by a function that the application provides, typically like so:
+//
+//	Start: func(ctx context.Context, name string) (context.Context, OpenTelemetrySpan) {
+//		ctx, span := tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+//
+// Attributes are named according to OpenTelemetry's semantic conventions for databases where
+// possible.
+type OpenTelemetryTracer struct {
+	Start func(ctx context.Context, name string) (context.Context, OpenTelemetrySpan)
+
+	// The value of the db.system attribute, such as "dynamodb" or "redis".
+	DBSystem string
+}
+
+var _ Tracer = (*OpenTelemetryTracer)(nil)
+
+func (t *OpenTelemetryTracer) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	ctx, span := t.Start(ctx, name)
+	if t.DBSystem != "" {
+		span.SetAttribute("db.system", t.DBSystem)
+	}
+	span.SetAttribute("db.operation", strings.TrimPrefix(name, "keyvaluestore."))
+	return ctx, &openTelemetrySpan{span}
+}
+
+type openTelemetrySpan struct {
+	span OpenTelemetrySpan
+}
+
+func (s *openTelemetrySpan) SetAttribute(key string, value interface{}) {
+	switch key {
+	case AttributeTableName:
+		if tableName, ok := value.(string); ok {
+			s.span.SetAttribute("aws.dynamodb.table_names", []string{tableName})
+			return
+		}
+	}
+	s.span.SetAttribute(key, value)
+}
+
+func (s *openTelemetrySpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetErrorStatus(err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,98 @@
+package keyvaluestoretracing
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder is a Tracer that keeps its spans in memory so that tests can inspect them.
+type Recorder struct {
+	mutex sync.Mutex
+	spans []*RecordedSpan
+}
+
+var _ Tracer = (*Recorder)(nil)
+
+type RecordedSpan struct {
+	Name string
+
+	// The span that was in the context when this span was started, if it was started by the same
+	// recorder.
+	Parent *RecordedSpan
+
+	recorder   *Recorder
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+type recordedSpanContextKey struct{}
+
+func (r *Recorder) StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	span := &RecordedSpan{
+		Name:       name,
+		recorder:   r,
+		attributes: map[string]interface{}{},
+	}
+	if parent, ok := ctx.Value(recordedSpanContextKey{}).(*RecordedSpan); ok && parent.recorder == r {
+		span.Parent = parent
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.spans = append(r.spans, span)
+
+	return context.WithValue(ctx, recordedSpanContextKey{}, span), span
+}
+
+// Returns the spans that have been started, in the order they were started.
+func (r *Recorder) Spans() []*RecordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]*RecordedSpan(nil), r.spans...)
+}
+
+// Forgets all of the spans that have been recorded.
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.spans = nil
+}
+
+func (s *RecordedSpan) SetAttribute(key string, value interface{}) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	s.attributes[key] = value
+}
+
+func (s *RecordedSpan) End(err error) {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	s.err = err
+	s.ended = true
+}
+
+// Returns a copy of the span's attributes.
+func (s *RecordedSpan) Attributes() map[string]interface{} {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	ret := make(map[string]interface{}, len(s.attributes))
+	for k, v := range s.attributes {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Returns the error that the span ended with.
+func (s *RecordedSpan) Err() error {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	return s.err
+}
+
+// Returns whether the span has ended.
+func (s *RecordedSpan) Ended() bool {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	return s.ended
+}
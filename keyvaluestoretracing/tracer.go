@@ -0,0 +1,36 @@
+// Package keyvaluestoretracing traces keyvaluestore operations.
+package keyvaluestoretracing
+
+import "context"
+
+// Tracer starts spans. Adapters for tracing libraries implement it.
+type Tracer interface {
+	// Starts a span. If ctx contains a span, the new span should be its child. Returns a context
+	// containing the new span.
+	StartSpan(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+
+	// Ends the span. If err is not nil, the span should be marked as failed.
+	End(err error)
+}
+
+// The attributes that are set on spans.
+const (
+	// The number of keys read or written by the operation.
+	AttributeKeyCount = "keyvaluestore.key_count"
+
+	// Whether an atomic write failed due to a condition.
+	AttributeConditionalFailed = "keyvaluestore.conditional_failed"
+
+	// The DynamoDB table, if known.
+	AttributeTableName = "dynamodb.table_name"
+
+	// The number of DynamoDB requests made and the capacity they consumed. These are only set for
+	// DynamoDB backends.
+	AttributeDynamoDBRequests              = "dynamodb.requests"
+	AttributeDynamoDBConsumedReadCapacity  = "dynamodb.consumed_read_capacity"
+	AttributeDynamoDBConsumedWriteCapacity = "dynamodb.consumed_write_capacity"
+)
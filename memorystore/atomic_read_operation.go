@@ -0,0 +1,82 @@
+package memorystore
+
+import (
+	"github.com/theaaf/keyvaluestore"
+)
+
+type AtomicReadOperation struct {
+	Backend *Backend
+
+	operations []func() error
+}
+
+type atomicReadGetResult struct {
+	value *string
+}
+
+func (r *atomicReadGetResult) Result() (*string, error) {
+	return r.value, nil
+}
+
+type atomicReadSMembersResult struct {
+	members []string
+	err     error
+}
+
+func (r *atomicReadSMembersResult) Result() ([]string, error) {
+	return r.members, r.err
+}
+
+type atomicReadScoreResult struct {
+	score *float64
+	err   error
+}
+
+func (r *atomicReadScoreResult) Result() (*float64, error) {
+	return r.score, r.err
+}
+
+func (op *AtomicReadOperation) Get(key string) keyvaluestore.GetResult {
+	result := &atomicReadGetResult{}
+	op.operations = append(op.operations, func() error {
+		result.value = op.Backend.get(key)
+		return nil
+	})
+	return result
+}
+
+func (op *AtomicReadOperation) SMembers(key string) keyvaluestore.SMembersResult {
+	result := &atomicReadSMembersResult{}
+	op.operations = append(op.operations, func() error {
+		result.members, result.err = op.Backend.smembers(key)
+		return result.err
+	})
+	return result
+}
+
+func (op *AtomicReadOperation) ZScore(key string, member interface{}) keyvaluestore.ScoreResult {
+	result := &atomicReadScoreResult{}
+	op.operations = append(op.operations, func() error {
+		result.score, result.err = op.Backend.zscore(key, member)
+		return result.err
+	})
+	return result
+}
+
+func (op *AtomicReadOperation) Exec() error {
+	if err := op.Backend.ctx.Err(); err != nil {
+		return err
+	}
+
+	op.Backend.mutex.Lock()
+	defer op.Backend.mutex.Unlock()
+
+	// Every read is performed so that each result is valid, but the first error is returned.
+	var firstError error
+	for _, read := range op.operations {
+		if err := read(); err != nil && firstError == nil {
+			firstError = err
+		}
+	}
+	return firstError
+}
@@ -2,6 +2,8 @@ package memorystore
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/theaaf/keyvaluestore"
 )
@@ -10,10 +12,12 @@ type AtomicWriteOperation struct {
 	Backend *Backend
 
 	operations []*atomicWriteOperation
+	err        error
 }
 
 type atomicWriteOperation struct {
 	condition func() bool
+	validate  func() error
 	write     func()
 
 	conditionPassed bool
@@ -39,6 +43,17 @@ func (op *AtomicWriteOperation) SetNX(key string, value interface{}) keyvaluesto
 	})
 }
 
+func (op *AtomicWriteOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.AtomicWriteResult {
+	if ttl <= 0 {
+		op.err = fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	return op.write(&atomicWriteOperation{
+		write: func() {
+			op.Backend.setEX(key, value, ttl)
+		},
+	})
+}
+
 func (op *AtomicWriteOperation) CAS(key string, oldValue, newValue string) keyvaluestore.AtomicWriteResult {
 	return op.write(&atomicWriteOperation{
 		condition: func() bool {
@@ -59,9 +74,128 @@ func (op *AtomicWriteOperation) Delete(key string) keyvaluestore.AtomicWriteResu
 	})
 }
 
+func (op *AtomicWriteOperation) Set(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		write: func() {
+			op.Backend.set(key, value)
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) SAdd(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		validate: func() error {
+			_, err := op.Backend.loadSet(key)
+			return err
+		},
+		write: func() {
+			op.Backend.sadd(key, member, members...)
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) SRem(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		validate: func() error {
+			_, err := op.Backend.loadSet(key)
+			return err
+		},
+		write: func() {
+			op.Backend.srem(key, member, members...)
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) ZAdd(key string, member interface{}, score float64) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		validate: func() error {
+			_, err := op.Backend.loadSortedSet(key)
+			return err
+		},
+		write: func() {
+			op.Backend.zadd(key, member, func(previousScore *float64) (float64, error) {
+				return score, nil
+			})
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) ZRem(key string, member interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		validate: func() error {
+			_, err := op.Backend.loadSortedSet(key)
+			return err
+		},
+		write: func() {
+			op.Backend.zrem(key, member)
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) SetXX(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		condition: func() bool {
+			_, ok := op.Backend.load(key)
+			return ok
+		},
+		write: func() {
+			op.Backend.set(key, value)
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) AddInt(key string, n int64) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		validate: func() error {
+			if v, ok := op.Backend.load(key); ok {
+				if s := keyvaluestore.ToString(v); s == nil {
+					return keyvaluestore.ErrWrongType
+				} else if _, err := strconv.ParseInt(*s, 10, 64); err != nil {
+					return keyvaluestore.ErrWrongType
+				}
+			}
+			return nil
+		},
+		write: func() {
+			op.Backend.addInt(key, n)
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) Check(key string, expected string) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		condition: func() bool {
+			v := op.Backend.get(key)
+			return v != nil && *v == expected
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) CheckExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		condition: func() bool {
+			_, ok := op.Backend.load(key)
+			return ok
+		},
+	})
+}
+
+func (op *AtomicWriteOperation) CheckNotExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		condition: func() bool {
+			_, ok := op.Backend.load(key)
+			return !ok
+		},
+	})
+}
+
 func (op *AtomicWriteOperation) Exec() (bool, error) {
-	if len(op.operations) > keyvaluestore.MaxAtomicWriteOperations {
-		return false, fmt.Errorf("max operation count exceeded")
+	if op.err != nil {
+		return false, op.err
+	}
+
+	if err := op.Backend.ctx.Err(); err != nil {
+		return false, err
 	}
 
 	op.Backend.mutex.Lock()
@@ -86,7 +220,17 @@ func (op *AtomicWriteOperation) Exec() (bool, error) {
 	}
 
 	for _, wOp := range op.operations {
-		wOp.write()
+		if wOp.validate != nil {
+			if err := wOp.validate(); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	for _, wOp := range op.operations {
+		if wOp.write != nil {
+			wOp.write()
+		}
 	}
 
 	return true, nil
@@ -1,10 +1,17 @@
 package memorystore
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ccbrown/go-immutable"
 
@@ -12,32 +19,111 @@ import (
 )
 
 type Backend struct {
-	m     map[string]interface{}
-	mutex sync.Mutex
+	*store
+	ctx context.Context
+}
+
+type store struct {
+	m           map[string]interface{}
+	expirations map[string]time.Time
+	clock       keyvaluestore.Clock
+	mutex       sync.Mutex
 }
 
 func NewBackend() *Backend {
+	return NewBackendWithClock(keyvaluestore.SystemClock)
+}
+
+// Returns a new backend that expires keys according to the given clock.
+func NewBackendWithClock(clock keyvaluestore.Clock) *Backend {
 	return &Backend{
-		m: make(map[string]interface{}),
+		store: &store{
+			m:           make(map[string]interface{}),
+			expirations: make(map[string]time.Time),
+			clock:       clock,
+		},
+		ctx: context.Background(),
 	}
 }
 
+// Returns a backend that shares the receiver's data, but fails its operations once ctx is done.
+func (b *Backend) WithContext(ctx context.Context) keyvaluestore.Backend {
+	ret := *b
+	ret.ctx = ctx
+	return &ret
+}
+
 // Erases everything in the backend and makes it like-new.
 func (b *Backend) Reinitialize() {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	b.m = make(map[string]interface{})
+	b.expirations = make(map[string]time.Time)
+}
+
+// Loads the value for a key, removing it first if it has expired.
+func (b *Backend) load(key string) (interface{}, bool) {
+	if expiration, ok := b.expirations[key]; ok && !b.clock.Now().Before(expiration) {
+		delete(b.m, key)
+		delete(b.expirations, key)
+	}
+	v, ok := b.m[key]
+	return v, ok
+}
+
+// Loads a set, returning ErrWrongType if the key holds another kind of value.
+func (b *Backend) loadSet(key string) (map[string]struct{}, error) {
+	v, ok := b.load(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := v.(map[string]struct{})
+	if !ok {
+		return nil, keyvaluestore.ErrWrongType
+	}
+	return s, nil
+}
+
+// Loads a sorted set, returning ErrWrongType if the key holds another kind of value.
+func (b *Backend) loadSortedSet(key string) (*sortedSet, error) {
+	v, ok := b.load(key)
+	if !ok {
+		return nil, nil
+	}
+	s, ok := v.(*sortedSet)
+	if !ok {
+		return nil, keyvaluestore.ErrWrongType
+	}
+	return s, nil
+}
+
+// Loads a hash, returning ErrWrongType if the key holds another kind of value.
+func (b *Backend) loadHash(key string) (map[string]string, error) {
+	v, ok := b.load(key)
+	if !ok {
+		return nil, nil
+	}
+	h, ok := v.(map[string]string)
+	if !ok {
+		return nil, keyvaluestore.ErrWrongType
+	}
+	return h, nil
 }
 
 func (b *Backend) Delete(key string) (bool, error) {
+	if err := b.ctx.Err(); err != nil {
+		return false, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	return b.delete(key), nil
 }
 
 func (b *Backend) delete(key string) bool {
-	_, ok := b.m[key]
+	_, ok := b.load(key)
 	delete(b.m, key)
+	delete(b.expirations, key)
 	return ok
 }
 
@@ -53,7 +139,25 @@ func (b *Backend) AtomicWrite() keyvaluestore.AtomicWriteOperation {
 	}
 }
 
+func (b *Backend) AtomicRead() keyvaluestore.AtomicReadOperation {
+	return &AtomicReadOperation{
+		Backend: b,
+	}
+}
+
+func (b *Backend) Capabilities() keyvaluestore.Capabilities {
+	return keyvaluestore.Capabilities{
+		TTL:             true,
+		Scan:            true,
+		WrongTypeErrors: true,
+	}
+}
+
 func (b *Backend) CAS(key string, transform func(prev *string) (interface{}, error)) (bool, error) {
+	if err := b.ctx.Err(); err != nil {
+		return false, err
+	}
+
 	before, err := b.Get(key)
 	if err != nil {
 		return false, err
@@ -69,28 +173,36 @@ func (b *Backend) CAS(key string, transform func(prev *string) (interface{}, err
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	if v, ok := b.m[key]; (ok && (before == nil || v != *before)) || (!ok && before != nil) {
+	if v := b.get(key); (v != nil && (before == nil || *v != *before)) || (v == nil && before != nil) {
 		return false, nil
 	}
 
-	b.m[key] = newValue
+	b.set(key, newValue)
 	return true, nil
 }
 
 func (b *Backend) Get(key string) (*string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	return b.get(key), nil
 }
 
 func (b *Backend) get(key string) *string {
-	if v, ok := b.m[key]; ok {
+	if v, ok := b.load(key); ok {
 		return keyvaluestore.ToString(v)
 	}
 	return nil
 }
 
 func (b *Backend) Set(key string, value interface{}) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	b.set(key, value)
@@ -99,39 +211,112 @@ func (b *Backend) Set(key string, value interface{}) error {
 
 func (b *Backend) set(key string, value interface{}) {
 	b.m[key] = value
+	delete(b.expirations, key)
+}
+
+func (b *Backend) SetEX(key string, value interface{}, ttl time.Duration) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl: %v", ttl)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.setEX(key, value, ttl)
+	return nil
+}
+
+func (b *Backend) setEX(key string, value interface{}, ttl time.Duration) {
+	b.m[key] = value
+	b.expirations[key] = b.clock.Now().Add(ttl)
+}
+
+func (b *Backend) Expire(key string, ttl time.Duration) (bool, error) {
+	if err := b.ctx.Err(); err != nil {
+		return false, err
+	}
+
+	if ttl <= 0 {
+		return false, fmt.Errorf("invalid ttl: %v", ttl)
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.load(key); !ok {
+		return false, nil
+	}
+
+	b.expirations[key] = b.clock.Now().Add(ttl)
+	return true, nil
+}
+
+func (b *Backend) TTL(key string) (*time.Duration, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.load(key); !ok {
+		return nil, nil
+	}
+
+	expiration, ok := b.expirations[key]
+	if !ok {
+		return nil, nil
+	}
+
+	ttl := expiration.Sub(b.clock.Now())
+	return &ttl, nil
 }
 
 func (b *Backend) AddInt(key string, n int64) (int64, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	return b.addInt(key, n)
 }
 
 func (b *Backend) addInt(key string, n int64) (int64, error) {
-	if v, ok := b.m[key]; ok {
-		if s := keyvaluestore.ToString(v); s != nil {
-			i, err := strconv.ParseInt(*s, 10, 64)
-			if err != nil {
-				return 0, err
-			}
-			b.m[key] = strconv.FormatInt(i+n, 10)
-			return i + n, nil
+	if v, ok := b.load(key); ok {
+		s := keyvaluestore.ToString(v)
+		if s == nil {
+			return 0, keyvaluestore.ErrWrongType
+		}
+		i, err := strconv.ParseInt(*s, 10, 64)
+		if err != nil {
+			return 0, keyvaluestore.ErrWrongType
 		}
+		b.m[key] = strconv.FormatInt(i+n, 10)
+		return i + n, nil
 	}
 	b.m[key] = strconv.FormatInt(n, 10)
 	return n, nil
 }
 
 func (b *Backend) SAdd(key string, member interface{}, members ...interface{}) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
-	b.sadd(key, member, members...)
-	return nil
+	return b.sadd(key, member, members...)
 }
 
-func (b *Backend) sadd(key string, member interface{}, members ...interface{}) {
-	s, ok := b.m[key].(map[string]struct{})
-	if !ok {
+func (b *Backend) sadd(key string, member interface{}, members ...interface{}) error {
+	s, err := b.loadSet(key)
+	if err != nil {
+		return err
+	} else if s == nil {
 		s = make(map[string]struct{})
 	}
 	s[*keyvaluestore.ToString(member)] = struct{}{}
@@ -139,25 +324,30 @@ func (b *Backend) sadd(key string, member interface{}, members ...interface{}) {
 		s[*keyvaluestore.ToString(member)] = struct{}{}
 	}
 	b.m[key] = s
+	return nil
 }
 
 func (b *Backend) SRem(key string, member interface{}, members ...interface{}) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 	return b.srem(key, member, members...)
 }
 
 func (b *Backend) srem(key string, member interface{}, members ...interface{}) error {
-	s, ok := b.m[key].(map[string]struct{})
-	if !ok {
-		return nil
+	s, err := b.loadSet(key)
+	if err != nil || s == nil {
+		return err
 	}
 	delete(s, *keyvaluestore.ToString(member))
 	for _, member := range members {
 		delete(s, *keyvaluestore.ToString(member))
 	}
 	if len(s) == 0 {
-		delete(b.m, key)
+		b.delete(key)
 	} else {
 		b.m[key] = s
 	}
@@ -165,12 +355,19 @@ func (b *Backend) srem(key string, member interface{}, members ...interface{}) e
 }
 
 func (b *Backend) SMembers(key string) ([]string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
+	return b.smembers(key)
+}
 
-	s, ok := b.m[key].(map[string]struct{})
-	if !ok {
-		return nil, nil
+func (b *Backend) smembers(key string) ([]string, error) {
+	s, err := b.loadSet(key)
+	if err != nil {
+		return nil, err
 	}
 	var results []string
 	for k := range s {
@@ -179,27 +376,103 @@ func (b *Backend) SMembers(key string) ([]string, error) {
 	return results, nil
 }
 
+func (b *Backend) SIsMember(key string, member interface{}) (bool, error) {
+	if err := b.ctx.Err(); err != nil {
+		return false, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, err := b.loadSet(key)
+	if err != nil {
+		return false, err
+	}
+	_, ok := s[*keyvaluestore.ToString(member)]
+	return ok, nil
+}
+
+func (b *Backend) SCard(key string) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, err := b.loadSet(key)
+	return len(s), err
+}
+
+func (b *Backend) SRandMember(key string) (*string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.srandmember(key)
+}
+
+func (b *Backend) srandmember(key string) (*string, error) {
+	s, err := b.loadSet(key)
+	if err != nil || len(s) == 0 {
+		return nil, err
+	}
+	i := rand.Intn(len(s))
+	for member := range s {
+		if i == 0 {
+			return &member, nil
+		}
+		i--
+	}
+	return nil, nil
+}
+
+func (b *Backend) SPop(key string) (*string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	member, err := b.srandmember(key)
+	if member != nil {
+		err = b.srem(key, *member)
+	}
+	return member, err
+}
+
 func (b *Backend) SetNX(key string, value interface{}) (bool, error) {
+	if err := b.ctx.Err(); err != nil {
+		return false, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	if _, ok := b.m[key]; ok {
+	if _, ok := b.load(key); ok {
 		return false, nil
 	}
 
-	b.m[key] = value
+	b.set(key, value)
 	return true, nil
 }
 
 func (b *Backend) SetXX(key string, value interface{}) (bool, error) {
+	if err := b.ctx.Err(); err != nil {
+		return false, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	if _, ok := b.m[key]; !ok {
+	if _, ok := b.load(key); !ok {
 		return false, nil
 	}
 
-	b.m[key] = value
+	b.set(key, value)
 	return true, nil
 }
 
@@ -252,12 +525,11 @@ type sortedSet struct {
 }
 
 func (b *Backend) zadd(key string, member interface{}, f func(previousScore *float64) (float64, error)) (float64, error) {
-	b.mutex.Lock()
-	defer b.mutex.Unlock()
-
 	v := *keyvaluestore.ToString(member)
-	s, _ := b.m[key].(*sortedSet)
-	if s == nil {
+	s, err := b.loadSortedSet(key)
+	if err != nil {
+		return 0, err
+	} else if s == nil {
 		s = &sortedSet{
 			scoresByMember: make(map[string]float64),
 		}
@@ -284,6 +556,13 @@ func (b *Backend) zadd(key string, member interface{}, f func(previousScore *flo
 }
 
 func (b *Backend) ZAdd(key string, member interface{}, score float64) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
 	_, err := b.zadd(key, member, func(previousScore *float64) (float64, error) {
 		return score, nil
 	})
@@ -291,20 +570,34 @@ func (b *Backend) ZAdd(key string, member interface{}, score float64) error {
 }
 
 func (b *Backend) ZScore(key string, member interface{}) (*float64, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
+	return b.zscore(key, member)
+}
 
-	if s, _ := b.m[key].(*sortedSet); s != nil {
+func (b *Backend) zscore(key string, member interface{}) (*float64, error) {
+	s, err := b.loadSortedSet(key)
+	if s != nil {
 		v := *keyvaluestore.ToString(member)
 		if prev, ok := s.scoresByMember[v]; ok {
 			return &prev, nil
 		}
 	}
-
-	return nil, nil
+	return nil, err
 }
 
 func (b *Backend) ZIncrBy(key string, member string, n float64) (float64, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
 	return b.zadd(key, member, func(previousScore *float64) (float64, error) {
 		if previousScore != nil {
 			return *previousScore + n, nil
@@ -315,10 +608,17 @@ func (b *Backend) ZIncrBy(key string, member string, n float64) (float64, error)
 }
 
 func (b *Backend) ZRem(key string, member interface{}) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
+	return b.zrem(key, member)
+}
 
-	s, _ := b.m[key].(*sortedSet)
+func (b *Backend) zrem(key string, member interface{}) error {
+	s, err := b.loadSortedSet(key)
 	if s != nil {
 		v := *keyvaluestore.ToString(member)
 		if previous, ok := s.scoresByMember[v]; ok {
@@ -327,14 +627,18 @@ func (b *Backend) ZRem(key string, member interface{}) error {
 			b.m[key] = s
 		}
 	}
-	return nil
+	return err
 }
 
 func (b *Backend) ZRangeByScore(key string, min, max float64, limit int) ([]string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	if members, err := b.zRangeByScoreWithScores(key, min, max, limit); err != nil {
+	if members, err := b.zRangeByScoreWithScores(key, min, max, "", limit); err != nil {
 		return nil, err
 	} else {
 		return members.Values(), nil
@@ -342,16 +646,21 @@ func (b *Backend) ZRangeByScore(key string, min, max float64, limit int) ([]stri
 }
 
 func (b *Backend) ZRangeByScoreWithScores(key string, min, max float64, limit int) (keyvaluestore.ScoredMembers, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	return b.zRangeByScoreWithScores(key, min, max, limit)
+	return b.zRangeByScoreWithScores(key, min, max, "", limit)
 }
 
-func (b *Backend) zRangeByScoreWithScores(key string, min, max float64, limit int) (keyvaluestore.ScoredMembers, error) {
-	s, _ := b.m[key].(*sortedSet)
-	if s == nil {
-		return nil, nil
+// If after is given, only members whose sort keys come after it are returned.
+func (b *Backend) zRangeByScoreWithScores(key string, min, max float64, after string, limit int) (keyvaluestore.ScoredMembers, error) {
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
 	}
 
 	var results []*keyvaluestore.ScoredMember
@@ -365,6 +674,9 @@ func (b *Backend) zRangeByScoreWithScores(key string, min, max float64, limit in
 	} else {
 		next = next.Next()
 	}
+	if after != "" && next != nil && next.Key().(string) <= after {
+		next = s.m.MinAfter(after)
+	}
 
 	for (limit == 0 || len(results) < limit) && next != nil && next.Key().(string)[:len(maxSortKeyPrefix)] <= maxSortKeyPrefix {
 		results = append(results, &keyvaluestore.ScoredMember{
@@ -378,10 +690,14 @@ func (b *Backend) zRangeByScoreWithScores(key string, min, max float64, limit in
 }
 
 func (b *Backend) ZRevRangeByScore(key string, min, max float64, limit int) ([]string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	if members, err := b.zRevRangeByScoreWithScores(key, min, max, limit); err != nil {
+	if members, err := b.zRevRangeByScoreWithScores(key, min, max, "", limit); err != nil {
 		return nil, err
 	} else {
 		return members.Values(), nil
@@ -389,16 +705,21 @@ func (b *Backend) ZRevRangeByScore(key string, min, max float64, limit int) ([]s
 }
 
 func (b *Backend) ZRevRangeByScoreWithScores(key string, min, max float64, limit int) (keyvaluestore.ScoredMembers, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	return b.zRevRangeByScoreWithScores(key, min, max, limit)
+	return b.zRevRangeByScoreWithScores(key, min, max, "", limit)
 }
 
-func (b *Backend) zRevRangeByScoreWithScores(key string, min, max float64, limit int) (keyvaluestore.ScoredMembers, error) {
-	s, _ := b.m[key].(*sortedSet)
-	if s == nil {
-		return nil, nil
+// If before is given, only members whose sort keys come before it are returned.
+func (b *Backend) zRevRangeByScoreWithScores(key string, min, max float64, before string, limit int) (keyvaluestore.ScoredMembers, error) {
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
 	}
 
 	var results []*keyvaluestore.ScoredMember
@@ -412,6 +733,9 @@ func (b *Backend) zRevRangeByScoreWithScores(key string, min, max float64, limit
 	} else {
 		next = s.m.MaxBefore(sortKeyAfterMax)
 	}
+	if before != "" && next != nil && next.Key().(string) >= before {
+		next = s.m.MaxBefore(before)
+	}
 
 	for (limit == 0 || len(results) < limit) && next != nil && next.Key().(string) >= minSortKey {
 		results = append(results, &keyvaluestore.ScoredMember{
@@ -424,23 +748,198 @@ func (b *Backend) zRevRangeByScoreWithScores(key string, min, max float64, limit
 	return results, nil
 }
 
+// Score range cursors are the sort key of the last member of the page.
+func (b *Backend) ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	return b.zRangeByScorePage(key, min, max, cursor, limit, false)
+}
+
+func (b *Backend) ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	return b.zRangeByScorePage(key, min, max, cursor, limit, true)
+}
+
+func (b *Backend) zRangeByScorePage(key string, min, max float64, cursor string, limit int, reverse bool) (*keyvaluestore.ZRangePage, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	after, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || (cursor != "" && len(after) < floatSortKeyNumBytes) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var members keyvaluestore.ScoredMembers
+	if reverse {
+		members, err = b.zRevRangeByScoreWithScores(key, min, max, string(after), limit)
+	} else {
+		members, err = b.zRangeByScoreWithScores(key, min, max, string(after), limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	page := &keyvaluestore.ZRangePage{
+		Members: members,
+	}
+	if limit > 0 && len(members) == limit {
+		last := members[len(members)-1]
+		page.Cursor = base64.RawURLEncoding.EncodeToString([]byte(floatSortKey(last.Score) + last.Value))
+	}
+	return page, nil
+}
+
+// Lexicographical range cursors are the last member of the page.
+func (b *Backend) ZRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	if cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		min = "(" + string(after)
+	}
+	members, err := b.ZRangeByLex(key, min, max, limit)
+	if err != nil {
+		return nil, err
+	}
+	return lexRangePage(members, limit), nil
+}
+
+func (b *Backend) ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	if cursor != "" {
+		before, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		max = "(" + string(before)
+	}
+	members, err := b.ZRevRangeByLex(key, min, max, limit)
+	if err != nil {
+		return nil, err
+	}
+	return lexRangePage(members, limit), nil
+}
+
+func lexRangePage(members []string, limit int) *keyvaluestore.ZLexRangePage {
+	page := &keyvaluestore.ZLexRangePage{
+		Members: members,
+	}
+	if limit > 0 && len(members) == limit {
+		page.Cursor = base64.RawURLEncoding.EncodeToString([]byte(members[len(members)-1]))
+	}
+	return page
+}
+
 func (b *Backend) ZCount(key string, min, max float64) (int, error) {
 	members, err := b.ZRangeByScore(key, min, max, 0)
 	return len(members), err
 }
 
+// Returns the smallest sort key prefix within the range and the smallest prefix above it. The
+// upper key is empty if the range is unbounded. Returns false if nothing can be in the range.
+func scoreRangeSortKeys(min, max keyvaluestore.ScoreBound) (string, string, bool) {
+	low := floatSortKey(min.Score)
+	if min.Exclusive {
+		if low = floatSortKeyAfter(min.Score); low == "" {
+			return "", "", false
+		}
+	}
+	high := floatSortKey(max.Score)
+	if !max.Exclusive {
+		high = floatSortKeyAfter(max.Score)
+	}
+	return low, high, high == "" || low < high
+}
+
+func (b *Backend) ZRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	return b.zRangeByScoreRange(key, r, false)
+}
+
+func (b *Backend) ZRevRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	return b.zRangeByScoreRange(key, r, true)
+}
+
+func (b *Backend) zRangeByScoreRange(key string, r keyvaluestore.ScoreRange, reverse bool) (keyvaluestore.ScoredMembers, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	low, high, ok := scoreRangeSortKeys(r.Min, r.Max)
+	if !ok {
+		return nil, nil
+	}
+
+	var next *immutable.OrderedMapElement
+	if reverse {
+		if high == "" {
+			next = s.m.Max()
+		} else {
+			next = s.m.MaxBefore(high)
+		}
+	} else if next = s.m.MaxBefore(low); next == nil {
+		next = s.m.Min()
+	} else {
+		next = next.Next()
+	}
+
+	var results keyvaluestore.ScoredMembers
+	for skipped := 0; (r.Limit == 0 || len(results) < r.Limit) && next != nil; {
+		sortKey := next.Key().(string)
+		if reverse && sortKey < low {
+			break
+		} else if !reverse && high != "" && sortKey >= high {
+			break
+		}
+		if skipped < r.Offset {
+			skipped++
+		} else {
+			results = append(results, &keyvaluestore.ScoredMember{
+				Score: sortKeyFloat(sortKey),
+				Value: next.Value().(string),
+			})
+		}
+		if reverse {
+			next = next.Prev()
+		} else {
+			next = next.Next()
+		}
+	}
+	return results, nil
+}
+
+func (b *Backend) ZCountRange(key string, min, max keyvaluestore.ScoreBound) (int, error) {
+	members, err := b.ZRangeByScoreRange(key, keyvaluestore.ScoreRange{
+		Min: min,
+		Max: max,
+	})
+	return len(members), err
+}
+
 func (b *Backend) ZLexCount(key string, min, max string) (int, error) {
 	members, err := b.ZRangeByLex(key, min, max, 0)
 	return len(members), err
 }
 
 func (b *Backend) ZRangeByLex(key string, min, max string, limit int) ([]string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	s, _ := b.m[key].(*sortedSet)
-	if s == nil {
-		return nil, nil
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
 	}
 
 	var results []string
@@ -476,12 +975,16 @@ func (b *Backend) ZRangeByLex(key string, min, max string, limit int) ([]string,
 }
 
 func (b *Backend) ZRevRangeByLex(key string, min, max string, limit int) ([]string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
-	s, _ := b.m[key].(*sortedSet)
-	if s == nil {
-		return nil, nil
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
 	}
 
 	var results []string
@@ -515,3 +1018,310 @@ func (b *Backend) ZRevRangeByLex(key string, min, max string, limit int) ([]stri
 
 	return results, nil
 }
+
+func (b *Backend) HSet(key, field string, value interface{}) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.hset(key, field, *keyvaluestore.ToString(value))
+}
+
+func (b *Backend) hset(key, field string, value string) error {
+	h, err := b.loadHash(key)
+	if err != nil {
+		return err
+	} else if h == nil {
+		h = make(map[string]string)
+	}
+	h[field] = value
+	b.m[key] = h
+	return nil
+}
+
+func (b *Backend) HGet(key, field string) (*string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.hget(key, field)
+}
+
+func (b *Backend) hget(key, field string) (*string, error) {
+	h, err := b.loadHash(key)
+	if value, ok := h[field]; ok {
+		return &value, nil
+	}
+	return nil, err
+}
+
+func (b *Backend) HMGet(key string, field string, fields ...string) ([]*string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	h, err := b.loadHash(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*string, 1+len(fields))
+	for i, field := range append([]string{field}, fields...) {
+		if value, ok := h[field]; ok {
+			results[i] = &value
+		}
+	}
+	return results, nil
+}
+
+func (b *Backend) HDel(key string, field string, fields ...string) error {
+	if err := b.ctx.Err(); err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	h, err := b.loadHash(key)
+	if err != nil || h == nil {
+		return err
+	}
+	delete(h, field)
+	for _, field := range fields {
+		delete(h, field)
+	}
+	if len(h) == 0 {
+		b.delete(key)
+	}
+	return nil
+}
+
+func (b *Backend) HGetAll(key string) (map[string]string, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	h, err := b.loadHash(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make(map[string]string)
+	for field, value := range h {
+		results[field] = value
+	}
+	return results, nil
+}
+
+func (b *Backend) HIncrBy(key, field string, n int64) (int64, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	v, err := b.hget(key, field)
+	if err != nil {
+		return 0, err
+	} else if v != nil {
+		i, err := strconv.ParseInt(*v, 10, 64)
+		if err != nil {
+			return 0, keyvaluestore.ErrWrongType
+		}
+		n += i
+	}
+	return n, b.hset(key, field, strconv.FormatInt(n, 10))
+}
+
+func keyType(v interface{}) keyvaluestore.KeyType {
+	switch v.(type) {
+	case map[string]struct{}:
+		return keyvaluestore.KeyTypeSet
+	case *sortedSet:
+		return keyvaluestore.KeyTypeSortedSet
+	case map[string]string:
+		return keyvaluestore.KeyTypeHash
+	}
+	return keyvaluestore.KeyTypeString
+}
+
+// Keys are scanned in lexicographical order. The cursor is the last key returned, prefixed with
+// ">" so that it's never empty.
+func (b *Backend) Scan(prefix, cursor string, limit int) (*keyvaluestore.ScanPage, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var after *string
+	if cursor != "" {
+		if !strings.HasPrefix(cursor, ">") {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		s := cursor[1:]
+		after = &s
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var keys []string
+	for key := range b.m {
+		if strings.HasPrefix(key, prefix) && (after == nil || key > *after) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	page := &keyvaluestore.ScanPage{}
+	for _, key := range keys {
+		if limit > 0 && len(page.Keys) == limit {
+			page.Cursor = ">" + page.Keys[len(page.Keys)-1].Key
+			break
+		}
+		if v, ok := b.load(key); ok {
+			page.Keys = append(page.Keys, keyvaluestore.ScannedKey{
+				Key:  key,
+				Type: keyType(v),
+			})
+		}
+	}
+	return page, nil
+}
+
+func (b *Backend) ZCard(key string) (int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, err := b.loadSortedSet(key)
+	if s != nil {
+		return len(s.scoresByMember), nil
+	}
+	return 0, err
+}
+
+func (b *Backend) ZRank(key string, member interface{}) (*int, error) {
+	return b.zRank(key, member, false)
+}
+
+func (b *Backend) ZRevRank(key string, member interface{}) (*int, error) {
+	return b.zRank(key, member, true)
+}
+
+func (b *Backend) zRank(key string, member interface{}, reverse bool) (*int, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	m := *keyvaluestore.ToString(member)
+	score, ok := s.scoresByMember[m]
+	if !ok {
+		return nil, nil
+	}
+
+	rank := 0
+	if prev := s.m.MaxBefore(floatSortKey(score) + m); prev != nil {
+		rank = prev.CountLess() + 1
+	}
+	if reverse {
+		rank = len(s.scoresByMember) - 1 - rank
+	}
+	return &rank, nil
+}
+
+// Converts a Redis-style rank range, which may use negative ranks, into non-negative ranks. Returns
+// false if the range is empty.
+func rankRange(start, stop, card int) (int, int, bool) {
+	if start < 0 {
+		start += card
+		if start < 0 {
+			start = 0
+		}
+	}
+	if stop < 0 {
+		stop += card
+	} else if stop >= card {
+		stop = card - 1
+	}
+	return start, stop, start <= stop
+}
+
+func (b *Backend) ZRange(key string, start, stop int) ([]string, error) {
+	members, err := b.zRange(key, start, stop, false)
+	return members.Values(), err
+}
+
+func (b *Backend) ZRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	return b.zRange(key, start, stop, false)
+}
+
+func (b *Backend) ZRevRange(key string, start, stop int) ([]string, error) {
+	members, err := b.zRange(key, start, stop, true)
+	return members.Values(), err
+}
+
+func (b *Backend) ZRevRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	return b.zRange(key, start, stop, true)
+}
+
+func (b *Backend) zRange(key string, start, stop int, reverse bool) (keyvaluestore.ScoredMembers, error) {
+	if err := b.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	s, err := b.loadSortedSet(key)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	start, stop, ok := rankRange(start, stop, len(s.scoresByMember))
+	if !ok {
+		return nil, nil
+	}
+
+	var next *immutable.OrderedMapElement
+	if reverse {
+		next = s.m.Max()
+	} else {
+		next = s.m.Min()
+	}
+
+	var results keyvaluestore.ScoredMembers
+	for i := 0; i <= stop && next != nil; i++ {
+		if i >= start {
+			results = append(results, &keyvaluestore.ScoredMember{
+				Score: sortKeyFloat(next.Key().(string)),
+				Value: next.Value().(string),
+			})
+		}
+		if reverse {
+			next = next.Prev()
+		} else {
+			next = next.Next()
+		}
+	}
+	return results, nil
+}
@@ -12,3 +12,9 @@ func TestBackend(t *testing.T) {
 		return NewBackend()
 	})
 }
+
+func TestBackendExpiration(t *testing.T) {
+	keyvaluestoretest.TestBackendExpiration(t, func(clock keyvaluestore.Clock) keyvaluestore.Backend {
+		return NewBackendWithClock(clock)
+	})
+}
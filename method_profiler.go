@@ -0,0 +1,74 @@
+package keyvaluestore
+
+import (
+	"sync"
+	"time"
+)
+
+// MethodProfile holds the statistics that MethodProfiler records for a single method.
+type MethodProfile struct {
+	Count      int
+	ErrorCount int
+
+	// The total and maximum durations of the method's calls.
+	Duration    time.Duration
+	MaxDuration time.Duration
+}
+
+// MethodProfiler is an Interceptor that records call counts and latencies for each method of an
+// InstrumentedBackend. Batches and atomic operations are recorded under "Batch", "AtomicWrite",
+// and "AtomicRead" when they're executed.
+type MethodProfiler struct {
+	mutex    sync.Mutex
+	profiles map[string]*MethodProfile
+}
+
+var _ Interceptor = (*MethodProfiler)(nil)
+
+func (p *MethodProfiler) Before(op *Operation) {}
+
+func (p *MethodProfiler) After(op *Operation, duration time.Duration, err error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.profiles == nil {
+		p.profiles = map[string]*MethodProfile{}
+	}
+	profile, ok := p.profiles[op.Name]
+	if !ok {
+		profile = &MethodProfile{}
+		p.profiles[op.Name] = profile
+	}
+	profile.Count++
+	if err != nil {
+		profile.ErrorCount++
+	}
+	profile.Duration += duration
+	if duration > profile.MaxDuration {
+		profile.MaxDuration = duration
+	}
+}
+
+// Returns the profile for the given method. If the method hasn't been called, the profile is
+// empty.
+func (p *MethodProfiler) Profile(name string) MethodProfile {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if profile, ok := p.profiles[name]; ok {
+		return *profile
+	}
+	return MethodProfile{}
+}
+
+// Returns the profiles of all of the methods that have been called, keyed by method name.
+func (p *MethodProfiler) Profiles() map[string]MethodProfile {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	ret := make(map[string]MethodProfile, len(p.profiles))
+	for name, profile := range p.profiles {
+		ret[name] = *profile
+	}
+	return ret
+}
@@ -0,0 +1,488 @@
+package keyvaluestore
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// PrefixedBackend prefixes every key with a namespace, allowing several applications or tests to
+// share a store. Keys returned by Scan have the prefix removed.
+type PrefixedBackend struct {
+	Backend Backend
+	Prefix  string
+}
+
+var _ Backend = &PrefixedBackend{}
+
+// Returns a backend that prefixes every key with the given prefix. If b is itself a
+// *PrefixedBackend, the prefixes are combined so that nested namespaces don't add any overhead.
+func NewPrefixedBackend(b Backend, prefix string) *PrefixedBackend {
+	if prefixed, ok := b.(*PrefixedBackend); ok {
+		return &PrefixedBackend{
+			Backend: prefixed.Backend,
+			Prefix:  prefixed.Prefix + prefix,
+		}
+	}
+	return &PrefixedBackend{
+		Backend: b,
+		Prefix:  prefix,
+	}
+}
+
+func (b *PrefixedBackend) WithContext(ctx context.Context) Backend {
+	return &PrefixedBackend{
+		Backend: b.Backend.WithContext(ctx),
+		Prefix:  b.Prefix,
+	}
+}
+
+func (b *PrefixedBackend) Batch() BatchOperation {
+	return &prefixedBatchOperation{
+		batch:  b.Backend.Batch(),
+		prefix: b.Prefix,
+	}
+}
+
+func (b *PrefixedBackend) AtomicWrite() AtomicWriteOperation {
+	return &prefixedAtomicWriteOperation{
+		atomicWrite: b.Backend.AtomicWrite(),
+		prefix:      b.Prefix,
+	}
+}
+
+func (b *PrefixedBackend) AtomicRead() AtomicReadOperation {
+	return &prefixedAtomicReadOperation{
+		atomicRead: b.Backend.AtomicRead(),
+		prefix:     b.Prefix,
+	}
+}
+
+func (b *PrefixedBackend) Capabilities() Capabilities {
+	return b.Backend.Capabilities()
+}
+
+func (b *PrefixedBackend) Delete(key string) (success bool, err error) {
+	return b.Backend.Delete(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) Get(key string) (*string, error) {
+	return b.Backend.Get(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) Set(key string, value interface{}) error {
+	return b.Backend.Set(b.Prefix+key, value)
+}
+
+func (b *PrefixedBackend) CAS(key string, transform func(v *string) (interface{}, error)) (success bool, err error) {
+	return b.Backend.CAS(b.Prefix+key, transform)
+}
+
+func (b *PrefixedBackend) AddInt(key string, n int64) (int64, error) {
+	return b.Backend.AddInt(b.Prefix+key, n)
+}
+
+func (b *PrefixedBackend) SetXX(key string, value interface{}) (bool, error) {
+	return b.Backend.SetXX(b.Prefix+key, value)
+}
+
+func (b *PrefixedBackend) SetNX(key string, value interface{}) (bool, error) {
+	return b.Backend.SetNX(b.Prefix+key, value)
+}
+
+func (b *PrefixedBackend) SetEX(key string, value interface{}, ttl time.Duration) error {
+	return b.Backend.SetEX(b.Prefix+key, value, ttl)
+}
+
+func (b *PrefixedBackend) Expire(key string, ttl time.Duration) (bool, error) {
+	return b.Backend.Expire(b.Prefix+key, ttl)
+}
+
+func (b *PrefixedBackend) TTL(key string) (*time.Duration, error) {
+	return b.Backend.TTL(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) SAdd(key string, member interface{}, members ...interface{}) error {
+	return b.Backend.SAdd(b.Prefix+key, member, members...)
+}
+
+func (b *PrefixedBackend) SRem(key string, member interface{}, members ...interface{}) error {
+	return b.Backend.SRem(b.Prefix+key, member, members...)
+}
+
+func (b *PrefixedBackend) SMembers(key string) ([]string, error) {
+	return b.Backend.SMembers(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) SIsMember(key string, member interface{}) (bool, error) {
+	return b.Backend.SIsMember(b.Prefix+key, member)
+}
+
+func (b *PrefixedBackend) SCard(key string) (int, error) {
+	return b.Backend.SCard(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) SRandMember(key string) (*string, error) {
+	return b.Backend.SRandMember(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) SPop(key string) (*string, error) {
+	return b.Backend.SPop(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) ZAdd(key string, member interface{}, score float64) error {
+	return b.Backend.ZAdd(b.Prefix+key, member, score)
+}
+
+func (b *PrefixedBackend) ZScore(key string, member interface{}) (*float64, error) {
+	return b.Backend.ZScore(b.Prefix+key, member)
+}
+
+func (b *PrefixedBackend) ZRem(key string, member interface{}) error {
+	return b.Backend.ZRem(b.Prefix+key, member)
+}
+
+func (b *PrefixedBackend) ZIncrBy(key string, member string, n float64) (float64, error) {
+	return b.Backend.ZIncrBy(b.Prefix+key, member, n)
+}
+
+func (b *PrefixedBackend) ZRangeByScore(key string, min, max float64, limit int) ([]string, error) {
+	return b.Backend.ZRangeByScore(b.Prefix+key, min, max, limit)
+}
+
+func (b *PrefixedBackend) ZRangeByScoreWithScores(key string, min, max float64, limit int) (ScoredMembers, error) {
+	return b.Backend.ZRangeByScoreWithScores(b.Prefix+key, min, max, limit)
+}
+
+func (b *PrefixedBackend) ZRevRangeByScore(key string, min, max float64, limit int) ([]string, error) {
+	return b.Backend.ZRevRangeByScore(b.Prefix+key, min, max, limit)
+}
+
+func (b *PrefixedBackend) ZRevRangeByScoreWithScores(key string, min, max float64, limit int) (ScoredMembers, error) {
+	return b.Backend.ZRevRangeByScoreWithScores(b.Prefix+key, min, max, limit)
+}
+
+func (b *PrefixedBackend) ZCount(key string, min, max float64) (int, error) {
+	return b.Backend.ZCount(b.Prefix+key, min, max)
+}
+
+func (b *PrefixedBackend) ZRangeByScoreRange(key string, r ScoreRange) (ScoredMembers, error) {
+	return b.Backend.ZRangeByScoreRange(b.Prefix+key, r)
+}
+
+func (b *PrefixedBackend) ZRevRangeByScoreRange(key string, r ScoreRange) (ScoredMembers, error) {
+	return b.Backend.ZRevRangeByScoreRange(b.Prefix+key, r)
+}
+
+func (b *PrefixedBackend) ZCountRange(key string, min, max ScoreBound) (int, error) {
+	return b.Backend.ZCountRange(b.Prefix+key, min, max)
+}
+
+func (b *PrefixedBackend) ZLexCount(key string, min, max string) (int, error) {
+	return b.Backend.ZLexCount(b.Prefix+key, min, max)
+}
+
+func (b *PrefixedBackend) ZRangeByLex(key string, min, max string, limit int) ([]string, error) {
+	return b.Backend.ZRangeByLex(b.Prefix+key, min, max, limit)
+}
+
+func (b *PrefixedBackend) ZRevRangeByLex(key string, min, max string, limit int) ([]string, error) {
+	return b.Backend.ZRevRangeByLex(b.Prefix+key, min, max, limit)
+}
+
+func (b *PrefixedBackend) ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*ZRangePage, error) {
+	return b.Backend.ZRangeByScorePage(b.Prefix+key, min, max, cursor, limit)
+}
+
+func (b *PrefixedBackend) ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*ZRangePage, error) {
+	return b.Backend.ZRevRangeByScorePage(b.Prefix+key, min, max, cursor, limit)
+}
+
+func (b *PrefixedBackend) ZRangeByLexPage(key string, min, max, cursor string, limit int) (*ZLexRangePage, error) {
+	return b.Backend.ZRangeByLexPage(b.Prefix+key, min, max, cursor, limit)
+}
+
+func (b *PrefixedBackend) ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*ZLexRangePage, error) {
+	return b.Backend.ZRevRangeByLexPage(b.Prefix+key, min, max, cursor, limit)
+}
+
+func (b *PrefixedBackend) ZCard(key string) (int, error) {
+	return b.Backend.ZCard(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) ZRank(key string, member interface{}) (*int, error) {
+	return b.Backend.ZRank(b.Prefix+key, member)
+}
+
+func (b *PrefixedBackend) ZRevRank(key string, member interface{}) (*int, error) {
+	return b.Backend.ZRevRank(b.Prefix+key, member)
+}
+
+func (b *PrefixedBackend) ZRange(key string, start, stop int) ([]string, error) {
+	return b.Backend.ZRange(b.Prefix+key, start, stop)
+}
+
+func (b *PrefixedBackend) ZRangeWithScores(key string, start, stop int) (ScoredMembers, error) {
+	return b.Backend.ZRangeWithScores(b.Prefix+key, start, stop)
+}
+
+func (b *PrefixedBackend) ZRevRange(key string, start, stop int) ([]string, error) {
+	return b.Backend.ZRevRange(b.Prefix+key, start, stop)
+}
+
+func (b *PrefixedBackend) ZRevRangeWithScores(key string, start, stop int) (ScoredMembers, error) {
+	return b.Backend.ZRevRangeWithScores(b.Prefix+key, start, stop)
+}
+
+func (b *PrefixedBackend) HSet(key, field string, value interface{}) error {
+	return b.Backend.HSet(b.Prefix+key, field, value)
+}
+
+func (b *PrefixedBackend) HGet(key, field string) (*string, error) {
+	return b.Backend.HGet(b.Prefix+key, field)
+}
+
+func (b *PrefixedBackend) HMGet(key string, field string, fields ...string) ([]*string, error) {
+	return b.Backend.HMGet(b.Prefix+key, field, fields...)
+}
+
+func (b *PrefixedBackend) HDel(key string, field string, fields ...string) error {
+	return b.Backend.HDel(b.Prefix+key, field, fields...)
+}
+
+func (b *PrefixedBackend) HGetAll(key string) (map[string]string, error) {
+	return b.Backend.HGetAll(b.Prefix + key)
+}
+
+func (b *PrefixedBackend) HIncrBy(key, field string, n int64) (int64, error) {
+	return b.Backend.HIncrBy(b.Prefix+key, field, n)
+}
+
+func (b *PrefixedBackend) Scan(prefix, cursor string, limit int) (*ScanPage, error) {
+	page, err := b.Backend.Scan(b.Prefix+prefix, cursor, limit)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]ScannedKey, len(page.Keys))
+	for i, key := range page.Keys {
+		keys[i] = ScannedKey{
+			Key:  strings.TrimPrefix(key.Key, b.Prefix),
+			Type: key.Type,
+		}
+	}
+	return &ScanPage{
+		Keys:   keys,
+		Cursor: page.Cursor,
+	}, nil
+}
+
+type prefixedBatchOperation struct {
+	batch  BatchOperation
+	prefix string
+}
+
+func (op *prefixedBatchOperation) Get(key string) GetResult {
+	return op.batch.Get(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) Delete(key string) DeleteResult {
+	return op.batch.Delete(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) Set(key string, value interface{}) ErrorResult {
+	return op.batch.Set(op.prefix+key, value)
+}
+
+func (op *prefixedBatchOperation) SetNX(key string, value interface{}) BoolResult {
+	return op.batch.SetNX(op.prefix+key, value)
+}
+
+func (op *prefixedBatchOperation) SetXX(key string, value interface{}) BoolResult {
+	return op.batch.SetXX(op.prefix+key, value)
+}
+
+func (op *prefixedBatchOperation) AddInt(key string, n int64) IntResult {
+	return op.batch.AddInt(op.prefix+key, n)
+}
+
+func (op *prefixedBatchOperation) SetEX(key string, value interface{}, ttl time.Duration) ErrorResult {
+	return op.batch.SetEX(op.prefix+key, value, ttl)
+}
+
+func (op *prefixedBatchOperation) Expire(key string, ttl time.Duration) BoolResult {
+	return op.batch.Expire(op.prefix+key, ttl)
+}
+
+func (op *prefixedBatchOperation) SMembers(key string) SMembersResult {
+	return op.batch.SMembers(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) SAdd(key string, member interface{}, members ...interface{}) ErrorResult {
+	return op.batch.SAdd(op.prefix+key, member, members...)
+}
+
+func (op *prefixedBatchOperation) SRem(key string, member interface{}, members ...interface{}) ErrorResult {
+	return op.batch.SRem(op.prefix+key, member, members...)
+}
+
+func (op *prefixedBatchOperation) SIsMember(key string, member interface{}) BoolResult {
+	return op.batch.SIsMember(op.prefix+key, member)
+}
+
+func (op *prefixedBatchOperation) SCard(key string) IntResult {
+	return op.batch.SCard(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) SRandMember(key string) GetResult {
+	return op.batch.SRandMember(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) SPop(key string) GetResult {
+	return op.batch.SPop(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) ZAdd(key string, member interface{}, score float64) ErrorResult {
+	return op.batch.ZAdd(op.prefix+key, member, score)
+}
+
+func (op *prefixedBatchOperation) ZRem(key string, member interface{}) ErrorResult {
+	return op.batch.ZRem(op.prefix+key, member)
+}
+
+func (op *prefixedBatchOperation) ZScore(key string, member interface{}) ScoreResult {
+	return op.batch.ZScore(op.prefix+key, member)
+}
+
+func (op *prefixedBatchOperation) ZIncrBy(key string, member string, n float64) FloatResult {
+	return op.batch.ZIncrBy(op.prefix+key, member, n)
+}
+
+func (op *prefixedBatchOperation) ZCount(key string, min, max float64) IntResult {
+	return op.batch.ZCount(op.prefix+key, min, max)
+}
+
+func (op *prefixedBatchOperation) ZRangeByScore(key string, min, max float64, limit int) ZRangeResult {
+	return op.batch.ZRangeByScore(op.prefix+key, min, max, limit)
+}
+
+func (op *prefixedBatchOperation) ZRangeByScoreWithScores(key string, min, max float64, limit int) ZRangeWithScoresResult {
+	return op.batch.ZRangeByScoreWithScores(op.prefix+key, min, max, limit)
+}
+
+func (op *prefixedBatchOperation) ZRangeByLex(key string, min, max string, limit int) ZRangeResult {
+	return op.batch.ZRangeByLex(op.prefix+key, min, max, limit)
+}
+
+func (op *prefixedBatchOperation) HSet(key, field string, value interface{}) ErrorResult {
+	return op.batch.HSet(op.prefix+key, field, value)
+}
+
+func (op *prefixedBatchOperation) HGet(key, field string) GetResult {
+	return op.batch.HGet(op.prefix+key, field)
+}
+
+func (op *prefixedBatchOperation) HMGet(key string, field string, fields ...string) HMGetResult {
+	return op.batch.HMGet(op.prefix+key, field, fields...)
+}
+
+func (op *prefixedBatchOperation) HDel(key string, field string, fields ...string) ErrorResult {
+	return op.batch.HDel(op.prefix+key, field, fields...)
+}
+
+func (op *prefixedBatchOperation) HGetAll(key string) HGetAllResult {
+	return op.batch.HGetAll(op.prefix + key)
+}
+
+func (op *prefixedBatchOperation) HIncrBy(key, field string, n int64) IntResult {
+	return op.batch.HIncrBy(op.prefix+key, field, n)
+}
+
+func (op *prefixedBatchOperation) Exec() error {
+	return op.batch.Exec()
+}
+
+type prefixedAtomicWriteOperation struct {
+	atomicWrite AtomicWriteOperation
+	prefix      string
+}
+
+func (op *prefixedAtomicWriteOperation) SetNX(key string, value interface{}) AtomicWriteResult {
+	return op.atomicWrite.SetNX(op.prefix+key, value)
+}
+
+func (op *prefixedAtomicWriteOperation) CAS(key string, oldValue, newValue string) AtomicWriteResult {
+	return op.atomicWrite.CAS(op.prefix+key, oldValue, newValue)
+}
+
+func (op *prefixedAtomicWriteOperation) Delete(key string) AtomicWriteResult {
+	return op.atomicWrite.Delete(op.prefix + key)
+}
+
+func (op *prefixedAtomicWriteOperation) SetEX(key string, value interface{}, ttl time.Duration) AtomicWriteResult {
+	return op.atomicWrite.SetEX(op.prefix+key, value, ttl)
+}
+
+func (op *prefixedAtomicWriteOperation) Set(key string, value interface{}) AtomicWriteResult {
+	return op.atomicWrite.Set(op.prefix+key, value)
+}
+
+func (op *prefixedAtomicWriteOperation) SAdd(key string, member interface{}, members ...interface{}) AtomicWriteResult {
+	return op.atomicWrite.SAdd(op.prefix+key, member, members...)
+}
+
+func (op *prefixedAtomicWriteOperation) SRem(key string, member interface{}, members ...interface{}) AtomicWriteResult {
+	return op.atomicWrite.SRem(op.prefix+key, member, members...)
+}
+
+func (op *prefixedAtomicWriteOperation) ZAdd(key string, member interface{}, score float64) AtomicWriteResult {
+	return op.atomicWrite.ZAdd(op.prefix+key, member, score)
+}
+
+func (op *prefixedAtomicWriteOperation) ZRem(key string, member interface{}) AtomicWriteResult {
+	return op.atomicWrite.ZRem(op.prefix+key, member)
+}
+
+func (op *prefixedAtomicWriteOperation) SetXX(key string, value interface{}) AtomicWriteResult {
+	return op.atomicWrite.SetXX(op.prefix+key, value)
+}
+
+func (op *prefixedAtomicWriteOperation) AddInt(key string, n int64) AtomicWriteResult {
+	return op.atomicWrite.AddInt(op.prefix+key, n)
+}
+
+func (op *prefixedAtomicWriteOperation) Check(key string, expected string) AtomicWriteResult {
+	return op.atomicWrite.Check(op.prefix+key, expected)
+}
+
+func (op *prefixedAtomicWriteOperation) CheckExists(key string) AtomicWriteResult {
+	return op.atomicWrite.CheckExists(op.prefix + key)
+}
+
+func (op *prefixedAtomicWriteOperation) CheckNotExists(key string) AtomicWriteResult {
+	return op.atomicWrite.CheckNotExists(op.prefix + key)
+}
+
+func (op *prefixedAtomicWriteOperation) Exec() (bool, error) {
+	return op.atomicWrite.Exec()
+}
+
+type prefixedAtomicReadOperation struct {
+	atomicRead AtomicReadOperation
+	prefix     string
+}
+
+func (op *prefixedAtomicReadOperation) Get(key string) GetResult {
+	return op.atomicRead.Get(op.prefix + key)
+}
+
+func (op *prefixedAtomicReadOperation) SMembers(key string) SMembersResult {
+	return op.atomicRead.SMembers(op.prefix + key)
+}
+
+func (op *prefixedAtomicReadOperation) ZScore(key string, member interface{}) ScoreResult {
+	return op.atomicRead.ZScore(op.prefix+key, member)
+}
+
+func (op *prefixedAtomicReadOperation) Exec() error {
+	return op.atomicRead.Exec()
+}
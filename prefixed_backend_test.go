@@ -0,0 +1,79 @@
+package keyvaluestore_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+func TestPrefixedBackend(t *testing.T) {
+	keyvaluestoretest.TestBackend(t, func() keyvaluestore.Backend {
+		return keyvaluestore.NewPrefixedBackend(memorystore.NewBackend(), "prefix:")
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		keyvaluestoretest.TestBackend(t, func() keyvaluestore.Backend {
+			return keyvaluestore.NewPrefixedBackend(keyvaluestore.NewPrefixedBackend(memorystore.NewBackend(), "a:"), "b:")
+		})
+	})
+
+	t.Run("Isolation", func(t *testing.T) {
+		shared := memorystore.NewBackend()
+		a := keyvaluestore.NewPrefixedBackend(shared, "a:")
+		b := keyvaluestore.NewPrefixedBackend(shared, "b:")
+		nested := keyvaluestore.NewPrefixedBackend(a, "c:")
+		assert.Equal(t, "a:c:", nested.Prefix)
+
+		require.NoError(t, a.Set("foo", "a"))
+		require.NoError(t, b.Set("foo", "b"))
+		require.NoError(t, nested.Set("foo", "c"))
+
+		batch := a.Batch()
+		batch.SAdd("set", "x")
+		require.NoError(t, batch.Exec())
+
+		tx := b.AtomicWrite()
+		tx.ZAdd("zset", "y", 1)
+		ok, err := tx.Exec()
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		for key, expected := range map[string]string{"a:foo": "a", "b:foo": "b", "a:c:foo": "c"} {
+			v, err := shared.Get(key)
+			require.NoError(t, err)
+			if assert.NotNil(t, v) {
+				assert.Equal(t, expected, *v)
+			}
+		}
+
+		members, err := shared.SMembers("a:set")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"x"}, members)
+
+		score, err := shared.ZScore("b:zset", "y")
+		require.NoError(t, err)
+		assert.NotNil(t, score)
+
+		read := nested.AtomicRead()
+		get := read.Get("foo")
+		require.NoError(t, read.Exec())
+		v, err := get.Result()
+		require.NoError(t, err)
+		if assert.NotNil(t, v) {
+			assert.Equal(t, "c", *v)
+		}
+
+		page, err := a.Scan("", "", 0)
+		require.NoError(t, err)
+		var keys []string
+		for _, key := range page.Keys {
+			keys = append(keys, key.Key)
+		}
+		assert.ElementsMatch(t, []string{"foo", "set", "c:foo"}, keys)
+	})
+}
@@ -0,0 +1,38 @@
+package redisstore
+
+import (
+	"github.com/go-redis/redis"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+// AtomicReadOperation executes its reads in a MULTI/EXEC transaction. The results are the same as
+// those of a batch.
+type AtomicReadOperation struct {
+	batch *BatchOperation
+}
+
+func newAtomicReadOperation(client *redis.Client) *AtomicReadOperation {
+	return &AtomicReadOperation{
+		batch: &BatchOperation{
+			pipe: client.TxPipeline(),
+			ctx:  client.Context(),
+		},
+	}
+}
+
+func (op *AtomicReadOperation) Get(key string) keyvaluestore.GetResult {
+	return op.batch.Get(key)
+}
+
+func (op *AtomicReadOperation) SMembers(key string) keyvaluestore.SMembersResult {
+	return op.batch.SMembers(key)
+}
+
+func (op *AtomicReadOperation) ZScore(key string, member interface{}) keyvaluestore.ScoreResult {
+	return op.batch.ZScore(key, member)
+}
+
+func (op *AtomicReadOperation) Exec() error {
+	return op.batch.Exec()
+}
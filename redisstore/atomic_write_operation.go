@@ -3,6 +3,7 @@ package redisstore
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/go-redis/redis"
 
@@ -13,6 +14,7 @@ type AtomicWriteOperation struct {
 	Client *redis.Client
 
 	operations []*atomicWriteOperation
+	err        error
 }
 
 type atomicWriteOperation struct {
@@ -21,6 +23,9 @@ type atomicWriteOperation struct {
 	write     string
 	args      []interface{}
 
+	// If set, the key must either not exist or hold this type of value.
+	keyType string
+
 	conditionPassed bool
 }
 
@@ -42,6 +47,18 @@ func (op *AtomicWriteOperation) SetNX(key string, value interface{}) keyvaluesto
 	})
 }
 
+func (op *AtomicWriteOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.AtomicWriteResult {
+	if ttl <= 0 {
+		op.err = fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "true",
+		write:     "redis.call('set', $@, $0, 'px', $1)",
+		args:      []interface{}{value, int64(roundTTL(ttl) / time.Millisecond)},
+	})
+}
+
 func (op *AtomicWriteOperation) CAS(key string, oldValue, newValue string) keyvaluestore.AtomicWriteResult {
 	return op.write(&atomicWriteOperation{
 		key:       key,
@@ -59,6 +76,108 @@ func (op *AtomicWriteOperation) Delete(key string) keyvaluestore.AtomicWriteResu
 	})
 }
 
+func (op *AtomicWriteOperation) Set(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "true",
+		write:     "redis.call('set', $@, $0)",
+		args:      []interface{}{value},
+	})
+}
+
+// Returns a Lua call of the given command with the key and every argument.
+func variadicAtomicWriteExpression(command string, numArgs int) string {
+	expression := "redis.call('" + command + "', $@"
+	for i := 0; i < numArgs; i++ {
+		expression += fmt.Sprintf(", $%d", i)
+	}
+	return expression + ")"
+}
+
+func (op *AtomicWriteOperation) SAdd(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	args := append([]interface{}{member}, members...)
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "true",
+		write:     variadicAtomicWriteExpression("sadd", len(args)),
+		keyType:   "set",
+		args:      args,
+	})
+}
+
+func (op *AtomicWriteOperation) SRem(key string, member interface{}, members ...interface{}) keyvaluestore.AtomicWriteResult {
+	args := append([]interface{}{member}, members...)
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "true",
+		write:     variadicAtomicWriteExpression("srem", len(args)),
+		keyType:   "set",
+		args:      args,
+	})
+}
+
+func (op *AtomicWriteOperation) ZAdd(key string, member interface{}, score float64) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "true",
+		write:     "redis.call('zadd', $@, $0, $1)",
+		keyType:   "zset",
+		args:      []interface{}{score, member},
+	})
+}
+
+func (op *AtomicWriteOperation) ZRem(key string, member interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "true",
+		write:     "redis.call('zrem', $@, $0)",
+		keyType:   "zset",
+		args:      []interface{}{member},
+	})
+}
+
+func (op *AtomicWriteOperation) SetXX(key string, value interface{}) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "redis.call('exists', $@) == 1",
+		write:     "redis.call('set', $@, $0)",
+		args:      []interface{}{value},
+	})
+}
+
+func (op *AtomicWriteOperation) AddInt(key string, n int64) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key: key,
+		// Incrementing an existing key by zero doesn't change it, but it does raise an error if
+		// the value isn't an integer. This aborts the script before anything is written.
+		condition: "redis.call('exists', $@) == 0 or redis.call('incrby', $@, 0)",
+		write:     "redis.call('incrby', $@, $0)",
+		args:      []interface{}{n},
+	})
+}
+
+func (op *AtomicWriteOperation) Check(key string, expected string) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "redis.call('get', $@) == $0",
+		args:      []interface{}{expected},
+	})
+}
+
+func (op *AtomicWriteOperation) CheckExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "redis.call('exists', $@) == 1",
+	})
+}
+
+func (op *AtomicWriteOperation) CheckNotExists(key string) keyvaluestore.AtomicWriteResult {
+	return op.write(&atomicWriteOperation{
+		key:       key,
+		condition: "redis.call('exists', $@) == 0",
+	})
+}
+
 func preprocessAtomicWriteExpression(in string, keyIndex, argsOffset, numArgs int) string {
 	out := strings.Replace(in, "$@", fmt.Sprintf("KEYS[%d]", keyIndex), -1)
 	for i := numArgs - 1; i >= 0; i-- {
@@ -68,15 +187,28 @@ func preprocessAtomicWriteExpression(in string, keyIndex, argsOffset, numArgs in
 }
 
 func (op *AtomicWriteOperation) Exec() (bool, error) {
-	if len(op.operations) > keyvaluestore.MaxAtomicWriteOperations {
-		return false, fmt.Errorf("max operation count exceeded")
+	if op.err != nil {
+		return false, op.err
+	}
+
+	if err := op.Client.Context().Err(); err != nil {
+		return false, err
 	}
 
 	keys := make([]string, len(op.operations))
 	var args []interface{}
 	writeExpressions := make([]string, len(op.operations))
 
-	script := []string{"local checks = {}"}
+	// Redis doesn't roll back a script's writes when a later command fails, so every key's type is
+	// checked before anything is written.
+	var script []string
+	for i, op := range op.operations {
+		if op.keyType != "" {
+			script = append(script, fmt.Sprintf("do local t = redis.call('type', KEYS[%d]).ok if t ~= 'none' and t ~= '%s' then return redis.error_reply('WRONGTYPE Operation against a key holding the wrong kind of value') end end", i+1, op.keyType))
+		}
+	}
+
+	script = append(script, "local checks = {}")
 	for i, op := range op.operations {
 		script = append(script, fmt.Sprintf("checks[%d] = %s", i+1, preprocessAtomicWriteExpression(op.condition, i+1, len(args), len(op.args))))
 		writeExpressions[i] = preprocessAtomicWriteExpression(op.write, i+1, len(args), len(op.args))
@@ -97,7 +229,7 @@ func (op *AtomicWriteOperation) Exec() (bool, error) {
 
 	result, err := op.Client.Eval(strings.Join(script, "\n"), keys, args...).Result()
 	if err != nil {
-		return false, err
+		return false, translateError(err)
 	}
 
 	checks, ok := result.([]interface{})
@@ -1,8 +1,15 @@
 package redisstore
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-redis/redis"
 
@@ -11,6 +18,20 @@ import (
 
 type Backend struct {
 	Client *redis.Client
+
+	// Determines how commands that Redis rejects because it's busy or still loading are retried. If
+	// nil, keyvaluestore.DefaultRetryPolicy is used. Atomic reads are retried as a whole, but
+	// batches aren't retried at all, since they may contain writes that aren't safe to repeat after
+	// part of the batch has succeeded.
+	//
+	// Before retry policies were added, the backend made a single attempt. Set MaxAttempts to 1 to
+	// keep that behavior.
+	//
+	// The client and retry policy shouldn't be changed once the backend is in use.
+	RetryPolicy *keyvaluestore.RetryPolicy
+
+	// The client that applies the retry policy, built when it's first needed.
+	retryingClient atomic.Value
 }
 
 func (b *Backend) WithProfiler(profiler interface{}) *Backend {
@@ -19,113 +40,355 @@ func (b *Backend) WithProfiler(profiler interface{}) *Backend {
 		return b
 	}
 
-	return &Backend{
-		Client: ProfileClient(b.Client, redisProfiler),
+	return b.withClient(ProfileClient(b.Client, redisProfiler))
+}
+
+// Returns a backend whose client uses the given context. The go-redis client doesn't use the context
+// for network I/O, so a command that has already been sent isn't aborted when the context is done.
+// Instead, each method checks the context before it sends anything, and no retries are made once
+// it's done.
+func (b *Backend) WithContext(ctx context.Context) keyvaluestore.Backend {
+	return b.withClient(b.Client.WithContext(ctx))
+}
+
+func (b *Backend) withClient(client *redis.Client) *Backend {
+	ret := &Backend{
+		Client:      client,
+		RetryPolicy: b.RetryPolicy,
+	}
+	ret.retryingClient.Store(ret.newRetryingClient())
+	return ret
+}
+
+// Returns the client that retries commands according to the backend's retry policy.
+func (b *Backend) client() *redis.Client {
+	if client, ok := b.retryingClient.Load().(*redis.Client); ok {
+		return client
+	}
+	// Concurrent callers may each build a client, but they're equivalent.
+	client := b.newRetryingClient()
+	b.retryingClient.Store(client)
+	return client
+}
+
+func (b *Backend) newRetryingClient() *redis.Client {
+	ret := b.Client.WithContext(b.Client.Context())
+	policy := b.RetryPolicy
+	ret.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			for attempt := 1; ; attempt++ {
+				if err := old(cmd); err == nil || !policy.Retry(ret.Context(), attempt, translateError(err)) {
+					return err
+				}
+			}
+		}
+	})
+	// Batches use Client directly, so only read-only pipelines, such as atomic reads, go through here.
+	ret.WrapProcessPipeline(func(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			for attempt := 1; ; attempt++ {
+				if err := old(cmds); err == nil || !policy.Retry(ret.Context(), attempt, translateError(err)) {
+					return err
+				}
+			}
+		}
+	})
+	return ret
+}
+
+// RunScript runs a Lua script with the backend's retry policy, translating errors the same way as
+// the backend's other methods. It lets packages built on top of backends make Redis-specific
+// optimizations.
+func (b *Backend) RunScript(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	result, err := script.Run(b.client(), keys, args...).Result()
+	if err != nil {
+		return nil, translateError(err)
 	}
+	return result, nil
 }
 
+// Batches use the client directly and aren't retried according to the retry policy.
 func (b *Backend) Batch() keyvaluestore.BatchOperation {
 	return &BatchOperation{
-		b.Client.Pipeline(),
+		pipe: b.Client.Pipeline(),
+		ctx:  b.Client.Context(),
 	}
 }
 
 func (b *Backend) AtomicWrite() keyvaluestore.AtomicWriteOperation {
 	return &AtomicWriteOperation{
-		Client: b.Client,
+		Client: b.client(),
+	}
+}
+
+func (b *Backend) AtomicRead() keyvaluestore.AtomicReadOperation {
+	return newAtomicReadOperation(b.client())
+}
+
+// Redis strings can be up to 512MB.
+const maxValueSize = 512 * 1024 * 1024
+
+func (b *Backend) Capabilities() keyvaluestore.Capabilities {
+	return keyvaluestore.Capabilities{
+		MaxValueSize:    maxValueSize,
+		TTL:             true,
+		Scan:            true,
+		WrongTypeErrors: true,
+		PubSub:          true,
 	}
 }
 
 func (b *Backend) CAS(key string, transform func(prev *string) (interface{}, error)) (bool, error) {
-	err := b.Client.Watch(func(tx *redis.Tx) error {
-		before, err := b.Get(key)
-		if err != nil {
-			return err
-		}
+	if err := b.Client.Context().Err(); err != nil {
+		return false, err
+	}
 
-		newValue, err := transform(before)
-		if err != nil {
+	client := b.client()
+	for attempt := 1; ; attempt++ {
+		// Errors from the read or the transform are returned as is. The read has already been retried.
+		var callbackErr error
+		err := client.Watch(func(tx *redis.Tx) error {
+			before, err := b.Get(key)
+			if err != nil {
+				callbackErr = err
+				return err
+			}
+
+			newValue, err := transform(before)
+			if err != nil {
+				callbackErr = err
+				return err
+			} else if newValue == nil {
+				return nil
+			}
+
+			_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
+				return pipe.Set(key, newValue, 0).Err()
+			})
 			return err
-		} else if newValue == nil {
-			return nil
+		}, key)
+		if err == redis.TxFailedErr {
+			return false, nil
+		} else if err == nil || callbackErr != nil {
+			return err == nil, translateError(err)
+		}
+		// The transaction's own commands don't go through the retrying client, so the whole
+		// transaction is retried instead.
+		if err = translateError(err); !b.RetryPolicy.Retry(client.Context(), attempt, err) {
+			return false, err
 		}
-
-		_, err = tx.TxPipelined(func(pipe redis.Pipeliner) error {
-			return pipe.Set(key, newValue, 0).Err()
-		})
-		return err
-	}, key)
-	if err == redis.TxFailedErr {
-		return false, nil
 	}
-	return err == nil, err
 }
 
 func (b *Backend) Delete(key string) (bool, error) {
-	result := b.Client.Del(key)
-	return result.Val() > 0, result.Err()
+	if err := b.Client.Context().Err(); err != nil {
+		return false, err
+	}
+
+	result := b.client().Del(key)
+	return result.Val() > 0, translateError(result.Err())
 }
 
 func (b *Backend) Get(key string) (*string, error) {
-	v, err := b.Client.Get(key).Result()
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	v, err := b.client().Get(key).Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &v, err
 }
 
 func (b *Backend) Set(key string, value interface{}) error {
-	return b.Client.Set(key, value, 0).Err()
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().Set(key, value, 0).Err())
+}
+
+// Redis expirations are in whole milliseconds, so TTLs are rounded up. Otherwise, TTLs under a
+// millisecond would become zero, which Redis rejects.
+func roundTTL(ttl time.Duration) time.Duration {
+	return (ttl + time.Millisecond - 1) / time.Millisecond * time.Millisecond
+}
+
+func (b *Backend) SetEX(key string, value interface{}, ttl time.Duration) error {
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl: %v", ttl)
+	}
+
+	return translateError(b.client().Set(key, value, roundTTL(ttl)).Err())
+}
+
+func (b *Backend) Expire(key string, ttl time.Duration) (bool, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return false, err
+	}
+
+	if ttl <= 0 {
+		return false, fmt.Errorf("invalid ttl: %v", ttl)
+	}
+
+	ret, err := b.client().PExpire(key, roundTTL(ttl)).Result()
+	return ret, translateError(err)
+}
+
+func (b *Backend) TTL(key string) (*time.Duration, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	// Redis uses negative values to indicate that the key doesn't exist or has no expiration.
+	ttl, err := b.client().PTTL(key).Result()
+	if err != nil || ttl < 0 {
+		return nil, translateError(err)
+	}
+	return &ttl, nil
 }
 
 func (b *Backend) AddInt(key string, n int64) (int64, error) {
-	return b.Client.IncrBy(key, n).Result()
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	ret, err := b.client().IncrBy(key, n).Result()
+	return ret, translateError(err)
 }
 
 func (b *Backend) ZIncrBy(key string, member string, n float64) (float64, error) {
-	return b.Client.ZIncrBy(key, n, member).Result()
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	ret, err := b.client().ZIncrBy(key, n, member).Result()
+	return ret, translateError(err)
 }
 
 func (b *Backend) SAdd(key string, member interface{}, members ...interface{}) error {
-	return b.Client.SAdd(key, append([]interface{}{member}, members...)...).Err()
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().SAdd(key, append([]interface{}{member}, members...)...).Err())
 }
 
 func (b *Backend) SRem(key string, member interface{}, members ...interface{}) error {
-	return b.Client.SRem(key, append([]interface{}{member}, members...)...).Err()
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().SRem(key, append([]interface{}{member}, members...)...).Err())
 }
 
 func (b *Backend) SMembers(key string) ([]string, error) {
-	return b.Client.SMembers(key).Result()
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	ret, err := b.client().SMembers(key).Result()
+	return ret, translateError(err)
+}
+
+func (b *Backend) SIsMember(key string, member interface{}) (bool, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return false, err
+	}
+
+	ret, err := b.client().SIsMember(key, member).Result()
+	return ret, translateError(err)
+}
+
+func (b *Backend) SCard(key string) (int, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.client().SCard(key).Result()
+	return int(n), translateError(err)
+}
+
+func (b *Backend) SRandMember(key string) (*string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return stringResult(b.client().SRandMember(key).Result())
+}
+
+func (b *Backend) SPop(key string) (*string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return stringResult(b.client().SPop(key).Result())
+}
+
+func stringResult(v string, err error) (*string, error) {
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, translateError(err)
+	}
+	return &v, nil
 }
 
 func (b *Backend) SetNX(key string, value interface{}) (bool, error) {
-	return b.Client.SetNX(key, value, 0).Result()
+	if err := b.Client.Context().Err(); err != nil {
+		return false, err
+	}
+
+	ret, err := b.client().SetNX(key, value, 0).Result()
+	return ret, translateError(err)
 }
 
 func (b *Backend) SetXX(key string, value interface{}) (bool, error) {
-	return b.Client.SetXX(key, value, 0).Result()
+	if err := b.Client.Context().Err(); err != nil {
+		return false, err
+	}
+
+	ret, err := b.client().SetXX(key, value, 0).Result()
+	return ret, translateError(err)
 }
 
 func (b *Backend) ZAdd(key string, member interface{}, score float64) error {
-	return b.Client.ZAdd(key, redis.Z{
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().ZAdd(key, redis.Z{
 		Member: member,
 		Score:  score,
-	}).Err()
+	}).Err())
 }
 
 func (b *Backend) ZScore(key string, member interface{}) (*float64, error) {
-	if score, err := b.Client.ZScore(key, *keyvaluestore.ToString(member)).Result(); err == nil {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	if score, err := b.client().ZScore(key, *keyvaluestore.ToString(member)).Result(); err == nil {
 		return &score, nil
 	} else if err != redis.Nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return nil, nil
 }
 
 func (b *Backend) ZRem(key string, member interface{}) error {
-	return b.Client.ZRem(key, member).Err()
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().ZRem(key, member).Err())
 }
 
 func (b *Backend) ZRangeByScore(key string, min, max float64, limit int) ([]string, error) {
@@ -134,14 +397,18 @@ func (b *Backend) ZRangeByScore(key string, min, max float64, limit int) ([]stri
 }
 
 func (b *Backend) ZRangeByScoreWithScores(key string, min, max float64, limit int) (keyvaluestore.ScoredMembers, error) {
-	results, err := b.Client.ZRangeByScoreWithScores(key, redis.ZRangeBy{
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	results, err := b.client().ZRangeByScoreWithScores(key, redis.ZRangeBy{
 		Min:   strings.ToLower(strconv.FormatFloat(min, 'g', -1, 64)),
 		Max:   strings.ToLower(strconv.FormatFloat(max, 'g', -1, 64)),
 		Count: int64(limit),
 	}).Result()
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	members := make([]*keyvaluestore.ScoredMember, len(results))
@@ -162,14 +429,18 @@ func (b *Backend) ZRevRangeByScore(key string, min, max float64, limit int) ([]s
 }
 
 func (b *Backend) ZRevRangeByScoreWithScores(key string, min, max float64, limit int) (keyvaluestore.ScoredMembers, error) {
-	results, err := b.Client.ZRevRangeByScoreWithScores(key, redis.ZRangeBy{
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	results, err := b.client().ZRevRangeByScoreWithScores(key, redis.ZRangeBy{
 		Min:   strings.ToLower(strconv.FormatFloat(min, 'g', -1, 64)),
 		Max:   strings.ToLower(strconv.FormatFloat(max, 'g', -1, 64)),
 		Count: int64(limit),
 	}).Result()
 
 	if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 
 	members := make([]*keyvaluestore.ScoredMember, len(results))
@@ -185,30 +456,479 @@ func (b *Backend) ZRevRangeByScoreWithScores(key string, min, max float64, limit
 }
 
 func (b *Backend) ZCount(key string, min, max float64) (int, error) {
-	n, err := b.Client.ZCount(key,
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.client().ZCount(key,
 		strings.ToLower(strconv.FormatFloat(min, 'g', -1, 64)),
 		strings.ToLower(strconv.FormatFloat(max, 'g', -1, 64)),
 	).Result()
-	return int(n), err
+	return int(n), translateError(err)
+}
+
+func formatScoreBound(bound keyvaluestore.ScoreBound) string {
+	if bound.Exclusive {
+		return "(" + formatScore(bound.Score)
+	}
+	return formatScore(bound.Score)
+}
+
+func scoreRangeBy(r keyvaluestore.ScoreRange) redis.ZRangeBy {
+	rangeBy := redis.ZRangeBy{
+		Min:    formatScoreBound(r.Min),
+		Max:    formatScoreBound(r.Max),
+		Offset: int64(r.Offset),
+		Count:  int64(r.Limit),
+	}
+	if r.Offset > 0 && r.Limit == 0 {
+		// A negative count tells Redis to return everything after the offset.
+		rangeBy.Count = -1
+	}
+	return rangeBy
+}
+
+func (b *Backend) ZRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return scoredMembersResult(b.client().ZRangeByScoreWithScores(key, scoreRangeBy(r)).Result())
+}
+
+func (b *Backend) ZRevRangeByScoreRange(key string, r keyvaluestore.ScoreRange) (keyvaluestore.ScoredMembers, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return scoredMembersResult(b.client().ZRevRangeByScoreWithScores(key, scoreRangeBy(r)).Result())
+}
+
+func (b *Backend) ZCountRange(key string, min, max keyvaluestore.ScoreBound) (int, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.client().ZCount(key, formatScoreBound(min), formatScoreBound(max)).Result()
+	return int(n), translateError(err)
 }
 
 func (b *Backend) ZLexCount(key string, min, max string) (int, error) {
-	n, err := b.Client.ZLexCount(key, min, max).Result()
-	return int(n), err
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.client().ZLexCount(key, min, max).Result()
+	return int(n), translateError(err)
 }
 
 func (b *Backend) ZRangeByLex(key string, min, max string, limit int) ([]string, error) {
-	return b.Client.ZRangeByLex(key, redis.ZRangeBy{
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	ret, err := b.client().ZRangeByLex(key, redis.ZRangeBy{
 		Min:   min,
 		Max:   max,
 		Count: int64(limit),
 	}).Result()
+	return ret, translateError(err)
 }
 
 func (b *Backend) ZRevRangeByLex(key string, min, max string, limit int) ([]string, error) {
-	return b.Client.ZRevRangeByLex(key, redis.ZRangeBy{
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	ret, err := b.client().ZRevRangeByLex(key, redis.ZRangeBy{
 		Min:   min,
 		Max:   max,
 		Count: int64(limit),
 	}).Result()
+	return ret, translateError(err)
+}
+
+// Continues a score range from a cursor member. Members that share the cursor's score are ordered
+// by member, so a binary search over them finds how many precede the cursor and must be skipped.
+// Members are compared byte-wise, as Redis does, rather than with Lua's locale-aware comparison.
+var zRangeByScorePageScript = redis.NewScript(`
+local key, bound, score, member = KEYS[1], ARGV[1], ARGV[2], ARGV[3]
+local range = ARGV[5] == '1' and 'zrevrangebyscore' or 'zrangebyscore'
+
+local function precedes(a, b)
+	for i = 1, math.min(#a, #b) do
+		local x, y = a:byte(i), b:byte(i)
+		if x ~= y then
+			return x < y
+		end
+	end
+	return #a <= #b
+end
+
+local lo, hi = 0, redis.call('zcount', key, score, score)
+while lo < hi do
+	local mid = math.floor((lo + hi) / 2)
+	local m = redis.call(range, key, score, score, 'limit', mid, 1)[1]
+	if (ARGV[5] == '1' and precedes(member, m)) or (ARGV[5] ~= '1' and precedes(m, member)) then
+		lo = mid + 1
+	else
+		hi = mid
+	end
+end
+
+return redis.call(range, key, score, bound, 'withscores', 'limit', lo, ARGV[4])
+`)
+
+func formatScore(f float64) string {
+	return strings.ToLower(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// Score range cursors hold the score and value of the last member of the page.
+func encodeZRangeCursor(member *keyvaluestore.ScoredMember) string {
+	buf := make([]byte, 8, 8+len(member.Value))
+	binary.BigEndian.PutUint64(buf, math.Float64bits(member.Score))
+	buf = append(buf, member.Value...)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeZRangeCursor(cursor string) (*keyvaluestore.ScoredMember, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(buf) < 8 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &keyvaluestore.ScoredMember{
+		Score: math.Float64frombits(binary.BigEndian.Uint64(buf)),
+		Value: string(buf[8:]),
+	}, nil
+}
+
+func (b *Backend) ZRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	return b.zRangeByScorePage(key, min, max, cursor, limit, false)
+}
+
+func (b *Backend) ZRevRangeByScorePage(key string, min, max float64, cursor string, limit int) (*keyvaluestore.ZRangePage, error) {
+	return b.zRangeByScorePage(key, min, max, cursor, limit, true)
+}
+
+func (b *Backend) zRangeByScorePage(key string, min, max float64, cursor string, limit int, reverse bool) (*keyvaluestore.ZRangePage, error) {
+	var members keyvaluestore.ScoredMembers
+	var err error
+
+	if cursor == "" {
+		if reverse {
+			members, err = b.ZRevRangeByScoreWithScores(key, min, max, limit)
+		} else {
+			members, err = b.ZRangeByScoreWithScores(key, min, max, limit)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if err := b.Client.Context().Err(); err != nil {
+			return nil, err
+		}
+
+		after, err := decodeZRangeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		bound, reverseArg := max, 0
+		if reverse {
+			bound, reverseArg = min, 1
+		}
+		count := limit
+		if count == 0 {
+			count = -1
+		}
+
+		result, err := zRangeByScorePageScript.Run(b.client(), []string{key}, formatScore(bound), formatScore(after.Score), after.Value, count, reverseArg).Result()
+		if err != nil {
+			return nil, translateError(err)
+		}
+		values, _ := result.([]interface{})
+		for i := 0; i+1 < len(values); i += 2 {
+			score, err := strconv.ParseFloat(values[i+1].(string), 64)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, &keyvaluestore.ScoredMember{
+				Score: score,
+				Value: values[i].(string),
+			})
+		}
+	}
+
+	page := &keyvaluestore.ZRangePage{
+		Members: members,
+	}
+	if limit > 0 && len(members) == limit {
+		page.Cursor = encodeZRangeCursor(members[len(members)-1])
+	}
+	return page, nil
+}
+
+// Lexicographical range cursors hold the last member of the page.
+func (b *Backend) ZRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	if cursor != "" {
+		after, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		min = "(" + string(after)
+	}
+	members, err := b.ZRangeByLex(key, min, max, limit)
+	if err != nil {
+		return nil, err
+	}
+	return lexRangePage(members, limit), nil
+}
+
+func (b *Backend) ZRevRangeByLexPage(key string, min, max, cursor string, limit int) (*keyvaluestore.ZLexRangePage, error) {
+	if cursor != "" {
+		before, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+		max = "(" + string(before)
+	}
+	members, err := b.ZRevRangeByLex(key, min, max, limit)
+	if err != nil {
+		return nil, err
+	}
+	return lexRangePage(members, limit), nil
+}
+
+func lexRangePage(members []string, limit int) *keyvaluestore.ZLexRangePage {
+	page := &keyvaluestore.ZLexRangePage{
+		Members: members,
+	}
+	if limit > 0 && len(members) == limit {
+		page.Cursor = base64.RawURLEncoding.EncodeToString([]byte(members[len(members)-1]))
+	}
+	return page
+}
+
+func (b *Backend) HSet(key, field string, value interface{}) error {
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().HSet(key, field, value).Err())
+}
+
+func (b *Backend) HGet(key, field string) (*string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	v, err := b.client().HGet(key, field).Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, translateError(err)
+	}
+	return &v, nil
+}
+
+func hmgetValues(values []interface{}) []*string {
+	results := make([]*string, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			results[i] = &s
+		}
+	}
+	return results
+}
+
+func (b *Backend) HMGet(key string, field string, fields ...string) ([]*string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	values, err := b.client().HMGet(key, append([]string{field}, fields...)...).Result()
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return hmgetValues(values), nil
+}
+
+func (b *Backend) HDel(key string, field string, fields ...string) error {
+	if err := b.Client.Context().Err(); err != nil {
+		return err
+	}
+
+	return translateError(b.client().HDel(key, append([]string{field}, fields...)...).Err())
+}
+
+func (b *Backend) HGetAll(key string) (map[string]string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	ret, err := b.client().HGetAll(key).Result()
+	return ret, translateError(err)
+}
+
+func (b *Backend) HIncrBy(key, field string, n int64) (int64, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	ret, err := b.client().HIncrBy(key, field, n).Result()
+	return ret, translateError(err)
+}
+
+// Escapes the characters that have special meaning in Redis glob-style patterns.
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (b *Backend) Scan(prefix, cursor string, limit int) (*keyvaluestore.ScanPage, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	var redisCursor uint64
+	if cursor != "" {
+		var err error
+		if redisCursor, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	keys, nextCursor, err := b.client().Scan(redisCursor, escapePattern(prefix)+"*", int64(limit)).Result()
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	page := &keyvaluestore.ScanPage{}
+	if nextCursor != 0 {
+		page.Cursor = strconv.FormatUint(nextCursor, 10)
+	}
+	if len(keys) == 0 {
+		return page, nil
+	}
+
+	pipe := b.client().Pipeline()
+	types := make([]*redis.StatusCmd, len(keys))
+	for i, key := range keys {
+		types[i] = pipe.Type(key)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil, translateError(err)
+	}
+
+	for i, key := range keys {
+		var keyType keyvaluestore.KeyType
+		switch types[i].Val() {
+		case "string":
+			keyType = keyvaluestore.KeyTypeString
+		case "set":
+			keyType = keyvaluestore.KeyTypeSet
+		case "zset":
+			keyType = keyvaluestore.KeyTypeSortedSet
+		case "hash":
+			keyType = keyvaluestore.KeyTypeHash
+		default:
+			// The key was deleted after it was scanned.
+			continue
+		}
+		page.Keys = append(page.Keys, keyvaluestore.ScannedKey{
+			Key:  key,
+			Type: keyType,
+		})
+	}
+	return page, nil
+}
+
+func (b *Backend) ZCard(key string) (int, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := b.client().ZCard(key).Result()
+	return int(n), translateError(err)
+}
+
+func (b *Backend) ZRank(key string, member interface{}) (*int, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return rankResult(b.client().ZRank(key, *keyvaluestore.ToString(member)).Result())
+}
+
+func (b *Backend) ZRevRank(key string, member interface{}) (*int, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return rankResult(b.client().ZRevRank(key, *keyvaluestore.ToString(member)).Result())
+}
+
+func rankResult(rank int64, err error) (*int, error) {
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, translateError(err)
+	}
+	ret := int(rank)
+	return &ret, nil
+}
+
+func (b *Backend) ZRange(key string, start, stop int) ([]string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	ret, err := b.client().ZRange(key, int64(start), int64(stop)).Result()
+	return ret, translateError(err)
+}
+
+func (b *Backend) ZRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return scoredMembersResult(b.client().ZRangeWithScores(key, int64(start), int64(stop)).Result())
+}
+
+func (b *Backend) ZRevRange(key string, start, stop int) ([]string, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	ret, err := b.client().ZRevRange(key, int64(start), int64(stop)).Result()
+	return ret, translateError(err)
+}
+
+func (b *Backend) ZRevRangeWithScores(key string, start, stop int) (keyvaluestore.ScoredMembers, error) {
+	if err := b.Client.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	return scoredMembersResult(b.client().ZRevRangeWithScores(key, int64(start), int64(stop)).Result())
+}
+
+func scoredMembersResult(results []redis.Z, err error) (keyvaluestore.ScoredMembers, error) {
+	if err != nil {
+		return nil, translateError(err)
+	}
+	members := make(keyvaluestore.ScoredMembers, len(results))
+	for i, result := range results {
+		members[i] = &keyvaluestore.ScoredMember{
+			Score: result.Score,
+			Value: result.Member.(string),
+		}
+	}
+	return members, nil
 }
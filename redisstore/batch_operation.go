@@ -1,6 +1,10 @@
 package redisstore
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/go-redis/redis"
 
 	"github.com/theaaf/keyvaluestore"
@@ -8,32 +12,47 @@ import (
 
 type BatchOperation struct {
 	pipe redis.Pipeliner
+	ctx  context.Context
+
+	// If the batch can't be executed, this is the error that all of its results return.
+	err error
+
+	// The first error caused by invalid arguments, which is returned by Exec.
+	argumentErr error
 }
 
 type GetResult struct {
 	*redis.StringCmd
+	batch *BatchOperation
 }
 
 func (r *GetResult) Result() (*string, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
 	v, err := r.StringCmd.Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &v, nil
 }
 
 type SMembersResult struct {
 	*redis.StringSliceCmd
+	batch *BatchOperation
 }
 
 func (r *SMembersResult) Result() ([]string, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
 	v, err := r.StringSliceCmd.Result()
 	if err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
-		return nil, err
+		return nil, translateError(err)
 	}
 	return v, nil
 }
@@ -44,53 +63,260 @@ type RedisCmd interface {
 
 type DeleteResult struct {
 	*redis.IntCmd
+	batch *BatchOperation
 }
 
 func (r *DeleteResult) Result() (bool, error) {
-	return r.IntCmd.Val() > 0, r.IntCmd.Err()
+	if r.batch.err != nil {
+		return false, r.batch.err
+	}
+	return r.IntCmd.Val() > 0, translateError(r.IntCmd.Err())
+}
+
+type BoolResult struct {
+	*redis.BoolCmd
+	batch *BatchOperation
+}
+
+func (r *BoolResult) Result() (bool, error) {
+	if r.batch.err != nil {
+		return false, r.batch.err
+	}
+	v, err := r.BoolCmd.Result()
+	return v, translateError(err)
+}
+
+type IntResult struct {
+	*redis.IntCmd
+	batch *BatchOperation
+}
+
+func (r *IntResult) Result() (int64, error) {
+	if r.batch.err != nil {
+		return 0, r.batch.err
+	}
+	v, err := r.IntCmd.Result()
+	return v, translateError(err)
+}
+
+type FloatResult struct {
+	*redis.FloatCmd
+	batch *BatchOperation
+}
+
+func (r *FloatResult) Result() (float64, error) {
+	if r.batch.err != nil {
+		return 0, r.batch.err
+	}
+	v, err := r.FloatCmd.Result()
+	return v, translateError(err)
+}
+
+type ScoreResult struct {
+	*redis.FloatCmd
+	batch *BatchOperation
+}
+
+func (r *ScoreResult) Result() (*float64, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
+	v, err := r.FloatCmd.Result()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, translateError(err)
+	}
+	return &v, nil
+}
+
+type ZRangeResult struct {
+	*redis.StringSliceCmd
+	batch *BatchOperation
+}
+
+func (r *ZRangeResult) Result() ([]string, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
+	v, err := r.StringSliceCmd.Result()
+	return v, translateError(err)
+}
+
+type ZRangeWithScoresResult struct {
+	*redis.ZSliceCmd
+	batch *BatchOperation
+}
+
+func (r *ZRangeWithScoresResult) Result() (keyvaluestore.ScoredMembers, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
+	return scoredMembersResult(r.ZSliceCmd.Result())
+}
+
+type HMGetResult struct {
+	*redis.SliceCmd
+	batch *BatchOperation
+}
+
+func (r *HMGetResult) Result() ([]*string, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
+	v, err := r.SliceCmd.Result()
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return hmgetValues(v), nil
+}
+
+type HGetAllResult struct {
+	*redis.StringStringMapCmd
+	batch *BatchOperation
+}
+
+func (r *HGetAllResult) Result() (map[string]string, error) {
+	if r.batch.err != nil {
+		return nil, r.batch.err
+	}
+	v, err := r.StringStringMapCmd.Result()
+	return v, translateError(err)
 }
 
 type ErrorResult struct {
 	RedisCmd
+	batch *BatchOperation
 }
 
 func (r *ErrorResult) Result() error {
-	return r.RedisCmd.Err()
+	if r.batch.err != nil {
+		return r.batch.err
+	}
+	return translateError(r.RedisCmd.Err())
 }
 
 func (op *BatchOperation) Get(key string) keyvaluestore.GetResult {
 	return &GetResult{
 		op.pipe.Get(key),
+		op,
 	}
 }
 
 func (op *BatchOperation) Set(key string, value interface{}) keyvaluestore.ErrorResult {
 	return &ErrorResult{
 		op.pipe.Set(key, value, 0),
+		op,
 	}
 }
 
+func (op *BatchOperation) SetNX(key string, value interface{}) keyvaluestore.BoolResult {
+	return &BoolResult{
+		op.pipe.SetNX(key, value, 0),
+		op,
+	}
+}
+
+func (op *BatchOperation) SetXX(key string, value interface{}) keyvaluestore.BoolResult {
+	return &BoolResult{
+		op.pipe.SetXX(key, value, 0),
+		op,
+	}
+}
+
+func (op *BatchOperation) AddInt(key string, n int64) keyvaluestore.IntResult {
+	return &IntResult{
+		op.pipe.IncrBy(key, n),
+		op,
+	}
+}
+
+func (op *BatchOperation) SetEX(key string, value interface{}, ttl time.Duration) keyvaluestore.ErrorResult {
+	if ttl <= 0 {
+		return &ErrorResult{
+			redis.NewStatusResult("", op.invalidArgument(fmt.Errorf("invalid ttl: %v", ttl))),
+			op,
+		}
+	}
+	return &ErrorResult{
+		op.pipe.Set(key, value, roundTTL(ttl)),
+		op,
+	}
+}
+
+func (op *BatchOperation) Expire(key string, ttl time.Duration) keyvaluestore.BoolResult {
+	if ttl <= 0 {
+		return &BoolResult{
+			redis.NewBoolResult(false, op.invalidArgument(fmt.Errorf("invalid ttl: %v", ttl))),
+			op,
+		}
+	}
+	return &BoolResult{
+		op.pipe.PExpire(key, roundTTL(ttl)),
+		op,
+	}
+}
+
+func (op *BatchOperation) invalidArgument(err error) error {
+	if op.argumentErr == nil {
+		op.argumentErr = err
+	}
+	return err
+}
+
 func (op *BatchOperation) Delete(key string) keyvaluestore.DeleteResult {
 	return &DeleteResult{
 		op.pipe.Del(key),
+		op,
 	}
 }
 
 func (op *BatchOperation) SMembers(key string) keyvaluestore.SMembersResult {
 	return &SMembersResult{
 		op.pipe.SMembers(key),
+		op,
 	}
 }
 
 func (op *BatchOperation) SAdd(key string, member interface{}, members ...interface{}) keyvaluestore.ErrorResult {
 	return &ErrorResult{
 		op.pipe.SAdd(key, append([]interface{}{member}, members...)...),
+		op,
 	}
 }
 
 func (op *BatchOperation) SRem(key string, member interface{}, members ...interface{}) keyvaluestore.ErrorResult {
 	return &ErrorResult{
 		op.pipe.SRem(key, append([]interface{}{member}, members...)...),
+		op,
+	}
+}
+
+func (op *BatchOperation) SIsMember(key string, member interface{}) keyvaluestore.BoolResult {
+	return &BoolResult{
+		op.pipe.SIsMember(key, member),
+		op,
+	}
+}
+
+func (op *BatchOperation) SCard(key string) keyvaluestore.IntResult {
+	return &IntResult{
+		op.pipe.SCard(key),
+		op,
+	}
+}
+
+func (op *BatchOperation) SRandMember(key string) keyvaluestore.GetResult {
+	return &GetResult{
+		op.pipe.SRandMember(key),
+		op,
+	}
+}
+
+func (op *BatchOperation) SPop(key string) keyvaluestore.GetResult {
+	return &GetResult{
+		op.pipe.SPop(key),
+		op,
 	}
 }
 
@@ -100,20 +326,126 @@ func (op *BatchOperation) ZAdd(key string, member interface{}, score float64) ke
 			Member: member,
 			Score:  score,
 		}),
+		op,
 	}
 }
 
 func (op *BatchOperation) ZRem(key string, member interface{}) keyvaluestore.ErrorResult {
 	return &ErrorResult{
 		op.pipe.ZRem(key, member),
+		op,
+	}
+}
+
+func (op *BatchOperation) ZScore(key string, member interface{}) keyvaluestore.ScoreResult {
+	return &ScoreResult{
+		op.pipe.ZScore(key, *keyvaluestore.ToString(member)),
+		op,
+	}
+}
+
+func (op *BatchOperation) ZIncrBy(key string, member string, n float64) keyvaluestore.FloatResult {
+	return &FloatResult{
+		op.pipe.ZIncrBy(key, n, member),
+		op,
+	}
+}
+
+func (op *BatchOperation) ZCount(key string, min, max float64) keyvaluestore.IntResult {
+	return &IntResult{
+		op.pipe.ZCount(key, formatScore(min), formatScore(max)),
+		op,
+	}
+}
+
+func (op *BatchOperation) ZRangeByScore(key string, min, max float64, limit int) keyvaluestore.ZRangeResult {
+	return &ZRangeResult{
+		op.pipe.ZRangeByScore(key, redis.ZRangeBy{
+			Min:   formatScore(min),
+			Max:   formatScore(max),
+			Count: int64(limit),
+		}),
+		op,
+	}
+}
+
+func (op *BatchOperation) ZRangeByScoreWithScores(key string, min, max float64, limit int) keyvaluestore.ZRangeWithScoresResult {
+	return &ZRangeWithScoresResult{
+		op.pipe.ZRangeByScoreWithScores(key, redis.ZRangeBy{
+			Min:   formatScore(min),
+			Max:   formatScore(max),
+			Count: int64(limit),
+		}),
+		op,
+	}
+}
+
+func (op *BatchOperation) ZRangeByLex(key string, min, max string, limit int) keyvaluestore.ZRangeResult {
+	return &ZRangeResult{
+		op.pipe.ZRangeByLex(key, redis.ZRangeBy{
+			Min:   min,
+			Max:   max,
+			Count: int64(limit),
+		}),
+		op,
+	}
+}
+
+func (op *BatchOperation) HSet(key, field string, value interface{}) keyvaluestore.ErrorResult {
+	return &ErrorResult{
+		op.pipe.HSet(key, field, value),
+		op,
+	}
+}
+
+func (op *BatchOperation) HGet(key, field string) keyvaluestore.GetResult {
+	return &GetResult{
+		op.pipe.HGet(key, field),
+		op,
+	}
+}
+
+func (op *BatchOperation) HMGet(key string, field string, fields ...string) keyvaluestore.HMGetResult {
+	return &HMGetResult{
+		op.pipe.HMGet(key, append([]string{field}, fields...)...),
+		op,
+	}
+}
+
+func (op *BatchOperation) HDel(key string, field string, fields ...string) keyvaluestore.ErrorResult {
+	return &ErrorResult{
+		op.pipe.HDel(key, append([]string{field}, fields...)...),
+		op,
+	}
+}
+
+func (op *BatchOperation) HGetAll(key string) keyvaluestore.HGetAllResult {
+	return &HGetAllResult{
+		op.pipe.HGetAll(key),
+		op,
+	}
+}
+
+func (op *BatchOperation) HIncrBy(key, field string, n int64) keyvaluestore.IntResult {
+	return &IntResult{
+		op.pipe.HIncrBy(key, field, n),
+		op,
 	}
 }
 
 func (op *BatchOperation) Exec() error {
+	if err := op.ctx.Err(); err != nil {
+		op.err = err
+		op.pipe.Discard()
+		return err
+	}
 	cmds, _ := op.pipe.Exec()
+	if op.argumentErr != nil {
+		return op.argumentErr
+	}
 	for _, cmd := range cmds {
 		if err := cmd.Err(); err != nil && err != redis.Nil {
-			return err
+			return translateError(err)
 		}
 	}
 	return nil
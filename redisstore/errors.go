@@ -0,0 +1,31 @@
+package redisstore
+
+import (
+	"strings"
+
+	"github.com/theaaf/keyvaluestore"
+)
+
+// Translates Redis error replies into the errors defined by the keyvaluestore package. go-redis
+// doesn't export its error reply type, so the messages are matched instead.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "WRONGTYPE"),
+		strings.Contains(msg, "is not an integer"),
+		strings.Contains(msg, "is not a valid float"):
+		return keyvaluestore.ErrWrongType
+	case strings.Contains(msg, "exceeds maximum allowed size"):
+		return keyvaluestore.ErrValueTooLarge
+	case strings.HasPrefix(msg, "BUSY "),
+		strings.HasPrefix(msg, "LOADING "),
+		strings.HasPrefix(msg, "MASTERDOWN "),
+		strings.HasPrefix(msg, "TRYAGAIN "):
+		// Redis rejected the command without running it, so it's safe to retry.
+		return keyvaluestore.ErrThrottled
+	}
+	return err
+}
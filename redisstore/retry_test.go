@@ -0,0 +1,110 @@
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var replies []error
+	attempts := 0
+
+	// The client never reaches a server. Its commands get the replies above, then succeed.
+	client := redis.NewClient(&redis.Options{
+		Addr: "127.0.0.1:0",
+	})
+	client.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			attempts++
+			if len(replies) > 0 {
+				err := replies[0]
+				replies = replies[1:]
+				return err
+			}
+			return nil
+		}
+	})
+	client.WrapProcessPipeline(func(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			attempts++
+			if len(replies) > 0 {
+				err := replies[0]
+				replies = replies[1:]
+				return err
+			}
+			return nil
+		}
+	})
+
+	clock := keyvaluestoretest.NewRecordingClock(time.Unix(1500000000, 0))
+	b := &Backend{
+		Client: client,
+		RetryPolicy: &keyvaluestore.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			Clock:       clock,
+		},
+	}
+
+	replies = []error{
+		fmt.Errorf("LOADING Redis is loading the dataset in memory"),
+		fmt.Errorf("BUSY Redis is busy running a script"),
+	}
+	assert.NoError(t, b.Set("foo", "bar"))
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.Waits())
+
+	attempts = 0
+	replies = []error{
+		fmt.Errorf("TRYAGAIN Multiple keys request during rehashing of slot"),
+		fmt.Errorf("TRYAGAIN Multiple keys request during rehashing of slot"),
+		fmt.Errorf("TRYAGAIN Multiple keys request during rehashing of slot"),
+	}
+	assert.Error(t, b.client().Process(redis.NewStatusCmd("set", "foo", "bar")))
+	assert.Equal(t, 3, attempts)
+
+	attempts = 0
+	replies = []error{
+		fmt.Errorf("WRONGTYPE Operation against a key holding the wrong kind of value"),
+	}
+	assert.Error(t, b.client().Process(redis.NewStatusCmd("set", "foo", "bar")))
+	assert.Equal(t, 1, attempts)
+
+	// Atomic reads are retried as a whole.
+	attempts = 0
+	replies = []error{
+		fmt.Errorf("LOADING Redis is loading the dataset in memory"),
+	}
+	atomicRead := b.AtomicRead()
+	atomicRead.Get("foo")
+	assert.NoError(t, atomicRead.Exec())
+	assert.Equal(t, 2, attempts)
+
+	// Batches aren't retried.
+	attempts = 0
+	replies = []error{
+		fmt.Errorf("LOADING Redis is loading the dataset in memory"),
+	}
+	batch := b.Batch()
+	batch.Set("foo", "bar")
+	batch.Exec()
+	assert.Equal(t, 1, attempts)
+
+	// The retrying client is only built once per backend.
+	assert.True(t, b.client() == b.client())
+	withContext := b.WithContext(context.Background()).(*Backend)
+	assert.True(t, withContext.client() == withContext.client())
+	assert.False(t, withContext.client() == b.client())
+
+	// Derived backends keep the policy.
+	assert.Equal(t, b.RetryPolicy, b.WithProfiler(&BasicProfiler{}).RetryPolicy)
+	assert.Equal(t, b.RetryPolicy, withContext.RetryPolicy)
+}
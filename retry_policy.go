@@ -0,0 +1,128 @@
+package keyvaluestore
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy determines how backends retry requests that fail due to contention or throttling.
+// The zero value makes up to 3 attempts without waiting between them. A nil policy behaves like
+// DefaultRetryPolicy.
+type RetryPolicy struct {
+	// The maximum number of attempts, including the first. Defaults to 3.
+	MaxAttempts int
+
+	// The delay before the first retry. Each retry after that waits twice as long as the last, up to
+	// MaxDelay. If zero, retries happen immediately.
+	BaseDelay time.Duration
+
+	// The maximum delay between attempts. If zero, delays aren't capped.
+	MaxDelay time.Duration
+
+	// The fraction of each delay, between 0 and 1, that is randomized. With a jitter of 1, each
+	// delay is anywhere from zero to the full amount, which keeps clients that fail at the same time
+	// from retrying in lockstep.
+	Jitter float64
+
+	// Returns true if a request that failed with the given error should be retried. Backends
+	// translate their errors before classifying them, so this can compare against errors such as
+	// ErrContention. Defaults to IsRetryableError.
+	Retryable func(err error) bool
+
+	// The clock used to wait between attempts. Defaults to SystemClock.
+	Clock Clock
+}
+
+// DefaultRetryPolicy is used by backends that aren't given a retry policy. It makes up to 3
+// attempts, waiting up to 20ms and then 40ms between them.
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: defaultRetryMaxAttempts,
+	BaseDelay:   20 * time.Millisecond,
+	MaxDelay:    time.Second,
+	Jitter:      1,
+}
+
+const defaultRetryMaxAttempts = 3
+
+// IsRetryableError returns true for ErrContention and ErrThrottled.
+func IsRetryableError(err error) bool {
+	return err == ErrContention || err == ErrThrottled
+}
+
+func (p *RetryPolicy) orDefault() *RetryPolicy {
+	if p == nil {
+		return DefaultRetryPolicy
+	}
+	return p
+}
+
+// Returns MaxAttempts, or its default if it isn't set.
+func (p *RetryPolicy) Attempts() int {
+	if p = p.orDefault(); p.MaxAttempts > 0 {
+		return p.MaxAttempts
+	}
+	return defaultRetryMaxAttempts
+}
+
+// Returns true if a request that failed with the given error should be retried.
+func (p *RetryPolicy) IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	} else if p = p.orDefault(); p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryableError(err)
+}
+
+// Returns how long to wait before the given retry, where the first retry is 1.
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	p = p.orDefault()
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	max := p.MaxDelay
+	if max <= 0 {
+		max = math.MaxInt64 / 2
+	}
+	d := ExponentialBackoff(p.BaseDelay, max)(retry)
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * math.Min(p.Jitter, 1) * float64(d))
+	}
+	return d
+}
+
+// Retry is called after the given attempt fails, where the first attempt is 1. If the error is
+// retryable and attempts remain, it waits for the delay and returns true. It returns false without
+// waiting the full delay if the context is done first.
+func (p *RetryPolicy) Retry(ctx context.Context, attempt int, err error) bool {
+	if attempt >= p.Attempts() || !p.IsRetryable(err) {
+		return false
+	}
+
+	d := p.Delay(attempt)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	clock := p.orDefault().Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	select {
+	case <-clock.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Run calls f until it succeeds or fails with an error that can't be retried, and returns f's last
+// error.
+func (p *RetryPolicy) Run(ctx context.Context, f func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := f(); err == nil || !p.Retry(ctx, attempt, err) {
+			return err
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package keyvaluestore_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestoretest"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("Delay", func(t *testing.T) {
+		p := &keyvaluestore.RetryPolicy{
+			BaseDelay: 10 * time.Millisecond,
+			MaxDelay:  50 * time.Millisecond,
+		}
+		assert.Equal(t, 10*time.Millisecond, p.Delay(1))
+		assert.Equal(t, 20*time.Millisecond, p.Delay(2))
+		assert.Equal(t, 40*time.Millisecond, p.Delay(3))
+		assert.Equal(t, 50*time.Millisecond, p.Delay(4))
+
+		p.MaxDelay = 0
+		assert.Equal(t, 80*time.Millisecond, p.Delay(4))
+
+		p.BaseDelay = 0
+		assert.Equal(t, time.Duration(0), p.Delay(4))
+	})
+
+	t.Run("Jitter", func(t *testing.T) {
+		p := &keyvaluestore.RetryPolicy{
+			BaseDelay: 100 * time.Millisecond,
+			Jitter:    0.5,
+		}
+		for i := 0; i < 100; i++ {
+			d := p.Delay(1)
+			assert.True(t, d > 50*time.Millisecond && d <= 100*time.Millisecond, d)
+		}
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		clock := keyvaluestoretest.NewRecordingClock(time.Unix(1500000000, 0))
+		p := &keyvaluestore.RetryPolicy{
+			MaxAttempts: 4,
+			BaseDelay:   10 * time.Millisecond,
+			Clock:       clock,
+		}
+
+		attempts := 0
+		assert.NoError(t, p.Run(context.Background(), func() error {
+			attempts++
+			if attempts < 3 {
+				return keyvaluestore.ErrThrottled
+			}
+			return nil
+		}))
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.Waits())
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		attempts := 0
+		err := (&keyvaluestore.RetryPolicy{}).Run(context.Background(), func() error {
+			attempts++
+			return keyvaluestore.ErrContention
+		})
+		assert.Equal(t, keyvaluestore.ErrContention, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("NotRetryable", func(t *testing.T) {
+		attempts := 0
+		err := (&keyvaluestore.RetryPolicy{}).Run(context.Background(), func() error {
+			attempts++
+			return fmt.Errorf("foo")
+		})
+		assert.EqualError(t, err, "foo")
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("Retryable", func(t *testing.T) {
+		p := &keyvaluestore.RetryPolicy{
+			Retryable: func(err error) bool {
+				return err == keyvaluestore.ErrThrottled
+			},
+		}
+		assert.True(t, p.IsRetryable(keyvaluestore.ErrThrottled))
+		assert.False(t, p.IsRetryable(keyvaluestore.ErrContention))
+		assert.False(t, p.IsRetryable(nil))
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &keyvaluestore.RetryPolicy{
+			BaseDelay: time.Hour,
+			Clock:     keyvaluestoretest.NewFakeClock(time.Unix(1500000000, 0)),
+		}
+
+		attempts := 0
+		err := p.Run(ctx, func() error {
+			attempts++
+			cancel()
+			return keyvaluestore.ErrThrottled
+		})
+		assert.Equal(t, keyvaluestore.ErrThrottled, err)
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		var p *keyvaluestore.RetryPolicy
+		assert.Equal(t, 3, p.Attempts())
+		assert.True(t, p.IsRetryable(keyvaluestore.ErrContention))
+	})
+}
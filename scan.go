@@ -0,0 +1,38 @@
+package keyvaluestore
+
+// KeyType is the type of data held by a key.
+type KeyType int
+
+const (
+	KeyTypeString KeyType = iota + 1
+	KeyTypeSet
+	KeyTypeSortedSet
+	KeyTypeHash
+)
+
+func (t KeyType) String() string {
+	switch t {
+	case KeyTypeString:
+		return "string"
+	case KeyTypeSet:
+		return "set"
+	case KeyTypeSortedSet:
+		return "sorted set"
+	case KeyTypeHash:
+		return "hash"
+	}
+	return "unknown"
+}
+
+type ScannedKey struct {
+	Key  string
+	Type KeyType
+}
+
+// ScanPage is a page of keys returned by Scan.
+type ScanPage struct {
+	Keys []ScannedKey
+
+	// The cursor to pass to Scan to get the next page. If empty, there are no more keys.
+	Cursor string
+}
@@ -0,0 +1,44 @@
+package keyvaluestore
+
+import "math"
+
+// ScoreBound is one end of a score range.
+type ScoreBound struct {
+	Score float64
+
+	// If true, members with exactly this score are outside of the range.
+	Exclusive bool
+}
+
+// Returns a bound that includes members with the given score.
+func InclusiveScore(score float64) ScoreBound {
+	return ScoreBound{
+		Score: score,
+	}
+}
+
+// Returns a bound that excludes members with the given score.
+func ExclusiveScore(score float64) ScoreBound {
+	return ScoreBound{
+		Score:     score,
+		Exclusive: true,
+	}
+}
+
+// Bounds that include every score.
+var (
+	MinScore = InclusiveScore(math.Inf(-1))
+	MaxScore = InclusiveScore(math.Inf(1))
+)
+
+// ScoreRange describes a range of sorted set members by score.
+type ScoreRange struct {
+	Min ScoreBound
+	Max ScoreBound
+
+	// The number of members at the start of the range to skip.
+	Offset int
+
+	// The maximum number of members to return. If 0, all of them are returned.
+	Limit int
+}
@@ -0,0 +1,160 @@
+package keyvaluestore
+
+import "time"
+
+// TransactionOptions configures RunTransaction. The zero value is usable.
+type TransactionOptions struct {
+	// The maximum number of times the transaction function is run. Defaults to 10.
+	MaxAttempts int
+
+	// Returns how long to wait before the given retry, where the first retry is attempt 1. If nil,
+	// retries happen immediately.
+	Backoff func(attempt int) time.Duration
+
+	// The clock used to wait between retries. Defaults to SystemClock.
+	Clock Clock
+}
+
+// ExponentialBackoff returns a backoff function for TransactionOptions that doubles the delay with
+// each retry, starting at base and never exceeding max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			return max
+		}
+		return d
+	}
+}
+
+const defaultTransactionMaxAttempts = 10
+
+// Transaction records the reads made through it and buffers its writes. When committed by
+// RunTransaction, every read becomes a condition of a single atomic write, so the writes only take
+// effect if nothing that was read has changed.
+type Transaction struct {
+	backend Backend
+
+	// The keys in the order they were first read or written, so that commits are deterministic.
+	keys   []string
+	reads  map[string]*string
+	writes map[string]interface{}
+}
+
+func newTransaction(b Backend) *Transaction {
+	return &Transaction{
+		backend: b,
+		reads:   map[string]*string{},
+		writes:  map[string]interface{}{},
+	}
+}
+
+func (tx *Transaction) addKey(key string) {
+	_, read := tx.reads[key]
+	_, written := tx.writes[key]
+	if !read && !written {
+		tx.keys = append(tx.keys, key)
+	}
+}
+
+// Gets the key. Values set earlier in the transaction are returned without reading the backend.
+func (tx *Transaction) Get(key string) (*string, error) {
+	if v, ok := tx.writes[key]; ok {
+		return ToString(v), nil
+	} else if v, ok := tx.reads[key]; ok {
+		return v, nil
+	}
+
+	v, err := tx.backend.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	tx.addKey(key)
+	tx.reads[key] = v
+	return v, nil
+}
+
+// Sets the key when the transaction is committed. If the key was read, the write is conditional on
+// its value not having changed.
+func (tx *Transaction) Set(key string, value interface{}) {
+	tx.addKey(key)
+	tx.writes[key] = value
+}
+
+func (tx *Transaction) commit() (bool, error) {
+	if len(tx.keys) == 0 {
+		return true, nil
+	} else if max := tx.backend.Capabilities().MaxAtomicWriteOperations; max > 0 && len(tx.keys) > max {
+		return false, ErrTooManyOperations
+	}
+
+	op := tx.backend.AtomicWrite()
+	for _, key := range tx.keys {
+		prev, read := tx.reads[key]
+		value, written := tx.writes[key]
+		switch {
+		case !written && prev == nil:
+			op.CheckNotExists(key)
+		case !written:
+			op.Check(key, *prev)
+		case !read:
+			op.Set(key, value)
+		case prev == nil:
+			op.SetNX(key, value)
+		default:
+			s := ToString(value)
+			if s == nil {
+				return false, ErrWrongType
+			}
+			op.CAS(key, *prev, *s)
+		}
+	}
+	return op.Exec()
+}
+
+// RunTransaction runs f and commits its reads and writes atomically. If anything that f read is
+// changed before the commit, f is run again with a new transaction, up to opts.MaxAttempts times,
+// after which ErrContention is returned. If f returns an error, the transaction is abandoned and
+// the error is returned. Because f may run multiple times, it should not have side effects outside
+// of the transaction.
+//
+// Every key that is read or written counts towards the backend's MaxAtomicWriteOperations.
+func RunTransaction(b Backend, opts *TransactionOptions, f func(tx *Transaction) error) error {
+	maxAttempts := defaultTransactionMaxAttempts
+	var backoff func(attempt int) time.Duration
+	clock := SystemClock
+	if opts != nil {
+		if opts.MaxAttempts > 0 {
+			maxAttempts = opts.MaxAttempts
+		}
+		backoff = opts.Backoff
+		if opts.Clock != nil {
+			clock = opts.Clock
+		}
+	}
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 && backoff != nil {
+			if d := backoff(attempt); d > 0 {
+				<-clock.After(d)
+			}
+		}
+
+		tx := newTransaction(b)
+		if err := f(tx); err != nil {
+			return err
+		}
+
+		if ok, err := tx.commit(); err == ErrContention {
+			continue
+		} else if err != nil {
+			return err
+		} else if ok {
+			return nil
+		}
+	}
+	return ErrContention
+}
@@ -0,0 +1,184 @@
+package keyvaluestore_test
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+// Limits atomic writes the way DynamoDB does.
+type limitedBackend struct {
+	*memorystore.Backend
+}
+
+func (b *limitedBackend) Capabilities() keyvaluestore.Capabilities {
+	ret := b.Backend.Capabilities()
+	ret.MaxAtomicWriteOperations = 10
+	return ret
+}
+
+func getInt(tx *keyvaluestore.Transaction, key string) (int, error) {
+	v, err := tx.Get(key)
+	if err != nil || v == nil {
+		return 0, err
+	}
+	return strconv.Atoi(*v)
+}
+
+func TestRunTransaction(t *testing.T) {
+	t.Run("Transfer", func(t *testing.T) {
+		b := memorystore.NewBackend()
+		require.NoError(t, b.Set("a", 10))
+
+		require.NoError(t, keyvaluestore.RunTransaction(b, nil, func(tx *keyvaluestore.Transaction) error {
+			a, err := getInt(tx, "a")
+			if err != nil {
+				return err
+			}
+			bal, err := getInt(tx, "b")
+			if err != nil {
+				return err
+			}
+			tx.Set("a", a-3)
+			tx.Set("b", bal+3)
+
+			// Reads see the transaction's own writes.
+			v, err := tx.Get("a")
+			require.NoError(t, err)
+			assert.Equal(t, "7", *v)
+			return nil
+		}))
+
+		v, err := b.Get("a")
+		require.NoError(t, err)
+		assert.Equal(t, "7", *v)
+
+		v, err = b.Get("b")
+		require.NoError(t, err)
+		assert.Equal(t, "3", *v)
+	})
+
+	t.Run("Conflict", func(t *testing.T) {
+		b := memorystore.NewBackend()
+		require.NoError(t, b.Set("a", 1))
+		require.NoError(t, b.Set("guard", "x"))
+
+		var backoffs []int
+		attempts := 0
+		require.NoError(t, keyvaluestore.RunTransaction(b, &keyvaluestore.TransactionOptions{
+			Backoff: func(attempt int) time.Duration {
+				backoffs = append(backoffs, attempt)
+				return 0
+			},
+		}, func(tx *keyvaluestore.Transaction) error {
+			attempts++
+			a, err := getInt(tx, "a")
+			if err != nil {
+				return err
+			}
+			if _, err := tx.Get("guard"); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				// Simulate a concurrent write to a key that was only read.
+				require.NoError(t, b.Set("guard", "y"))
+			}
+			tx.Set("a", a+1)
+			return nil
+		}))
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, []int{1}, backoffs)
+
+		v, err := b.Get("a")
+		require.NoError(t, err)
+		assert.Equal(t, "2", *v)
+	})
+
+	t.Run("NotExists", func(t *testing.T) {
+		b := memorystore.NewBackend()
+
+		attempts := 0
+		require.NoError(t, keyvaluestore.RunTransaction(b, nil, func(tx *keyvaluestore.Transaction) error {
+			attempts++
+			v, err := tx.Get("a")
+			if err != nil || v != nil {
+				return err
+			}
+			if attempts == 1 {
+				require.NoError(t, b.Set("a", "concurrent"))
+			}
+			tx.Set("a", "tx")
+			return nil
+		}))
+		assert.Equal(t, 2, attempts)
+
+		v, err := b.Get("a")
+		require.NoError(t, err)
+		assert.Equal(t, "concurrent", *v)
+	})
+
+	t.Run("Contention", func(t *testing.T) {
+		b := memorystore.NewBackend()
+
+		attempts := 0
+		err := keyvaluestore.RunTransaction(b, &keyvaluestore.TransactionOptions{
+			MaxAttempts: 3,
+		}, func(tx *keyvaluestore.Transaction) error {
+			attempts++
+			if _, err := tx.Get("a"); err != nil {
+				return err
+			}
+			require.NoError(t, b.Set("a", attempts))
+			tx.Set("b", "x")
+			return nil
+		})
+		assert.Equal(t, keyvaluestore.ErrContention, err)
+		assert.Equal(t, 3, attempts)
+
+		v, err := b.Get("b")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		b := memorystore.NewBackend()
+
+		err := keyvaluestore.RunTransaction(b, nil, func(tx *keyvaluestore.Transaction) error {
+			tx.Set("a", "x")
+			return fmt.Errorf("abort")
+		})
+		assert.EqualError(t, err, "abort")
+
+		v, err := b.Get("a")
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("TooManyOperations", func(t *testing.T) {
+		b := &limitedBackend{memorystore.NewBackend()}
+
+		err := keyvaluestore.RunTransaction(b, nil, func(tx *keyvaluestore.Transaction) error {
+			for i := 0; i <= 10; i++ {
+				tx.Set(fmt.Sprintf("key%v", i), i)
+			}
+			return nil
+		})
+		assert.Equal(t, keyvaluestore.ErrTooManyOperations, err)
+	})
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := keyvaluestore.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, backoff(1))
+	assert.Equal(t, 20*time.Millisecond, backoff(2))
+	assert.Equal(t, 40*time.Millisecond, backoff(3))
+	assert.Equal(t, 50*time.Millisecond, backoff(4))
+	assert.Equal(t, 50*time.Millisecond, backoff(100))
+}
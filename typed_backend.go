@@ -0,0 +1,73 @@
+package keyvaluestore
+
+import (
+	"context"
+	"reflect"
+)
+
+// TypedBackend stores structured values using a codec. It embeds the backend, so all of the
+// untyped operations remain available.
+type TypedBackend struct {
+	Backend
+	Codec Codec
+}
+
+func NewTypedBackend(b Backend, codec Codec) *TypedBackend {
+	return &TypedBackend{
+		Backend: b,
+		Codec:   codec,
+	}
+}
+
+// Returns a *TypedBackend that performs its operations within the given context.
+func (b *TypedBackend) WithContext(ctx context.Context) Backend {
+	return &TypedBackend{
+		Backend: b.Backend.WithContext(ctx),
+		Codec:   b.Codec,
+	}
+}
+
+// Gets the key and decodes it into v, which must be a pointer. Returns false if the key doesn't
+// exist.
+func (b *TypedBackend) GetInto(key string, v interface{}) (bool, error) {
+	s, err := b.Get(key)
+	if err != nil || s == nil {
+		return false, err
+	}
+	return true, b.Codec.Unmarshal([]byte(*s), v)
+}
+
+// Encodes v and sets the key to it.
+func (b *TypedBackend) SetValue(key string, v interface{}) error {
+	data, err := b.Codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Set(key, data)
+}
+
+// CASValue is like CAS, but the transform function works with decoded values. v must be a pointer
+// to the type of the stored value. If the key exists, its value is decoded into a new value of that
+// type, which is passed to the transform. Otherwise the transform receives nil. Values returned by
+// the transform are encoded before being stored, and as with CAS, returning nil performs no action.
+//
+// The transform may be called more than once, and receives a freshly decoded value each time, so
+// codecs that leave missing fields untouched never merge one attempt's value into another's.
+func (b *TypedBackend) CASValue(key string, v interface{}, transform func(prev interface{}) (interface{}, error)) (bool, error) {
+	t := reflect.TypeOf(v).Elem()
+	return b.CAS(key, func(s *string) (interface{}, error) {
+		var prev interface{}
+		if s != nil {
+			prev = reflect.New(t).Interface()
+			if err := b.Codec.Unmarshal([]byte(*s), prev); err != nil {
+				return nil, err
+			}
+		}
+
+		next, err := transform(prev)
+		if err != nil || next == nil {
+			return nil, err
+		}
+		return b.Codec.Marshal(next)
+	})
+}
@@ -0,0 +1,184 @@
+package keyvaluestore_test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/theaaf/keyvaluestore"
+	"github.com/theaaf/keyvaluestore/keyvaluestorecache"
+	"github.com/theaaf/keyvaluestore/memorystore"
+)
+
+type testStruct struct {
+	Name  string
+	Count int
+}
+
+// Mimics a generated protobuf message.
+type testMessage struct {
+	Count uint64
+}
+
+func (m *testMessage) Marshal() ([]byte, error) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, m.Count)], nil
+}
+
+func (m *testMessage) Unmarshal(data []byte) error {
+	n, size := binary.Uvarint(data)
+	if size <= 0 {
+		return fmt.Errorf("invalid message")
+	}
+	m.Count = n
+	return nil
+}
+
+// Before its first CAS, calls the transform and then replaces the value, as if a concurrent write
+// had made the CAS fail.
+type racingBackend struct {
+	keyvaluestore.Backend
+	race func()
+}
+
+func (b *racingBackend) CAS(key string, transform func(v *string) (interface{}, error)) (bool, error) {
+	if race := b.race; race != nil {
+		b.race = nil
+		v, err := b.Backend.Get(key)
+		if err != nil {
+			return false, err
+		}
+		if _, err := transform(v); err != nil {
+			return false, err
+		}
+		race()
+	}
+	return b.Backend.CAS(key, transform)
+}
+
+func TestTypedBackend(t *testing.T) {
+	for name, newBackend := range map[string]func() keyvaluestore.Backend{
+		"Memory": func() keyvaluestore.Backend {
+			return memorystore.NewBackend()
+		},
+		"ReadCache": func() keyvaluestore.Backend {
+			return keyvaluestorecache.NewReadCache(memorystore.NewBackend())
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for name, codec := range map[string]keyvaluestore.Codec{
+				"JSON": keyvaluestore.JSONCodec,
+				"Gob":  keyvaluestore.GobCodec,
+			} {
+				t.Run(name, func(t *testing.T) {
+					b := keyvaluestore.NewTypedBackend(newBackend(), codec)
+
+					var v testStruct
+					ok, err := b.GetInto("foo", &v)
+					assert.NoError(t, err)
+					assert.False(t, ok)
+
+					require.NoError(t, b.SetValue("foo", testStruct{Name: "foo", Count: 1}))
+
+					ok, err = b.GetInto("foo", &v)
+					assert.NoError(t, err)
+					assert.True(t, ok)
+					assert.Equal(t, testStruct{Name: "foo", Count: 1}, v)
+
+					ok, err = b.CASValue("foo", &testStruct{}, func(prev interface{}) (interface{}, error) {
+						require.NotNil(t, prev)
+						v := prev.(*testStruct)
+						v.Count++
+						return v, nil
+					})
+					assert.NoError(t, err)
+					assert.True(t, ok)
+
+					ok, err = b.GetInto("foo", &v)
+					assert.NoError(t, err)
+					assert.True(t, ok)
+					assert.Equal(t, testStruct{Name: "foo", Count: 2}, v)
+
+					ok, err = b.CASValue("bar", &testStruct{}, func(prev interface{}) (interface{}, error) {
+						assert.Nil(t, prev)
+						return testStruct{Name: "bar"}, nil
+					})
+					assert.NoError(t, err)
+					assert.True(t, ok)
+
+					var bar testStruct
+					ok, err = b.GetInto("bar", &bar)
+					assert.NoError(t, err)
+					assert.True(t, ok)
+					assert.Equal(t, testStruct{Name: "bar"}, bar)
+				})
+			}
+		})
+	}
+
+	t.Run("CASValueRetry", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			codec keyvaluestore.Codec
+
+			// A second value that omits the name, which JSON and gob leave untouched when decoding.
+			second string
+		}{
+			"JSON": {keyvaluestore.JSONCodec, `{"Count":2}`},
+			"Gob":  {keyvaluestore.GobCodec, ""},
+		} {
+			codec, second := tc.codec, tc.second
+			t.Run(name, func(t *testing.T) {
+				if second == "" {
+					data, err := codec.Marshal(testStruct{Count: 2})
+					require.NoError(t, err)
+					second = string(data)
+				}
+
+				backend := &racingBackend{
+					Backend: memorystore.NewBackend(),
+				}
+				b := keyvaluestore.NewTypedBackend(backend, codec)
+				require.NoError(t, b.SetValue("foo", testStruct{Name: "foo", Count: 1}))
+
+				// The second attempt's value omits the name that the first one set.
+				backend.race = func() {
+					require.NoError(t, backend.Backend.Set("foo", second))
+				}
+
+				var attempts []testStruct
+				ok, err := b.CASValue("foo", &testStruct{}, func(prev interface{}) (interface{}, error) {
+					v := prev.(*testStruct)
+					attempts = append(attempts, *v)
+					v.Count += 10
+					return v, nil
+				})
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, []testStruct{{Name: "foo", Count: 1}, {Count: 2}}, attempts)
+
+				var v testStruct
+				ok, err = b.GetInto("foo", &v)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, testStruct{Count: 12}, v)
+			})
+		}
+	})
+
+	t.Run("Protobuf", func(t *testing.T) {
+		b := keyvaluestore.NewTypedBackend(memorystore.NewBackend(), keyvaluestore.ProtobufCodec)
+
+		require.NoError(t, b.SetValue("foo", &testMessage{Count: 300}))
+
+		var m testMessage
+		ok, err := b.GetInto("foo", &m)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.EqualValues(t, 300, m.Count)
+
+		assert.Error(t, b.SetValue("foo", testStruct{}))
+	})
+}
@@ -0,0 +1,19 @@
+package keyvaluestore
+
+// ZRangePage is a page of members returned by ZRangeByScorePage or ZRevRangeByScorePage.
+type ZRangePage struct {
+	Members ScoredMembers
+
+	// The cursor to pass to get the next page. If empty, there are no more members. A non-empty
+	// cursor may still be followed by an empty page.
+	Cursor string
+}
+
+// ZLexRangePage is a page of members returned by ZRangeByLexPage or ZRevRangeByLexPage.
+type ZLexRangePage struct {
+	Members []string
+
+	// The cursor to pass to get the next page. If empty, there are no more members. A non-empty
+	// cursor may still be followed by an empty page.
+	Cursor string
+}